@@ -0,0 +1,387 @@
+package jdb
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+const (
+	maxAttachmentName        = 255
+	defaultMaxAttachmentSize = 16 << 20
+	attachmentChunkSize      = 1 << 20
+)
+
+// Attachment describes binary data stored with a document. The attachments
+// table has no data column, the data itself is stored once per distinct
+// SHA256 hash, in chunks in the blob chunks table (e.g. jdb_blob_chunks).
+type Attachment struct {
+	Name        string
+	ContentType string
+	Size        int64
+	SHA256      string
+}
+
+func attachmentsTable(table string) string {
+	return table + "_attachments"
+}
+
+func blobsTable(table string) string {
+	return table + "_blobs"
+}
+
+func blobChunksTable(table string) string {
+	return table + "_blob_chunks"
+}
+
+// scanRow scans the first row of the query written by w into dest, it
+// returns sql.ErrNoRows when there are no rows.
+func (t *Tx) scanRow(ctx context.Context, w *SQLWriter, dest ...interface{}) error {
+	if t.committed {
+		return ErrTxCommitted
+	}
+	query, params := w.toSQL()
+	return t.tx.QueryRowContext(ctx, query, params...).Scan(dest...)
+}
+
+func (t *Tx) queryWriter(ctx context.Context, w *SQLWriter) (*sql.Rows, error) {
+	if t.committed {
+		return nil, ErrTxCommitted
+	}
+	query, params := w.toSQL()
+	rows, err := t.tx.QueryContext(ctx, query, params...)
+	if err != nil {
+		return nil, t.c.d.ErrorMap(err)
+	}
+	return rows, nil
+}
+
+func (t *Tx) execWriter(ctx context.Context, w *SQLWriter) error {
+	if t.committed {
+		return ErrTxCommitted
+	}
+	query, params := w.toSQL()
+	_, err := t.exec(ctx, sqlQuery{query, params})
+	return err
+}
+
+// PutAttachment reads r into the named attachment of the document, replacing
+// any existing attachment with the same name.
+//
+// The data is written in chunks as it is read, so at most one chunk is held
+// in memory, and reading more than the MaxAttachmentSize of the client
+// returns ErrAttachmentTooLarge.
+func (t *Tx) PutAttachment(ctx context.Context, kind, id, name, contentType string, r io.Reader) (*Attachment, error) {
+	if t.readOnly {
+		return nil, ErrReadOnlyMode
+	}
+	if name == "" || len(name) > maxAttachmentName {
+		return nil, fmt.Errorf("attachment name is invalid: %q", name)
+	}
+
+	var count int
+	err := t.c.Query(kind).Get(id).Count().First(ctx, t, &count)
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrIDNotFound
+	}
+
+	// data larger than a chunk is written under a random key, which is
+	// replaced by its hash once all of it is read
+	var key string
+	deleteKey := func() {
+		if key != "" {
+			t.deleteChunks(ctx, key)
+		}
+	}
+
+	h := sha256.New()
+	r = io.TeeReader(io.LimitReader(r, t.c.maxAttachmentSize+1), h)
+	buf := make([]byte, attachmentChunkSize)
+	var size int64
+	var last []byte
+	for seq := 0; ; seq++ {
+		n, err := io.ReadFull(r, buf)
+		done := err == io.EOF || err == io.ErrUnexpectedEOF
+		if err != nil && !done {
+			deleteKey()
+			return nil, err
+		}
+		size += int64(n)
+		if size > t.c.maxAttachmentSize {
+			deleteKey()
+			return nil, ErrAttachmentTooLarge
+		}
+		if done && key == "" {
+			last = buf[:n]
+			break
+		}
+
+		if key == "" {
+			if key, err = randomKey(); err != nil {
+				return nil, err
+			}
+		}
+		if n > 0 {
+			if err := t.insertChunk(ctx, key, seq, buf[:n]); err != nil {
+				deleteKey()
+				return nil, err
+			}
+		}
+		if done {
+			break
+		}
+	}
+
+	a := &Attachment{
+		Name:        name,
+		ContentType: contentType,
+		Size:        size,
+		SHA256:      hex.EncodeToString(h.Sum(nil)),
+	}
+
+	if err := t.DeleteAttachment(ctx, kind, id, name); err != nil && err != ErrNotFound {
+		deleteKey()
+		return nil, err
+	}
+
+	if err := t.putBlob(ctx, a, key, last); err != nil {
+		deleteKey()
+		return nil, err
+	}
+
+	w := newSQLWriter(t.c.d, t.c.table)
+	w.WriteString("INSERT INTO " + attachmentsTable(t.c.table))
+	w.WriteString(" (kind, id, name, content_type, size, sha256) VALUES (?, ?, ?, ?, ?, ?)")
+	w.AddParams(kind, id, a.Name, a.ContentType, a.Size, a.SHA256)
+	if err := t.execWriter(ctx, w); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// putBlob stores the data of a once, either the chunks written under key or,
+// when key is empty, data as its only chunk.
+func (t *Tx) putBlob(ctx context.Context, a *Attachment, key string, data []byte) error {
+	var count int
+	w := newSQLWriter(t.c.d, t.c.table)
+	w.WriteString("SELECT count(*) FROM " + blobsTable(t.c.table) + " WHERE sha256 = ?")
+	w.AddParams(a.SHA256)
+	if err := t.scanRow(ctx, w, &count); err != nil {
+		return t.c.d.ErrorMap(err)
+	}
+
+	if count > 0 {
+		if key != "" {
+			return t.deleteChunks(ctx, key)
+		}
+		return nil
+	}
+
+	if key != "" {
+		w = newSQLWriter(t.c.d, t.c.table)
+		w.WriteString("UPDATE " + blobChunksTable(t.c.table) + " SET sha256 = ? WHERE sha256 = ?")
+		w.AddParams(a.SHA256, key)
+		if err := t.execWriter(ctx, w); err != nil {
+			return err
+		}
+	} else if len(data) > 0 {
+		if err := t.insertChunk(ctx, a.SHA256, 0, data); err != nil {
+			return err
+		}
+	}
+
+	w = newSQLWriter(t.c.d, t.c.table)
+	w.WriteString("INSERT INTO " + blobsTable(t.c.table) + " (sha256, size) VALUES (?, ?)")
+	w.AddParams(a.SHA256, a.Size)
+	return t.execWriter(ctx, w)
+}
+
+func (t *Tx) insertChunk(ctx context.Context, hash string, seq int, data []byte) error {
+	w := newSQLWriter(t.c.d, t.c.table)
+	w.WriteString("INSERT INTO " + blobChunksTable(t.c.table) + " (sha256, seq, data) VALUES (?, ?, ?)")
+	w.AddParams(hash, seq, data)
+	return t.execWriter(ctx, w)
+}
+
+func (t *Tx) deleteChunks(ctx context.Context, hash string) error {
+	w := newSQLWriter(t.c.d, t.c.table)
+	w.WriteString("DELETE FROM " + blobChunksTable(t.c.table) + " WHERE sha256 = ?")
+	w.AddParams(hash)
+	return t.execWriter(ctx, w)
+}
+
+// randomKey returns a random hex key with the length of a SHA256 hash.
+func randomKey() (string, error) {
+	b := make([]byte, sha256.Size)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// GetAttachment writes the data of the named attachment to w, one chunk at a
+// time.
+func (t *Tx) GetAttachment(ctx context.Context, kind, id, name string, w io.Writer) (*Attachment, error) {
+	a := &Attachment{Name: name}
+
+	sw := newSQLWriter(t.c.d, t.c.table)
+	sw.WriteString("SELECT content_type, size, sha256 FROM " + attachmentsTable(t.c.table))
+	sw.WriteString(" WHERE kind = ? AND id = ? AND name = ?")
+	sw.AddParams(kind, id, name)
+
+	err := t.scanRow(ctx, sw, &a.ContentType, &a.Size, &a.SHA256)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	} else if err != nil {
+		return nil, t.c.d.ErrorMap(err)
+	}
+
+	sw = newSQLWriter(t.c.d, t.c.table)
+	sw.WriteString("SELECT data FROM " + blobChunksTable(t.c.table) + " WHERE sha256 = ? ORDER BY seq ASC")
+	sw.AddParams(a.SHA256)
+
+	rows, err := t.queryWriter(ctx, sw)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data sql.RawBytes
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		if _, err := w.Write(data); err != nil {
+			return nil, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return a, rows.Close()
+}
+
+// ListAttachments returns the attachments of the document ordered by name.
+func (t *Tx) ListAttachments(ctx context.Context, kind, id string) ([]Attachment, error) {
+	w := newSQLWriter(t.c.d, t.c.table)
+	w.WriteString("SELECT name, content_type, size, sha256 FROM " + attachmentsTable(t.c.table))
+	w.WriteString(" WHERE kind = ? AND id = ? ORDER BY name ASC")
+	w.AddParams(kind, id)
+
+	rows, err := t.queryWriter(ctx, w)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []Attachment
+	for rows.Next() {
+		var a Attachment
+		if err := rows.Scan(&a.Name, &a.ContentType, &a.Size, &a.SHA256); err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+// DeleteAttachment removes the named attachment, and its data when no other
+// attachment shares it.
+func (t *Tx) DeleteAttachment(ctx context.Context, kind, id, name string) error {
+	if t.readOnly {
+		return ErrReadOnlyMode
+	}
+
+	var hash string
+	w := newSQLWriter(t.c.d, t.c.table)
+	w.WriteString("SELECT sha256 FROM " + attachmentsTable(t.c.table) + " WHERE kind = ? AND id = ? AND name = ?")
+	w.AddParams(kind, id, name)
+	err := t.scanRow(ctx, w, &hash)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	} else if err != nil {
+		return t.c.d.ErrorMap(err)
+	}
+
+	w = newSQLWriter(t.c.d, t.c.table)
+	w.WriteString("DELETE FROM " + attachmentsTable(t.c.table) + " WHERE kind = ? AND id = ? AND name = ?")
+	w.AddParams(kind, id, name)
+	if err := t.execWriter(ctx, w); err != nil {
+		return err
+	}
+
+	return deleteBlobs(ctx, t, t.c.table, []string{hash})
+}
+
+// deleteBlobs removes the data for the given hashes that is no longer used by
+// any attachment.
+func deleteBlobs(ctx context.Context, tx *Tx, table string, hashes []string) error {
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	for _, t := range []string{blobChunksTable(table), blobsTable(table)} {
+		w := newSQLWriter(tx.c.d, table)
+		w.WriteString("DELETE FROM " + t + " WHERE sha256 IN (" + placeholders(len(hashes)) + ")")
+		w.WriteString(" AND NOT EXISTS (SELECT 1 FROM " + attachmentsTable(table) + " a")
+		w.WriteString(" WHERE a.sha256 = " + t + ".sha256)")
+		for _, hash := range hashes {
+			w.AddParams(hash)
+		}
+		if err := tx.execWriter(ctx, w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteAttachments removes the attachments, and unused data, of documents
+// matching wb.
+func deleteAttachments(ctx context.Context, tx *Tx, q *Query, wb *WhereBuilder) error {
+	table := attachmentsTable(q.table)
+
+	w := newSQLWriter(q.d, q.table)
+	w.WriteString("SELECT DISTINCT sha256 FROM " + table + " WHERE EXISTS (SELECT 1 FROM " + q.table + " ")
+	if err := wb.toWhereSQL(w); err != nil {
+		return err
+	}
+	w.WriteString(" AND " + q.table + ".kind = " + table + ".kind AND " + q.table + ".id = " + table + ".id)")
+
+	rows, err := tx.queryWriter(ctx, w)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var hashes []string
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return err
+		}
+		hashes = append(hashes, hash)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+
+	if err := deleteSide(ctx, tx, q, wb, table); err != nil {
+		return err
+	}
+	return deleteBlobs(ctx, tx, q.table, hashes)
+}
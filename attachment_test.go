@@ -0,0 +1,214 @@
+package jdb
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestTx_PutAttachment(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	hash := "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) AS count FROM jdb WHERE ((kind = ?) AND (id = ?))")).
+		WithArgs("user", "1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT sha256 FROM jdb_attachments WHERE kind = ? AND id = ? AND name = ?")).
+		WithArgs("user", "1", "avatar").
+		WillReturnRows(sqlmock.NewRows([]string{"sha256"}))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM jdb_blobs WHERE sha256 = ?")).
+		WithArgs(hash).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jdb_blob_chunks (sha256, seq, data) VALUES (?, ?, ?)")).
+		WithArgs(hash, 0, []byte("hello")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jdb_blobs (sha256, size) VALUES (?, ?)")).
+		WithArgs(hash, int64(5)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jdb_attachments (kind, id, name, content_type, size, sha256) "+
+		"VALUES (?, ?, ?, ?, ?, ?)")).
+		WithArgs("user", "1", "avatar", "text/plain", int64(5), hash).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) AS count FROM jdb")).
+		WithArgs("user", "2").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) AS count FROM jdb")).
+		WithArgs("user", "1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectCommit()
+
+	ctx := context.Background()
+
+	require.NoError(t, c.Tx(ctx, func(tx *Tx) error {
+		a, err := tx.PutAttachment(ctx, "user", "1", "avatar", "text/plain", strings.NewReader("hello"))
+		require.NoError(t, err)
+		require.Equal(t, &Attachment{Name: "avatar", ContentType: "text/plain", Size: 5, SHA256: hash}, a)
+
+		_, err = tx.PutAttachment(ctx, "user", "2", "avatar", "text/plain", strings.NewReader("hello"))
+		require.Equal(t, ErrIDNotFound, err)
+
+		c.maxAttachmentSize = 4
+		_, err = tx.PutAttachment(ctx, "user", "1", "avatar", "text/plain", strings.NewReader("hello"))
+		require.Equal(t, ErrAttachmentTooLarge, err)
+
+		_, err = tx.PutAttachment(ctx, "user", "1", "", "text/plain", strings.NewReader("hello"))
+		require.EqualError(t, err, `attachment name is invalid: ""`)
+
+		return tx.Commit()
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTx_PutAttachmentChunks(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	data := bytes.Repeat([]byte("a"), attachmentChunkSize+1)
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) AS count FROM jdb WHERE ((kind = ?) AND (id = ?))")).
+		WithArgs("user", "1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jdb_blob_chunks (sha256, seq, data) VALUES (?, ?, ?)")).
+		WithArgs(sqlmock.AnyArg(), 0, data[:attachmentChunkSize]).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jdb_blob_chunks (sha256, seq, data) VALUES (?, ?, ?)")).
+		WithArgs(sqlmock.AnyArg(), 1, []byte("a")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT sha256 FROM jdb_attachments WHERE kind = ? AND id = ? AND name = ?")).
+		WithArgs("user", "1", "avatar").
+		WillReturnRows(sqlmock.NewRows([]string{"sha256"}))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) FROM jdb_blobs WHERE sha256 = ?")).
+		WithArgs(hash).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE jdb_blob_chunks SET sha256 = ? WHERE sha256 = ?")).
+		WithArgs(hash, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jdb_blobs (sha256, size) VALUES (?, ?)")).
+		WithArgs(hash, int64(len(data))).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jdb_attachments")).
+		WithArgs("user", "1", "avatar", "text/plain", int64(len(data)), hash).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT count(*) AS count FROM jdb")).
+		WithArgs("user", "1").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jdb_blob_chunks (sha256, seq, data) VALUES (?, ?, ?)")).
+		WithArgs(sqlmock.AnyArg(), 0, data[:attachmentChunkSize]).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM jdb_blob_chunks WHERE sha256 = ?")).
+		WithArgs(sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ctx := context.Background()
+
+	require.NoError(t, c.Tx(ctx, func(tx *Tx) error {
+		a, err := tx.PutAttachment(ctx, "user", "1", "avatar", "text/plain", bytes.NewReader(data))
+		require.NoError(t, err)
+		require.Equal(t, int64(len(data)), a.Size)
+		require.Equal(t, hash, a.SHA256)
+
+		c.maxAttachmentSize = attachmentChunkSize
+		_, err = tx.PutAttachment(ctx, "user", "1", "avatar", "text/plain", bytes.NewReader(data))
+		require.Equal(t, ErrAttachmentTooLarge, err)
+
+		return tx.Commit()
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTx_GetAttachment(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT content_type, size, sha256 FROM jdb_attachments "+
+		"WHERE kind = ? AND id = ? AND name = ?")).
+		WithArgs("user", "1", "avatar").
+		WillReturnRows(sqlmock.NewRows([]string{"content_type", "size", "sha256"}).
+			AddRow("text/plain", 5, "abc"))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT data FROM jdb_blob_chunks WHERE sha256 = ? ORDER BY seq ASC")).
+		WithArgs("abc").
+		WillReturnRows(sqlmock.NewRows([]string{"data"}).AddRow([]byte("hel")).AddRow([]byte("lo")))
+	mock.ExpectQuery("SELECT content_type").
+		WithArgs("user", "1", "missing").
+		WillReturnRows(sqlmock.NewRows([]string{"content_type", "size", "sha256"}))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT name, content_type, size, sha256 FROM jdb_attachments "+
+		"WHERE kind = ? AND id = ? ORDER BY name ASC")).
+		WithArgs("user", "1").
+		WillReturnRows(sqlmock.NewRows([]string{"name", "content_type", "size", "sha256"}).
+			AddRow("avatar", "text/plain", 5, "abc"))
+	mock.ExpectRollback()
+
+	ctx := context.Background()
+
+	require.NoError(t, c.Tx(ctx, func(tx *Tx) error {
+		b := &bytes.Buffer{}
+		a, err := tx.GetAttachment(ctx, "user", "1", "avatar", b)
+		require.NoError(t, err)
+		require.Equal(t, &Attachment{Name: "avatar", ContentType: "text/plain", Size: 5, SHA256: "abc"}, a)
+		require.Equal(t, "hello", b.String())
+
+		_, err = tx.GetAttachment(ctx, "user", "1", "missing", b)
+		require.Equal(t, ErrNotFound, err)
+
+		attachments, err := tx.ListAttachments(ctx, "user", "1")
+		require.NoError(t, err)
+		require.Equal(t, []Attachment{{Name: "avatar", ContentType: "text/plain", Size: 5, SHA256: "abc"}},
+			attachments)
+
+		return nil
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTx_DeleteAttachment(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT sha256 FROM jdb_attachments").
+		WithArgs("user", "1", "avatar").
+		WillReturnRows(sqlmock.NewRows([]string{"sha256"}).AddRow("abc"))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM jdb_attachments WHERE kind = ? AND id = ? AND name = ?")).
+		WithArgs("user", "1", "avatar").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM jdb_blob_chunks WHERE sha256 IN (?) AND NOT EXISTS " +
+		"(SELECT 1 FROM jdb_attachments a WHERE a.sha256 = jdb_blob_chunks.sha256)")).
+		WithArgs("abc").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM jdb_blobs WHERE sha256 IN (?) AND NOT EXISTS " +
+		"(SELECT 1 FROM jdb_attachments a WHERE a.sha256 = jdb_blobs.sha256)")).
+		WithArgs("abc").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT sha256 FROM jdb_attachments").
+		WithArgs("user", "1", "avatar").
+		WillReturnRows(sqlmock.NewRows([]string{"sha256"}))
+	mock.ExpectCommit()
+
+	ctx := context.Background()
+
+	require.NoError(t, c.Tx(ctx, func(tx *Tx) error {
+		require.NoError(t, tx.DeleteAttachment(ctx, "user", "1", "avatar"))
+		require.Equal(t, ErrNotFound, tx.DeleteAttachment(ctx, "user", "1", "avatar"))
+		return tx.Commit()
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,23 +3,22 @@ package jdb
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
 	"fmt"
-	"reflect"
+	"time"
 
 	"github.com/silas/jdb/dialect"
 )
 
-var stringValue reflect.Value
-
-func init() {
-	stringValue = reflect.ValueOf("")
-}
-
 type Client struct {
 	d        dialect.Dialect
 	db       *sql.DB
 	table    string
 	readOnly bool
+	kinds    *kindRegistry
+	retry    RetryPolicy
+
+	maxAttachmentSize int64
 
 	ID              SelectWhereColumn
 	Kind            SelectWhereColumn
@@ -37,6 +36,8 @@ type Client struct {
 func Open(driverName, dataSourceName string, opts ...Option) (*Client, error) {
 	table := "jdb"
 	readOnly := false
+	var retry RetryPolicy
+	maxAttachmentSize := int64(defaultMaxAttachmentSize)
 
 	for _, opt := range opts {
 		switch v := opt.(type) {
@@ -47,6 +48,13 @@ func Open(driverName, dataSourceName string, opts ...Option) (*Client, error) {
 			table = v.table
 		case optionReadOnly:
 			readOnly = v.readOnly
+		case optionTxRetry:
+			retry = v.policy
+		case optionMaxAttachmentSize:
+			if v.size < 1 {
+				return nil, fmt.Errorf("jdb: invalid max attachment size: %d", v.size)
+			}
+			maxAttachmentSize = v.size
 		default:
 			panic("unknown option")
 		}
@@ -75,6 +83,10 @@ func Open(driverName, dataSourceName string, opts ...Option) (*Client, error) {
 		db:       db,
 		table:    table,
 		readOnly: readOnly,
+		kinds:    newKindRegistry(),
+		retry:    retry,
+
+		maxAttachmentSize: maxAttachmentSize,
 
 		ID:              idField,
 		ParentKind:      parentKindField,
@@ -92,11 +104,15 @@ func Open(driverName, dataSourceName string, opts ...Option) (*Client, error) {
 }
 
 func (c *Client) Migrate(ctx context.Context) error {
-	return c.d.Migrate(ctx, c.db, c.table)
+	err := c.d.Migrate(ctx, c.db, c.table)
+	if err != nil {
+		return err
+	}
+	return c.migratePathIndexes(ctx)
 }
 
 func (c *Client) Path(key ...string) *PathField {
-	p := &PathField{c.d.Path()}
+	p := &PathField{p: c.d.Path()}
 	if len(key) > 0 {
 		for _, v := range key {
 			p.Key(v)
@@ -106,23 +122,137 @@ func (c *Client) Path(key ...string) *PathField {
 }
 
 func (c *Client) Query(kind string) *Query {
-	return newQuery(c.d, c.table, kind)
+	q := newQuery(c.d, c.table, kind)
+	q.kinds = c.kinds
+	return q
+}
+
+// TxOptions configures a transaction started with TxWith.
+type TxOptions struct {
+	// Isolation is the isolation level, which defaults to serializable.
+	Isolation sql.IsolationLevel
+	// ReadOnly makes writes fail with ErrReadOnlyMode, it is always set for
+	// read-only clients.
+	ReadOnly bool
+	// Timeout limits the duration of each statement on postgres, with
+	// statement_timeout, and of each SELECT on mysql, with
+	// max_execution_time. On sqlite3 it limits the whole transaction, which
+	// is rolled back when it expires and fails with ErrTxTimeout.
+	Timeout time.Duration
+	// Label identifies the transaction, see Tx.Label.
+	Label string
 }
 
+// Tx runs fn in a serializable transaction, which is rolled back unless fn
+// commits it. With the TxRetry option fn is run again when it fails with a
+// retryable error before committing.
 func (c *Client) Tx(ctx context.Context, fn func(*Tx) error) error {
-	opts := &sql.TxOptions{
-		Isolation: sql.LevelSerializable,
-		ReadOnly:  c.readOnly,
+	return c.TxWith(ctx, TxOptions{}, fn)
+}
+
+// TxWith is like Tx but runs fn in a transaction configured by opts.
+func (c *Client) TxWith(ctx context.Context, opts TxOptions, fn func(*Tx) error) error {
+	for attempt := 1; ; attempt++ {
+		committed, err := c.tx(ctx, opts, fn)
+		if err == nil || committed || attempt >= c.retry.MaxAttempts || !IsRetryable(err) {
+			return err
+		}
+		if c.retry.OnRetry != nil {
+			c.retry.OnRetry(attempt, err)
+		}
+		if err := c.retry.wait(ctx, attempt); err != nil {
+			return err
+		}
 	}
-	tx, err := c.db.BeginTx(ctx, opts)
-	if err != nil {
-		return err
+}
+
+// Update runs fn in a transaction, like Tx, which is committed when fn
+// returns nil.
+func (c *Client) Update(ctx context.Context, fn func(*Tx) error) error {
+	return c.Tx(ctx, func(tx *Tx) error {
+		if err := fn(tx); err != nil {
+			return err
+		}
+		if tx.committed {
+			return nil
+		}
+		return tx.Commit()
+	})
+}
+
+// View runs fn in a read-only transaction, writes return ErrReadOnlyMode.
+func (c *Client) View(ctx context.Context, fn func(*Tx) error) error {
+	return c.TxWith(ctx, TxOptions{ReadOnly: true}, fn)
+}
+
+func (c *Client) tx(ctx context.Context, opts TxOptions, fn func(*Tx) error) (bool, error) {
+	txOpts := &sql.TxOptions{
+		Isolation: opts.Isolation,
+		ReadOnly:  c.readOnly || opts.ReadOnly,
+	}
+	if txOpts.Isolation == sql.LevelDefault {
+		txOpts.Isolation = sql.LevelSerializable
+	}
+
+	var setTimeout, resetTimeout string
+	var timeoutCtx context.Context
+	if opts.Timeout > 0 {
+		setTimeout, resetTimeout = c.d.StatementTimeout(opts.Timeout)
+		if setTimeout == "" {
+			var cancel context.CancelFunc
+			timeoutCtx, cancel = context.WithTimeout(ctx, opts.Timeout)
+			defer cancel()
+		}
+	}
+	parentCtx := ctx
+	if timeoutCtx != nil {
+		ctx = timeoutCtx
+	}
+
+	var tx *sql.Tx
+	var err error
+	if resetTimeout != "" {
+		// the timeout outlives the transaction, so it is reset before the
+		// connection is returned to the pool
+		conn, err := c.db.Conn(ctx)
+		if err != nil {
+			return false, c.d.ErrorMap(err)
+		}
+		defer func() {
+			if _, err := conn.ExecContext(context.Background(), resetTimeout); err != nil {
+				// discard the connection rather than pool it with the timeout
+				conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+			}
+			conn.Close()
+		}()
+		tx, err = conn.BeginTx(ctx, txOpts)
+		if err != nil {
+			return false, c.d.ErrorMap(err)
+		}
+	} else {
+		tx, err = c.db.BeginTx(ctx, txOpts)
+		if err != nil {
+			return false, c.d.ErrorMap(err)
+		}
 	}
 	defer tx.Rollback()
 
-	t := &Tx{c: c, tx: tx}
+	if setTimeout != "" {
+		if _, err := tx.ExecContext(ctx, setTimeout); err != nil {
+			return false, c.d.ErrorMap(err)
+		}
+	}
 
-	return fn(t)
+	t := &Tx{c: c, tx: tx, sqlTx: tx, readOnly: txOpts.ReadOnly, label: opts.Label}
+
+	err = fn(t)
+	if err != nil && timeoutCtx != nil && parentCtx.Err() == nil &&
+		timeoutCtx.Err() == context.DeadlineExceeded {
+		// the expired context rolled the transaction back, which otherwise
+		// surfaces as a closed transaction
+		err = ErrTxTimeout
+	}
+	return t.committed, err
 }
 
 func (c *Client) Close() error {
@@ -57,11 +57,29 @@ func TestOpen_Option_Table(t *testing.T) {
 	require.EqualError(t, err, "jdb: invalid table name")
 }
 
+func TestOpen_Option_MaxAttachmentSize(t *testing.T) {
+	c, err := Open("sqlmock", "test")
+	require.NoError(t, err)
+	require.Equal(t, int64(16<<20), c.maxAttachmentSize)
+
+	c, err = Open("sqlmock", "test", MaxAttachmentSize(1024))
+	require.NoError(t, err)
+	require.Equal(t, int64(1024), c.maxAttachmentSize)
+
+	_, err = Open("sqlmock", "test", MaxAttachmentSize(0))
+	require.EqualError(t, err, "jdb: invalid max attachment size: 0")
+}
+
 func TestClient_Migrate(t *testing.T) {
 	c, mock := createMockClient(t)
 	defer c.Close()
 
 	jdbsqlmock.ExpectRun(mock)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM jdb WHERE").
+		WithArgs("jdb", "path_indexes").
+		WillReturnRows(sqlmock.NewRows([]string{"kind", "id", "parent_kind", "parent_id", "data"}))
+	mock.ExpectRollback()
 
 	require.NoError(t, c.Migrate(context.Background()))
 	require.NoError(t, mock.ExpectationsWereMet())
@@ -71,10 +89,10 @@ func TestClient_Path(t *testing.T) {
 	c, mock := createMockClient(t)
 	defer c.Close()
 
-	require.Equal(t, c.Path("one").toWhereField(), "data->'$.one'")
-	require.Equal(t, c.Path("one", "two").toWhereField(), "data->'$.one.two'")
-	require.Equal(t, c.Path("one").Key("two").toWhereField(), "data->'$.one.two'")
-	require.Equal(t, c.Path("one").Index(2).toWhereField(), "data->'$.one[2]'")
+	require.Equal(t, whereFieldSQL(t, c.Path("one")), "data->'$.one'")
+	require.Equal(t, whereFieldSQL(t, c.Path("one", "two")), "data->'$.one.two'")
+	require.Equal(t, whereFieldSQL(t, c.Path("one").Key("two")), "data->'$.one.two'")
+	require.Equal(t, whereFieldSQL(t, c.Path("one").Index(2)), "data->'$.one[2]'")
 
 	require.NoError(t, mock.ExpectationsWereMet())
 }
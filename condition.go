@@ -1,9 +1,11 @@
 package jdb
 
 import (
-	"bytes"
 	"fmt"
 	"strings"
+
+	"github.com/silas/jdb/dialect"
+	"github.com/silas/jdb/internal/json"
 )
 
 const (
@@ -11,47 +13,78 @@ const (
 	falseCondition = "(1 != 1)"
 )
 
+type Condition interface {
+	ConditionSQL(w *SQLWriter) error
+}
+
 type expr struct {
 	value string
 }
 
-func (c expr) toConditionSQL(query *bytes.Buffer, params *[]interface{}) error {
-	query.WriteString(c.value)
+func (c expr) ConditionSQL(w *SQLWriter) error {
+	w.WriteString(c.value)
 	return nil
 }
 
-type Condition interface {
-	toConditionSQL(sql *bytes.Buffer, params *[]interface{}) error
+type raw struct {
+	sql  string
+	args []interface{}
+}
+
+// Raw returns a condition using the given SQL, which should use "?" for
+// placeholders. Every "?" is a placeholder, including those in string
+// literals and operators such as the postgres "?|", so they must be passed
+// as args instead.
+func Raw(sql string, args ...interface{}) Condition {
+	return raw{sql, args}
+}
+
+func (c raw) ConditionSQL(w *SQLWriter) error {
+	if err := checkRawArgs(c.sql, c.args); err != nil {
+		return err
+	}
+	w.WriteString("(")
+	w.WriteString(c.sql)
+	w.WriteString(")")
+	w.AddParams(c.args...)
+	return nil
+}
+
+func checkRawArgs(sql string, args []interface{}) error {
+	if n := strings.Count(sql, "?"); n != len(args) {
+		return fmt.Errorf("raw: expected %d args, got %d: %s", n, len(args), sql)
+	}
+	return nil
 }
 
 type conj []Condition
 
-func (c conj) join(query *bytes.Buffer, params *[]interface{}, sep string) error {
+func (c conj) join(w *SQLWriter, sep string) error {
 	if len(c) > 0 {
-		query.WriteString("(")
+		w.WriteString("(")
 		for i, queryBuilder := range c {
 			if i > 0 {
-				query.WriteString(sep)
+				w.WriteString(sep)
 			}
 			if queryBuilder == nil {
 				return fmt.Errorf("%s: nil condition: %v", strings.TrimSpace(sep), c)
 			}
-			err := queryBuilder.toConditionSQL(query, params)
+			err := queryBuilder.ConditionSQL(w)
 			if err != nil {
 				return err
 			}
 		}
-		query.WriteString(")")
+		w.WriteString(")")
 	} else {
-		query.WriteString(trueCondition)
+		w.WriteString(trueCondition)
 	}
 	return nil
 }
 
 type and conj
 
-func (c and) toConditionSQL(query *bytes.Buffer, params *[]interface{}) error {
-	return conj(c).join(query, params, " AND ")
+func (c and) ConditionSQL(w *SQLWriter) error {
+	return conj(c).join(w, " AND ")
 }
 
 func And(args ...Condition) Condition {
@@ -60,8 +93,8 @@ func And(args ...Condition) Condition {
 
 type or conj
 
-func (c or) toConditionSQL(query *bytes.Buffer, params *[]interface{}) error {
-	return conj(c).join(query, params, " OR ")
+func (c or) ConditionSQL(w *SQLWriter) error {
+	return conj(c).join(w, " OR ")
 }
 
 func Or(args ...Condition) Condition {
@@ -76,6 +109,47 @@ func False() Condition {
 	return expr{falseCondition}
 }
 
+// writeComparison writes "(field op ?)" or, when value is nil,
+// "(field nullOp)".
+func writeComparison(w *SQLWriter, f WhereField, op, nullOp string, value interface{}) error {
+	if kf, ok := f.(IndexField); ok {
+		if value == nil {
+			return kf.comparisonSQL(w, nullOp, nil)
+		}
+		return kf.comparisonSQL(w, op, []interface{}{value})
+	}
+
+	w.WriteString("(")
+	if err := w.WriteField(f); err != nil {
+		return err
+	}
+	if value != nil {
+		w.WriteString(" " + op + " ")
+		w.WriteParam(value)
+	} else {
+		w.WriteString(" " + nullOp)
+	}
+	w.WriteString(")")
+	return nil
+}
+
+// writeList writes "(field op (?, ?, ...))".
+func writeList(w *SQLWriter, f WhereField, op string, values []interface{}) error {
+	if kf, ok := f.(IndexField); ok {
+		return kf.comparisonSQL(w, op, values)
+	}
+
+	w.WriteString("(")
+	if err := w.WriteField(f); err != nil {
+		return err
+	}
+	w.WriteString(" " + op + " (")
+	w.WriteString(placeholders(len(values)))
+	w.WriteString("))")
+	w.AddParams(values...)
+	return nil
+}
+
 type eq struct {
 	field WhereField
 	value interface{}
@@ -85,14 +159,8 @@ func Eq(f WhereField, v interface{}) Condition {
 	return eq{f, v}
 }
 
-func (c eq) toConditionSQL(query *bytes.Buffer, params *[]interface{}) error {
-	if c.value != nil {
-		query.WriteString(fmt.Sprintf("(%s = ?)", c.field.toWhereField()))
-		*params = append(*params, c.value)
-	} else {
-		query.WriteString(fmt.Sprintf("(%s IS NULL)", c.field.toWhereField()))
-	}
-	return nil
+func (c eq) ConditionSQL(w *SQLWriter) error {
+	return writeComparison(w, c.field, "=", "IS NULL", c.value)
 }
 
 type notEq struct {
@@ -104,14 +172,8 @@ func NotEq(f WhereField, v interface{}) Condition {
 	return notEq{f, v}
 }
 
-func (c notEq) toConditionSQL(query *bytes.Buffer, params *[]interface{}) error {
-	if c.value != nil {
-		query.WriteString(fmt.Sprintf("(%s != ?)", c.field.toWhereField()))
-		*params = append(*params, c.value)
-	} else {
-		query.WriteString(fmt.Sprintf("(%s IS NOT NULL)", c.field.toWhereField()))
-	}
-	return nil
+func (c notEq) ConditionSQL(w *SQLWriter) error {
+	return writeComparison(w, c.field, "!=", "IS NOT NULL", c.value)
 }
 
 type in struct {
@@ -123,7 +185,7 @@ func In(f WhereField, v ...interface{}) Condition {
 	return in{f, v}
 }
 
-func (c in) toConditionSQL(query *bytes.Buffer, params *[]interface{}) error {
+func (c in) ConditionSQL(w *SQLWriter) error {
 	hasNil := false
 	var value []interface{}
 	for _, v := range c.value {
@@ -137,33 +199,34 @@ func (c in) toConditionSQL(query *bytes.Buffer, params *[]interface{}) error {
 	hasValues := len(value) > 0
 
 	if !hasNil && !hasValues {
-		query.WriteString(falseCondition)
+		w.WriteString(falseCondition)
 		return nil
 	}
 
 	if hasNil && hasValues {
-		query.WriteString("(")
+		w.WriteString("(")
 	}
 	if hasNil {
-		if err := Eq(c.field, nil).toConditionSQL(query, params); err != nil {
+		if err := Eq(c.field, nil).ConditionSQL(w); err != nil {
 			return err
 		}
 		if !hasValues {
 			return nil
 		}
 	} else if !hasValues {
-		query.WriteString(falseCondition)
+		w.WriteString(falseCondition)
 		return nil
 	}
 	if hasNil && hasValues {
-		query.WriteString(" OR ")
+		w.WriteString(" OR ")
 	}
 	if hasValues {
-		query.WriteString(fmt.Sprintf("(%s IN (%s))", c.field.toWhereField(), placeholders(len(value))))
-		*params = append(*params, value...)
+		if err := writeList(w, c.field, "IN", value); err != nil {
+			return err
+		}
 	}
 	if hasNil && hasValues {
-		query.WriteString(")")
+		w.WriteString(")")
 	}
 
 	return nil
@@ -178,7 +241,7 @@ func NotIn(f WhereField, v ...interface{}) Condition {
 	return notIn{f, v}
 }
 
-func (c notIn) toConditionSQL(query *bytes.Buffer, params *[]interface{}) error {
+func (c notIn) ConditionSQL(w *SQLWriter) error {
 	hasNil := false
 	var value []interface{}
 	for _, v := range c.value {
@@ -192,36 +255,102 @@ func (c notIn) toConditionSQL(query *bytes.Buffer, params *[]interface{}) error
 	hasValues := len(value) > 0
 
 	if !hasNil && !hasValues {
-		query.WriteString(trueCondition)
+		w.WriteString(trueCondition)
 		return nil
 	}
 
 	if !hasNil && hasValues {
-		query.WriteString("(")
+		w.WriteString("(")
 	}
 	if !hasNil {
-		if err := Eq(c.field, nil).toConditionSQL(query, params); err != nil {
+		if err := Eq(c.field, nil).ConditionSQL(w); err != nil {
 			return err
 		}
 	} else if !hasValues {
-		if err := NotEq(c.field, nil).toConditionSQL(query, params); err != nil {
+		if err := NotEq(c.field, nil).ConditionSQL(w); err != nil {
 			return err
 		}
 	}
 	if !hasNil && hasValues {
-		query.WriteString(" OR ")
+		w.WriteString(" OR ")
 	}
 	if hasValues {
-		query.WriteString(fmt.Sprintf("(%s NOT IN (%s))", c.field.toWhereField(), placeholders(len(value))))
-		*params = append(*params, value...)
+		if err := writeList(w, c.field, "NOT IN", value); err != nil {
+			return err
+		}
 	}
 	if !hasNil && hasValues {
-		query.WriteString(")")
+		w.WriteString(")")
 	}
 
 	return nil
 }
 
+type contains struct {
+	field PathField
+	value []interface{}
+	not   bool
+}
+
+// Contains matches documents whose value at the path equals one of the
+// values, or is an array containing any of them. Values compare as JSON, so
+// strings don't match numbers. It's the same as In when the dialect doesn't
+// implement dialect.ContainsPath.
+func Contains(f PathField, v ...interface{}) Condition {
+	return contains{f, v, false}
+}
+
+// NotContains matches documents which Contains doesn't match.
+func NotContains(f PathField, v ...interface{}) Condition {
+	return contains{f, v, true}
+}
+
+func (c contains) ConditionSQL(w *SQLWriter) error {
+	cp, ok := c.field.p.(dialect.ContainsPath)
+	if !ok {
+		if c.not {
+			return NotIn(c.field, c.value...).ConditionSQL(w)
+		}
+		return In(c.field, c.value...).ConditionSQL(w)
+	}
+
+	if len(c.value) == 0 {
+		if c.not {
+			w.WriteString(trueCondition)
+		} else {
+			w.WriteString(falseCondition)
+		}
+		return nil
+	}
+
+	if c.not {
+		w.WriteString("(NOT ")
+	}
+	w.WriteString("(")
+	for i, v := range c.value {
+		if i > 0 {
+			w.WriteString(" OR ")
+		}
+		if v == nil {
+			if err := Eq(c.field, nil).ConditionSQL(w); err != nil {
+				return err
+			}
+			continue
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("contains: %v", err)
+		}
+		w.WriteString(cp.JSONContains("data"))
+		w.AddParams(string(b))
+	}
+	w.WriteString(")")
+	if c.not {
+		w.WriteString(")")
+	}
+	return nil
+}
+
 type like struct {
 	field WhereField
 	value interface{}
@@ -231,14 +360,8 @@ func Like(f WhereField, v interface{}) Condition {
 	return like{f, v}
 }
 
-func (c like) toConditionSQL(query *bytes.Buffer, a *[]interface{}) error {
-	if c.value != nil {
-		query.WriteString(fmt.Sprintf("(%s LIKE ?)", c.field.toWhereField()))
-		*a = append(*a, c.value)
-	} else {
-		query.WriteString(fmt.Sprintf("(%s LIKE NULL)", c.field.toWhereField()))
-	}
-	return nil
+func (c like) ConditionSQL(w *SQLWriter) error {
+	return writeComparison(w, c.field, "LIKE", "LIKE NULL", c.value)
 }
 
 type notLike struct {
@@ -250,14 +373,8 @@ func NotLike(f WhereField, v interface{}) Condition {
 	return notLike{f, v}
 }
 
-func (c notLike) toConditionSQL(query *bytes.Buffer, params *[]interface{}) error {
-	if c.value != nil {
-		query.WriteString(fmt.Sprintf("(%s NOT LIKE ?)", c.field.toWhereField()))
-		*params = append(*params, c.value)
-	} else {
-		query.WriteString(fmt.Sprintf("(%s NOT LIKE NULL)", c.field.toWhereField()))
-	}
-	return nil
+func (c notLike) ConditionSQL(w *SQLWriter) error {
+	return writeComparison(w, c.field, "NOT LIKE", "NOT LIKE NULL", c.value)
 }
 
 type gt struct {
@@ -269,14 +386,8 @@ func Gt(f WhereField, v interface{}) Condition {
 	return gt{f, v}
 }
 
-func (c gt) toConditionSQL(query *bytes.Buffer, params *[]interface{}) error {
-	if c.value != nil {
-		query.WriteString(fmt.Sprintf("(%s > ?)", c.field.toWhereField()))
-		*params = append(*params, c.value)
-	} else {
-		query.WriteString(fmt.Sprintf("(%s > NULL)", c.field.toWhereField()))
-	}
-	return nil
+func (c gt) ConditionSQL(w *SQLWriter) error {
+	return writeComparison(w, c.field, ">", "> NULL", c.value)
 }
 
 type lt struct {
@@ -288,14 +399,8 @@ func Lt(f WhereField, v interface{}) Condition {
 	return lt{f, v}
 }
 
-func (c lt) toConditionSQL(query *bytes.Buffer, params *[]interface{}) error {
-	if c.value != nil {
-		query.WriteString(fmt.Sprintf("(%s < ?)", c.field.toWhereField()))
-		*params = append(*params, c.value)
-	} else {
-		query.WriteString(fmt.Sprintf("(%s < NULL)", c.field.toWhereField()))
-	}
-	return nil
+func (c lt) ConditionSQL(w *SQLWriter) error {
+	return writeComparison(w, c.field, "<", "< NULL", c.value)
 }
 
 type gte struct {
@@ -307,14 +412,8 @@ func Gte(f WhereField, v interface{}) Condition {
 	return gte{f, v}
 }
 
-func (c gte) toConditionSQL(query *bytes.Buffer, params *[]interface{}) error {
-	if c.value != nil {
-		query.WriteString(fmt.Sprintf("(%s >= ?)", c.field.toWhereField()))
-		*params = append(*params, c.value)
-	} else {
-		query.WriteString(fmt.Sprintf("(%s >= NULL)", c.field.toWhereField()))
-	}
-	return nil
+func (c gte) ConditionSQL(w *SQLWriter) error {
+	return writeComparison(w, c.field, ">=", ">= NULL", c.value)
 }
 
 type lte struct {
@@ -326,12 +425,59 @@ func Lte(f WhereField, v interface{}) Condition {
 	return lte{f, v}
 }
 
-func (c lte) toConditionSQL(query *bytes.Buffer, params *[]interface{}) error {
-	if c.value != nil {
-		query.WriteString(fmt.Sprintf("(%s <= ?)", c.field.toWhereField()))
-		*params = append(*params, c.value)
+func (c lte) ConditionSQL(w *SQLWriter) error {
+	return writeComparison(w, c.field, "<=", "<= NULL", c.value)
+}
+
+type hasRelation struct {
+	child bool
+	kind  string
+	where and
+}
+
+// HasChild matches documents with at least one child of the given kind
+// matching all of the conditions.
+func HasChild(kind string, where ...Condition) Condition {
+	return hasRelation{true, kind, where}
+}
+
+// HasParent matches documents whose parent is of the given kind and matches
+// all of the conditions.
+func HasParent(kind string, where ...Condition) Condition {
+	return hasRelation{false, kind, where}
+}
+
+func (c hasRelation) ConditionSQL(w *SQLWriter) error {
+	if w.table == "" {
+		return fmt.Errorf("relation: table not defined")
+	}
+
+	outer := w.Table()
+	sw := w.subquery()
+	inner := sw.Table()
+
+	sw.WriteString("(EXISTS (SELECT 1 FROM ")
+	sw.WriteString(w.table)
+	sw.WriteString(" ")
+	sw.WriteString(inner)
+	sw.WriteString(" WHERE ")
+	sw.WriteString(inner)
+	sw.WriteString(".kind = ")
+	sw.WriteParam(c.kind)
+	if c.child {
+		sw.WriteString(" AND " + inner + ".parent_kind = " + outer + ".kind")
+		sw.WriteString(" AND " + inner + ".parent_id = " + outer + ".id")
 	} else {
-		query.WriteString(fmt.Sprintf("(%s <= NULL)", c.field.toWhereField()))
+		sw.WriteString(" AND " + inner + ".kind = " + outer + ".parent_kind")
+		sw.WriteString(" AND " + inner + ".id = " + outer + ".parent_id")
 	}
+	if len(c.where) > 0 {
+		sw.WriteString(" AND ")
+		if err := c.where.ConditionSQL(sw); err != nil {
+			return err
+		}
+	}
+	sw.WriteString("))")
+
 	return nil
 }
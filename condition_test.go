@@ -1,11 +1,11 @@
 package jdb
 
 import (
-	"bytes"
 	"testing"
 
 	"fmt"
 
+	"github.com/silas/jdb/dialect"
 	"github.com/stretchr/testify/require"
 )
 
@@ -167,6 +167,27 @@ func TestConditions(t *testing.T) {
 			"(numeric_key <= NULL)",
 			params(),
 		},
+		// Raw
+		{
+			Raw("string_key = lower(?)", "A"),
+			"(string_key = lower(?))",
+			params("A"),
+		},
+		{
+			Raw("numeric_key BETWEEN ? AND ?", 1, 2),
+			"(numeric_key BETWEEN ? AND ?)",
+			params(1, 2),
+		},
+		{
+			Eq(RawField("lower(string_key)"), "a"),
+			"(lower(string_key) = ?)",
+			params("a"),
+		},
+		{
+			In(RawField("coalesce(string_key, ?)", "b"), "a", "b"),
+			"(coalesce(string_key, ?) IN (?, ?))",
+			params("b", "a", "b"),
+		},
 		// And
 		{
 			And(),
@@ -204,12 +225,168 @@ func TestConditions(t *testing.T) {
 	for i, test := range tests {
 		msg := fmt.Sprintf("Test: %d", i)
 
-		var params []interface{}
-		query := &bytes.Buffer{}
+		w := newSQLWriter(nil, "table")
+
+		err := test.Condition.ConditionSQL(w)
+		require.NoError(t, err, msg)
+		require.Equal(t, test.Query, w.String(), msg)
+		require.Equal(t, test.Params, *w.params, msg)
+	}
+}
+
+func TestConditions_Raw(t *testing.T) {
+	w := newSQLWriter(nil, "table")
+	err := Raw("id = ?").ConditionSQL(w)
+	require.EqualError(t, err, "raw: expected 1 args, got 0: id = ?")
+
+	w = newSQLWriter(nil, "table")
+	err = Eq(RawField("?", 1, 2), 3).ConditionSQL(w)
+	require.EqualError(t, err, "raw: expected 1 args, got 2: ?")
+}
+
+func TestConditions_Relation(t *testing.T) {
+	tests := []struct {
+		Condition Condition
+		Query     string
+		Params    []interface{}
+	}{
+		{
+			HasChild("comment"),
+			"(EXISTS (SELECT 1 FROM table table_1 WHERE table_1.kind = ? AND " +
+				"table_1.parent_kind = table.kind AND table_1.parent_id = table.id))",
+			params("comment"),
+		},
+		{
+			HasParent("post", Eq(RawField("published"), true)),
+			"(EXISTS (SELECT 1 FROM table table_1 WHERE table_1.kind = ? AND " +
+				"table_1.kind = table.parent_kind AND table_1.id = table.parent_id AND ((published = ?))))",
+			params("post", true),
+		},
+		{
+			Or(Eq(idField, "1"), HasChild("post", HasChild("comment", Eq(idField, "2")))),
+			"((id = ?) OR (EXISTS (SELECT 1 FROM table table_1 WHERE table_1.kind = ? AND " +
+				"table_1.parent_kind = table.kind AND table_1.parent_id = table.id AND " +
+				"((EXISTS (SELECT 1 FROM table table_2 WHERE table_2.kind = ? AND " +
+				"table_2.parent_kind = table_1.kind AND table_2.parent_id = table_1.id AND ((id = ?))))))))",
+			params("1", "post", "comment", "2"),
+		},
+	}
+
+	for i, test := range tests {
+		msg := fmt.Sprintf("Test: %d", i)
+
+		w := newSQLWriter(nil, "table")
+
+		err := test.Condition.ConditionSQL(w)
+		require.NoError(t, err, msg)
+		require.Equal(t, test.Query, w.String(), msg)
+		require.Equal(t, test.Params, *w.params, msg)
+	}
+
+	err := HasChild("comment").ConditionSQL(newSQLWriter(nil, ""))
+	require.EqualError(t, err, "relation: table not defined")
+}
+
+// basicPath implements only dialect.Path.
+type basicPath struct {
+	key string
+}
+
+func (p *basicPath) Key(v string) dialect.Path {
+	p.key = v
+	return p
+}
+
+func (p *basicPath) Index(v int) dialect.Path {
+	return p
+}
+
+func (p *basicPath) JSONExtract(column string) string {
+	return column + "." + p.key
+}
+
+func TestConditions_Contains(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	basic := PathField{p: &basicPath{}}
+	basic.Key("Tags")
+
+	tests := []struct {
+		Condition Condition
+		Query     string
+		Params    []interface{}
+	}{
+		{
+			Contains(*c.Path("Tags")),
+			falseCondition,
+			params(),
+		},
+		{
+			Contains(*c.Path("Tags"), "a", 1, nil),
+			"(JSON_CONTAINS(data->'$.Tags', ?) OR JSON_CONTAINS(data->'$.Tags', ?) OR (data->'$.Tags' IS NULL))",
+			params(`"a"`, "1"),
+		},
+		{
+			NotContains(*c.Path("Tags")),
+			trueCondition,
+			params(),
+		},
+		{
+			NotContains(*c.Path("Tags"), "a"),
+			"(NOT (JSON_CONTAINS(data->'$.Tags', ?)))",
+			params(`"a"`),
+		},
+		{
+			Contains(basic, "a", 1),
+			"(data.Tags IN (?, ?))",
+			params("a", 1),
+		},
+		{
+			Gt(basic.Numeric(), 1),
+			"(data.Tags > ?)",
+			params(1),
+		},
+	}
+
+	for i, test := range tests {
+		msg := fmt.Sprintf("Test: %d", i)
+
+		w := newSQLWriter(nil, "table")
 
-		err := test.Condition.toConditionSQL(query, &params)
+		err := test.Condition.ConditionSQL(w)
 		require.NoError(t, err, msg)
-		require.Equal(t, test.Query, query.String(), msg)
-		require.Equal(t, test.Params, params, msg)
+		require.Equal(t, test.Query, w.String(), msg)
+		require.Equal(t, test.Params, *w.params, msg)
 	}
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+type regexpCondition struct {
+	field   WhereField
+	pattern string
+}
+
+func (c regexpCondition) ConditionSQL(w *SQLWriter) error {
+	w.WriteString("(")
+	if err := w.WriteField(c.field); err != nil {
+		return err
+	}
+	w.WriteString(" REGEXP ")
+	w.WriteParam(c.pattern)
+	w.WriteString(")")
+	return nil
+}
+
+func TestConditions_Custom(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	s, p, err := c.Query("test").Where(Or(regexpCondition{c.Path("Name"), "^J"}, Eq(c.ID, "1"))).Delete().ToSQL()
+	require.NoError(t, err)
+	require.Equal(t, "DELETE FROM jdb WHERE ((kind = ?) AND ((data->'$.Name' REGEXP ?) OR (id = ?)))", s)
+	require.Equal(t, params("test", "^J", "1"), p)
+
+	require.NoError(t, mock.ExpectationsWereMet())
 }
@@ -10,8 +10,17 @@ const (
 	createTimeTag = "-createtime"
 	updateTimeTag = "-updatetime"
 
+	schemaVersionTag = "-schemaversion"
+
 	uniqueStringKeyTag = "uniquestringkey"
 	stringKeyTag       = "stringkey"
 	numericKeyTag      = "numerickey"
 	timeKeyTag         = "timekey"
+
+	childrenTag = "children"
+	parentTag   = "parent"
+	refTag      = "ref"
+	indexTag    = "index"
+	uniqueTag   = "unique"
+	scopeTag    = "scope"
 )
@@ -1,7 +1,6 @@
 package jdb
 
 import (
-	"bytes"
 	"context"
 )
 
@@ -19,22 +18,40 @@ func newDeleteBuilder(q *Query, wb *WhereBuilder) *DeleteBuilder {
 }
 
 func (b *DeleteBuilder) ToSQL() (string, []interface{}, error) {
-	var params []interface{}
-	query := &bytes.Buffer{}
+	w := newSQLWriter(b.q.d, b.q.table)
 
-	query.WriteString("DELETE FROM ")
-	query.WriteString(b.q.table)
-	query.WriteString(" ")
+	w.WriteString("DELETE FROM ")
+	w.WriteString(b.q.table)
+	w.WriteString(" ")
 
-	err := b.wb.toWhereSQL(query, &params)
+	err := b.wb.toWhereSQL(w)
 	if err != nil {
 		return "", nil, err
 	}
 
-	return b.q.d.ReplacePlaceHolders(query.String()), params, nil
+	query, params := w.toSQL()
+	return query, params, nil
 }
 
-func (b *DeleteBuilder) Exec(ctx context.Context, tx *Tx) error {
+func (b *DeleteBuilder) Exec(ctx context.Context, e Executor) error {
+	return e.run(ctx, writeMode, func(tx *Tx) error {
+		return b.exec(ctx, tx)
+	})
+}
+
+func (b *DeleteBuilder) exec(ctx context.Context, tx *Tx) error {
+	if err := b.beforeDelete(ctx, tx); err != nil {
+		return err
+	}
+	if err := deleteSide(ctx, tx, b.q, b.wb, refsTable(b.q.table)); err != nil {
+		return err
+	}
+	if err := deleteSide(ctx, tx, b.q, b.wb, keysTable(b.q.table)); err != nil {
+		return err
+	}
+	if err := deleteAttachments(ctx, tx, b.q, b.wb); err != nil {
+		return err
+	}
 	_, err := tx.exec(ctx, b)
 	return err
 }
@@ -38,9 +38,36 @@ func TestDeleteBuilder_Exec(t *testing.T) {
 	kind := "test"
 
 	mock.ExpectBegin()
+	mock.ExpectExec("DELETE FROM jdb_refs WHERE EXISTS").
+		WithArgs(kind).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM jdb_keys WHERE EXISTS").
+		WithArgs(kind).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT DISTINCT sha256 FROM jdb_attachments WHERE EXISTS").
+		WithArgs(kind).
+		WillReturnRows(sqlmock.NewRows([]string{"sha256"}))
 	mock.ExpectExec("DELETE FROM jdb WHERE").
 		WithArgs(kind).
 		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM jdb_refs WHERE EXISTS").
+		WithArgs(kind, "1", "2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("DELETE FROM jdb_keys WHERE EXISTS").
+		WithArgs(kind, "1", "2").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT DISTINCT sha256 FROM jdb_attachments WHERE EXISTS").
+		WithArgs(kind, "1", "2").
+		WillReturnRows(sqlmock.NewRows([]string{"sha256"}).AddRow("abc"))
+	mock.ExpectExec("DELETE FROM jdb_attachments WHERE EXISTS").
+		WithArgs(kind, "1", "2").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM jdb_blob_chunks WHERE sha256 IN \(\?\) AND NOT EXISTS`).
+		WithArgs("abc").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`DELETE FROM jdb_blobs WHERE sha256 IN \(\?\) AND NOT EXISTS`).
+		WithArgs("abc").
+		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec("DELETE FROM jdb WHERE").
 		WithArgs(kind, "1", "2").
 		WillReturnResult(sqlmock.NewResult(0, 0))
@@ -15,10 +15,23 @@ type Dialect interface {
 	Path() Path
 	Now(ctx context.Context, tx *sql.Tx) (time.Time, error)
 	ErrorMap(err error) error
+	StatementTimeout(timeout time.Duration) (set, reset string)
 
 	Migrate(ctx context.Context, db *sql.DB, table string) error
+	CreatePathIndex(ctx context.Context, db *sql.DB, table string, index PathIndex) error
+	DropPathIndex(ctx context.Context, db *sql.DB, table string, index PathIndex) error
 }
 
 type ValidateDataSourceNameOpts struct {
 	ReadOnly bool
 }
+
+// TimeoutMillis returns timeout in milliseconds, rounded up so short timeouts
+// are not disabled.
+func TimeoutMillis(timeout time.Duration) int64 {
+	ms := int64(timeout / time.Millisecond)
+	if timeout%time.Millisecond != 0 {
+		ms++
+	}
+	return ms
+}
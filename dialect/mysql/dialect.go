@@ -66,8 +66,19 @@ func (d *mysqlDialect) Now(ctx context.Context, tx *sql.Tx) (now time.Time, err
 	return
 }
 
+func (d *mysqlDialect) StatementTimeout(timeout time.Duration) (string, string) {
+	return fmt.Sprintf("SET SESSION max_execution_time = %d", dialect.TimeoutMillis(timeout)),
+		"SET SESSION max_execution_time = DEFAULT"
+}
+
 func (d *mysqlDialect) ErrorMap(err error) error {
 	if e, ok := err.(*mysql.MySQLError); ok {
+		if e.Number == duplicateEntry {
+			return &jdb.UniqueError{Err: newError(e), Constraint: duplicateKey(e.Message)}
+		}
+		if e.Number == lockDeadlock {
+			return &jdb.SerializationError{Err: newError(e)}
+		}
 		return newError(e)
 	}
 	return err
@@ -1,10 +1,31 @@
 package mysql
 
 import (
+	"strings"
+
 	"github.com/go-sql-driver/mysql"
 	"github.com/silas/jdb/internal/errors"
 )
 
+const (
+	duplicateEntry = 1062
+	lockDeadlock   = 1213
+)
+
+// duplicateKey returns the key name from a duplicate entry error message,
+// which looks like "Duplicate entry 'a' for key 'table.name'".
+func duplicateKey(message string) string {
+	i := strings.LastIndex(message, " for key '")
+	if i < 0 {
+		return ""
+	}
+	name := strings.TrimSuffix(message[i+len(" for key '"):], "'")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
 type postgresError struct {
 	err *mysql.MySQLError
 }
@@ -23,9 +44,11 @@ func (e postgresError) Source() error {
 
 func (e postgresError) Type() errors.ErrorType {
 	switch e.err.Number {
+	case duplicateEntry:
+		return errors.IntegrityError
 	case 1205:
 		return errors.BusyError
-	case 1213:
+	case lockDeadlock:
 		return errors.TransactionError
 	default:
 		return errors.UnknownError
@@ -0,0 +1,74 @@
+package mysql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/silas/jdb/dialect"
+)
+
+const indexExists = `
+SELECT
+  count(*) > 0 AS index_exists
+FROM
+  information_schema.statistics
+WHERE
+  table_schema = database() AND
+  table_name = ? AND
+  index_name = ?
+LIMIT 1;
+`
+
+func (d *mysqlDialect) indexExists(ctx context.Context, db *sql.DB, table, name string) (bool, error) {
+	var exists bool
+	err := db.QueryRowContext(ctx, indexExists, table, name).Scan(&exists)
+	return exists, err
+}
+
+// CreatePathIndex adds a virtual column for the path along with an index on
+// it, which mysql uses for queries on the same expression. It does nothing
+// when the index exists.
+func (d *mysqlDialect) CreatePathIndex(ctx context.Context, db *sql.DB, table string, index dialect.PathIndex) error {
+	if exists, err := d.indexExists(ctx, db, table, index.Name); err != nil || exists {
+		return err
+	}
+
+	p := &mysqlPath{}
+	for _, key := range index.Path {
+		p.Key(key)
+	}
+
+	unique := ""
+	if index.Unique {
+		unique = "UNIQUE "
+	}
+
+	name := quoteIdentifier(index.Name)
+	query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s VARCHAR(255) GENERATED ALWAYS AS (%s) VIRTUAL, "+
+		"ADD %sINDEX %s (kind, %s)", table, name, p.JSONExtract("data"), unique, name, name)
+	if _, err := db.ExecContext(ctx, query); err != nil {
+		// the index may have been added concurrently since it was checked
+		if exists, existsErr := d.indexExists(ctx, db, table, index.Name); existsErr == nil && exists {
+			return nil
+		}
+		return err
+	}
+	return nil
+}
+
+func (d *mysqlDialect) DropPathIndex(ctx context.Context, db *sql.DB, table string, index dialect.PathIndex) error {
+	if exists, err := d.indexExists(ctx, db, table, index.Name); err != nil || !exists {
+		return err
+	}
+
+	name := quoteIdentifier(index.Name)
+	query := fmt.Sprintf("ALTER TABLE %s DROP INDEX %s, DROP COLUMN %s", table, name, name)
+	_, err := db.ExecContext(ctx, query)
+	return err
+}
+
+func quoteIdentifier(v string) string {
+	return "`" + strings.Replace(v, "`", "``", -1) + "`"
+}
@@ -24,6 +24,64 @@ CREATE TABLE {{ .Table }} (
 ) DEFAULT CHARACTER SET utf8mb4;
 `
 
+const createRefsTable = `
+CREATE TABLE {{ .Table }}_refs (
+  kind VARCHAR(64) NOT NULL,
+  id VARCHAR(64) NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  ref_kind VARCHAR(64) NOT NULL,
+  ref_id VARCHAR(64) NOT NULL,
+  PRIMARY KEY (kind, id, name),
+  FOREIGN KEY (kind, id) REFERENCES {{ .Table }} (kind, id) ON DELETE CASCADE
+) DEFAULT CHARACTER SET utf8mb4;
+`
+
+const createKeysTable = `
+CREATE TABLE {{ .Table }}_keys (
+  kind VARCHAR(64) NOT NULL,
+  id VARCHAR(64) NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  string_value VARCHAR(255),
+  numeric_value DOUBLE,
+  time_value TIMESTAMP(4) NULL DEFAULT NULL,
+  unique_value VARCHAR(255),
+  scope VARCHAR(64) NOT NULL DEFAULT '',
+  scope_value VARCHAR(191) NOT NULL DEFAULT '',
+  FOREIGN KEY (kind, id) REFERENCES {{ .Table }} (kind, id) ON DELETE CASCADE
+) DEFAULT CHARACTER SET utf8mb4;
+`
+
+const createBlobsTable = `
+CREATE TABLE {{ .Table }}_blobs (
+  sha256 CHAR(64) NOT NULL,
+  size BIGINT NOT NULL,
+  PRIMARY KEY (sha256)
+) DEFAULT CHARACTER SET utf8mb4;
+`
+
+const createBlobChunksTable = `
+CREATE TABLE {{ .Table }}_blob_chunks (
+  sha256 CHAR(64) NOT NULL,
+  seq INTEGER NOT NULL,
+  data LONGBLOB NOT NULL,
+  PRIMARY KEY (sha256, seq)
+) DEFAULT CHARACTER SET utf8mb4;
+`
+
+const createAttachmentsTable = `
+CREATE TABLE {{ .Table }}_attachments (
+  kind VARCHAR(64) NOT NULL,
+  id VARCHAR(64) NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  content_type VARCHAR(255) NOT NULL,
+  size BIGINT NOT NULL,
+  sha256 CHAR(64) NOT NULL,
+  PRIMARY KEY (kind, id, name),
+  FOREIGN KEY (kind, id) REFERENCES {{ .Table }} (kind, id) ON DELETE CASCADE,
+  FOREIGN KEY (sha256) REFERENCES {{ .Table }}_blobs (sha256)
+) DEFAULT CHARACTER SET utf8mb4;
+`
+
 var revisions = m.Revisions{
 	m.SQL(1, createTable),
 	m.SQL(2, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }} (create_time);`),
@@ -41,4 +99,16 @@ var revisions = m.Revisions{
 	m.SQL(14, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }} (parent_kind, parent_id, kind, time_key);`),
 	m.SQL(15, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }} (parent_kind, parent_id, kind, create_time);`),
 	m.SQL(16, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }} (parent_kind, parent_id, kind, update_time);`),
+	m.SQL(17, createRefsTable),
+	m.SQL(18, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }}_refs (ref_kind, ref_id, kind, id);`),
+	m.SQL(19, createKeysTable),
+	m.SQL(20, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }}_keys (kind, id, name);`),
+	m.SQL(21, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }}_keys (kind, name, string_value);`),
+	m.SQL(22, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }}_keys (kind, name, numeric_value);`),
+	m.SQL(23, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }}_keys (kind, name, time_value);`),
+	m.SQL(24, `CREATE UNIQUE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }}_keys (kind, name, scope_value, unique_value);`),
+	m.SQL(25, createBlobsTable),
+	m.SQL(26, createBlobChunksTable),
+	m.SQL(27, createAttachmentsTable),
+	m.SQL(28, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }}_attachments (sha256);`),
 }
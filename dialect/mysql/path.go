@@ -27,3 +27,16 @@ func (p *mysqlPath) JSONExtract(column string) string {
 	path = strings.Replace(path, "'", `''`, -1)
 	return fmt.Sprintf("json_unquote(json_extract(%s, '$%s'))", column, path)
 }
+
+func (p *mysqlPath) JSONExtractNumeric(column string) string {
+	path := strings.Join(p.parts, "")
+	path = strings.Replace(path, "'", `''`, -1)
+	return fmt.Sprintf("(CASE WHEN json_type(json_extract(%s, '$%s')) IN ('INTEGER', 'UNSIGNED INTEGER', "+
+		"'DOUBLE', 'DECIMAL') THEN json_extract(%s, '$%s') END)", column, path, column, path)
+}
+
+func (p *mysqlPath) JSONContains(column string) string {
+	path := strings.Join(p.parts, "")
+	path = strings.Replace(path, "'", `''`, -1)
+	return fmt.Sprintf("(COALESCE(json_contains(json_extract(%s, '$%s'), ?), 0) = 1)", column, path)
+}
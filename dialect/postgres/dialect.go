@@ -64,8 +64,18 @@ func (d *postgresDialect) Now(ctx context.Context, tx *sql.Tx) (now time.Time, e
 	return
 }
 
+func (d *postgresDialect) StatementTimeout(timeout time.Duration) (string, string) {
+	return fmt.Sprintf("SET LOCAL statement_timeout = %d", dialect.TimeoutMillis(timeout)), ""
+}
+
 func (d *postgresDialect) ErrorMap(err error) error {
 	if e, ok := err.(*pq.Error); ok {
+		if e.Code == uniqueViolation {
+			return &jdb.UniqueError{Err: newError(e), Constraint: e.Constraint}
+		}
+		if e.Code == serializationFailure || e.Code == deadlockDetected {
+			return &jdb.SerializationError{Err: newError(e)}
+		}
 		return newError(e)
 	}
 	return err
@@ -5,6 +5,12 @@ import (
 	"github.com/silas/jdb/internal/errors"
 )
 
+const (
+	uniqueViolation      = "23505"
+	serializationFailure = "40001"
+	deadlockDetected     = "40P01"
+)
+
 type postgresError struct {
 	err *pq.Error
 }
@@ -33,6 +39,8 @@ func (e postgresError) Type() errors.ErrorType {
 		return errors.TransactionError
 	case "28":
 		return errors.AuthorizationError
+	case "40":
+		return errors.TransactionError
 	default:
 		return errors.UnknownError
 	}
@@ -0,0 +1,37 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/lib/pq"
+	"github.com/silas/jdb/dialect"
+)
+
+func (d *postgresDialect) CreatePathIndex(ctx context.Context, db *sql.DB, table string, index dialect.PathIndex) error {
+	p := &postgresPath{}
+	for _, key := range index.Path {
+		p.Key(key)
+	}
+
+	unique := ""
+	if index.Unique {
+		unique = "UNIQUE "
+	}
+
+	query := fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS %s ON %s ((%s)) WHERE kind = %s",
+		unique, pq.QuoteIdentifier(index.Name), table, p.JSONExtract("data"), quoteLiteral(index.Kind))
+	_, err := db.ExecContext(ctx, query)
+	return err
+}
+
+func (d *postgresDialect) DropPathIndex(ctx context.Context, db *sql.DB, table string, index dialect.PathIndex) error {
+	_, err := db.ExecContext(ctx, fmt.Sprintf("DROP INDEX IF EXISTS %s", pq.QuoteIdentifier(index.Name)))
+	return err
+}
+
+func quoteLiteral(v string) string {
+	return "'" + strings.Replace(v, "'", "''", -1) + "'"
+}
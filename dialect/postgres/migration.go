@@ -24,6 +24,64 @@ CREATE TABLE {{ .Table }} (
 );
 `
 
+const createRefsTable = `
+CREATE TABLE {{ .Table }}_refs (
+  kind VARCHAR(64) NOT NULL,
+  id VARCHAR(64) NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  ref_kind VARCHAR(64) NOT NULL,
+  ref_id VARCHAR(64) NOT NULL,
+  PRIMARY KEY (kind, id, name),
+  FOREIGN KEY (kind, id) REFERENCES {{ .Table }} (kind, id) ON DELETE CASCADE
+);
+`
+
+const createKeysTable = `
+CREATE TABLE {{ .Table }}_keys (
+  kind VARCHAR(64) NOT NULL,
+  id VARCHAR(64) NOT NULL,
+  name VARCHAR(64) NOT NULL,
+  string_value VARCHAR(255),
+  numeric_value DOUBLE PRECISION,
+  time_value TIMESTAMP WITH TIME ZONE,
+  unique_value VARCHAR(255),
+  scope VARCHAR(64) NOT NULL DEFAULT '',
+  scope_value VARCHAR(191) NOT NULL DEFAULT '',
+  FOREIGN KEY (kind, id) REFERENCES {{ .Table }} (kind, id) ON DELETE CASCADE
+);
+`
+
+const createBlobsTable = `
+CREATE TABLE {{ .Table }}_blobs (
+  sha256 CHAR(64) NOT NULL,
+  size BIGINT NOT NULL,
+  PRIMARY KEY (sha256)
+);
+`
+
+const createBlobChunksTable = `
+CREATE TABLE {{ .Table }}_blob_chunks (
+  sha256 CHAR(64) NOT NULL,
+  seq INTEGER NOT NULL,
+  data BYTEA NOT NULL,
+  PRIMARY KEY (sha256, seq)
+);
+`
+
+const createAttachmentsTable = `
+CREATE TABLE {{ .Table }}_attachments (
+  kind VARCHAR(64) NOT NULL,
+  id VARCHAR(64) NOT NULL,
+  name VARCHAR(255) NOT NULL,
+  content_type VARCHAR(255) NOT NULL,
+  size BIGINT NOT NULL,
+  sha256 CHAR(64) NOT NULL,
+  PRIMARY KEY (kind, id, name),
+  FOREIGN KEY (kind, id) REFERENCES {{ .Table }} (kind, id) ON DELETE CASCADE,
+  FOREIGN KEY (sha256) REFERENCES {{ .Table }}_blobs (sha256)
+);
+`
+
 var revisions = m.Revisions{
 	m.SQL(1, createTable),
 	m.SQL(2, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }} (create_time NULLS FIRST);`),
@@ -41,4 +99,16 @@ var revisions = m.Revisions{
 	m.SQL(14, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }} (parent_kind NULLS FIRST, parent_id NULLS FIRST, kind NULLS FIRST, time_key NULLS FIRST);`),
 	m.SQL(15, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }} (parent_kind NULLS FIRST, parent_id NULLS FIRST, kind NULLS FIRST, create_time NULLS FIRST);`),
 	m.SQL(16, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }} (parent_kind NULLS FIRST, parent_id NULLS FIRST, kind NULLS FIRST, update_time NULLS FIRST);`),
+	m.SQL(17, createRefsTable),
+	m.SQL(18, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }}_refs (ref_kind, ref_id, kind, id);`),
+	m.SQL(19, createKeysTable),
+	m.SQL(20, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }}_keys (kind, id, name);`),
+	m.SQL(21, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }}_keys (kind, name, string_value);`),
+	m.SQL(22, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }}_keys (kind, name, numeric_value);`),
+	m.SQL(23, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }}_keys (kind, name, time_value);`),
+	m.SQL(24, `CREATE UNIQUE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }}_keys (kind, name, scope_value, unique_value);`),
+	m.SQL(25, createBlobsTable),
+	m.SQL(26, createBlobChunksTable),
+	m.SQL(27, createAttachmentsTable),
+	m.SQL(28, `CREATE INDEX {{ .Namespace }}_r{{ .ID }} ON {{ .Table }}_attachments (sha256);`),
 }
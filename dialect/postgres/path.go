@@ -28,3 +28,16 @@ func (p *postgresPath) JSONExtract(column string) string {
 	path = strings.Replace(path, "'", `''`, -1)
 	return fmt.Sprintf("%s#>>'{%s}'", column, path)
 }
+
+func (p *postgresPath) JSONExtractNumeric(column string) string {
+	path := strings.Join(p.parts, ",")
+	path = strings.Replace(path, "'", `''`, -1)
+	return fmt.Sprintf("(CASE WHEN jsonb_typeof(%s#>'{%s}') = 'number' THEN (%s#>>'{%s}')::numeric END)",
+		column, path, column, path)
+}
+
+func (p *postgresPath) JSONContains(column string) string {
+	path := strings.Join(p.parts, ",")
+	path = strings.Replace(path, "'", `''`, -1)
+	return fmt.Sprintf("COALESCE(%s#>'{%s}' @> ?::jsonb, false)", column, path)
+}
@@ -91,8 +91,21 @@ func (t *nowTime) Scan(v interface{}) error {
 	return nil
 }
 
+// StatementTimeout returns no statements, sqlite3 timeouts are context
+// deadlines.
+func (d *sqlite3Dialect) StatementTimeout(timeout time.Duration) (string, string) {
+	return "", ""
+}
+
 func (d *sqlite3Dialect) ErrorMap(err error) error {
+	if e, ok := err.(sqlite3.Error); ok {
+		err = &e
+	}
 	if e, ok := err.(*sqlite3.Error); ok {
+		if e.ExtendedCode == sqlite3.ErrConstraintUnique || e.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+			constraint := strings.TrimPrefix(e.Error(), "UNIQUE constraint failed: ")
+			return &jdb.UniqueError{Err: newError(e), Constraint: constraint}
+		}
 		return newError(e)
 	}
 	return err
@@ -0,0 +1,38 @@
+package sqlite3
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/silas/jdb/dialect"
+)
+
+// CreatePathIndex includes kind in the index instead of using a partial index
+// since sqlite3 only uses partial indexes for queries with literal values.
+func (d *sqlite3Dialect) CreatePathIndex(ctx context.Context, db *sql.DB, table string, index dialect.PathIndex) error {
+	p := &sqlite3Path{}
+	for _, key := range index.Path {
+		p.Key(key)
+	}
+
+	unique := ""
+	if index.Unique {
+		unique = "UNIQUE "
+	}
+
+	query := fmt.Sprintf("CREATE %sINDEX IF NOT EXISTS %s ON %s (kind, %s)",
+		unique, quoteIdentifier(index.Name), table, p.JSONExtract("data"))
+	_, err := db.ExecContext(ctx, query)
+	return err
+}
+
+func (d *sqlite3Dialect) DropPathIndex(ctx context.Context, db *sql.DB, table string, index dialect.PathIndex) error {
+	_, err := db.ExecContext(ctx, fmt.Sprintf("DROP INDEX IF EXISTS %s", quoteIdentifier(index.Name)))
+	return err
+}
+
+func quoteIdentifier(v string) string {
+	return `"` + strings.Replace(v, `"`, `""`, -1) + `"`
+}
@@ -27,3 +27,17 @@ func (p *sqlite3Path) JSONExtract(column string) string {
 	path = strings.Replace(path, "'", `''`, -1)
 	return fmt.Sprintf("json_extract(%s, '$%s')", column, path)
 }
+
+func (p *sqlite3Path) JSONExtractNumeric(column string) string {
+	path := strings.Join(p.parts, "")
+	path = strings.Replace(path, "'", `''`, -1)
+	return fmt.Sprintf("(CASE WHEN json_type(%s, '$%s') IN ('integer', 'real') THEN json_extract(%s, '$%s') END)",
+		column, path, column, path)
+}
+
+func (p *sqlite3Path) JSONContains(column string) string {
+	path := strings.Join(p.parts, "")
+	path = strings.Replace(path, "'", `''`, -1)
+	return fmt.Sprintf("(EXISTS (SELECT 1 FROM json_each(%s, '$%s') WHERE json_each.value = json_extract(?, '$')))",
+		column, path)
+}
@@ -64,6 +64,10 @@ func (d *mockDialect) Now(ctx context.Context, tx *sql.Tx) (now time.Time, err e
 	return
 }
 
+func (d *mockDialect) StatementTimeout(timeout time.Duration) (string, string) {
+	return fmt.Sprintf("SET LOCAL statement_timeout = %d", timeout/time.Millisecond), ""
+}
+
 func (d *mockDialect) ErrorMap(err error) error {
 	return err
 }
@@ -0,0 +1,30 @@
+package sqlmock
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/silas/jdb/dialect"
+)
+
+func (d *mockDialect) CreatePathIndex(ctx context.Context, db *sql.DB, table string, index dialect.PathIndex) error {
+	p := &mockPath{}
+	for _, key := range index.Path {
+		p.Key(key)
+	}
+
+	unique := ""
+	if index.Unique {
+		unique = "UNIQUE "
+	}
+
+	query := fmt.Sprintf("CREATE %sINDEX %s ON %s (kind, %s)", unique, index.Name, table, p.JSONExtract("data"))
+	_, err := db.ExecContext(ctx, query)
+	return err
+}
+
+func (d *mockDialect) DropPathIndex(ctx context.Context, db *sql.DB, table string, index dialect.PathIndex) error {
+	_, err := db.ExecContext(ctx, fmt.Sprintf("DROP INDEX %s", index.Name))
+	return err
+}
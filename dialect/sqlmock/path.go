@@ -25,3 +25,13 @@ func (p *mockPath) JSONExtract(column string) string {
 	path := strings.Join(p.parts, "")
 	return fmt.Sprintf("%s->'$%s'", column, path)
 }
+
+func (p *mockPath) JSONExtractNumeric(column string) string {
+	path := strings.Join(p.parts, "")
+	return fmt.Sprintf("CAST(%s->'$%s' AS NUMERIC)", column, path)
+}
+
+func (p *mockPath) JSONContains(column string) string {
+	path := strings.Join(p.parts, "")
+	return fmt.Sprintf("JSON_CONTAINS(%s->'$%s', ?)", column, path)
+}
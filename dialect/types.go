@@ -10,3 +10,30 @@ type Path interface {
 	Index(v int) Path
 	JSONExtract(column string) string
 }
+
+// NumericPath is an optional interface of Path, for dialects which can
+// compare values at the path as numbers.
+type NumericPath interface {
+	Path
+	// JSONExtractNumeric returns the value at the path as a number, or NULL
+	// when it isn't a JSON number.
+	JSONExtractNumeric(column string) string
+}
+
+// ContainsPath is an optional interface of Path, for dialects which can
+// match values within JSON arrays.
+type ContainsPath interface {
+	Path
+	// JSONContains returns a condition, which is never NULL, matching when
+	// the value at the path equals the JSON encoded "?" parameter, or is an
+	// array containing it.
+	JSONContains(column string) string
+}
+
+// PathIndex is an expression index on a JSON path of one kind's data.
+type PathIndex struct {
+	Name   string
+	Kind   string
+	Path   []string
+	Unique bool
+}
@@ -0,0 +1,33 @@
+package jdb
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Document is a generic document that keeps data as raw JSON. It can be used
+// to insert or scan documents of any kind.
+type Document struct {
+	Kind       string          `jdb:"-kind"`
+	ID         string          `jdb:"-id"`
+	ParentKind string          `jdb:"-parentkind"`
+	ParentID   string          `jdb:"-parentid"`
+	Data       json.RawMessage `jdb:"-"`
+	CreateTime time.Time       `jdb:"-createtime"`
+	UpdateTime time.Time       `jdb:"-updatetime"`
+
+	// SchemaVersion is the schema version the document was stored with.
+	SchemaVersion int `jdb:"-schemaversion"`
+}
+
+func (d Document) MarshalJSON() ([]byte, error) {
+	if len(d.Data) == 0 {
+		return []byte("{}"), nil
+	}
+	return d.Data, nil
+}
+
+func (d *Document) UnmarshalJSON(data []byte) error {
+	d.Data = append(d.Data[:0], data...)
+	return nil
+}
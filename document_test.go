@@ -0,0 +1,70 @@
+package jdb
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/silas/jdb/internal/ptr"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestDocument(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	doc := Document{
+		ID:         "1",
+		ParentKind: "post",
+		ParentID:   "2",
+		Data:       []byte(`{"Hello":"World"}`),
+	}
+	_, p, err := c.Query("comment").Insert(doc).ToSQL()
+	require.NoError(t, err)
+	require.Equal(t, params("comment", "1", ptr.String("post"), ptr.String("2"), (*string)(nil),
+		(*string)(nil), (*float64)(nil), (*time.Time)(nil), ptr.String(`{"Hello":"World"}`)), p)
+
+	_, p, err = c.Query("comment").Insert(Document{ID: "1"}).ToSQL()
+	require.NoError(t, err)
+	require.Equal(t, (*string)(nil), p[8])
+
+	createTime := time.Date(2005, 3, 7, 8, 23, 34, 0, time.UTC)
+	columns := []string{"kind", "id", "parent_kind", "parent_id", "data", "create_time", "update_time"}
+
+	mock.ExpectBegin()
+	rows := sqlmock.NewRows(columns).
+		AddRow("comment", "1", "post", "2", `{"Hello":"World"}`, createTime, createTime).
+		AddRow("comment", "3", nil, nil, nil, createTime, createTime)
+	mock.ExpectQuery(`SELECT kind, id, .*`).
+		WithArgs("comment").
+		WillReturnRows(rows)
+	mock.ExpectCommit()
+
+	require.NoError(t, c.Tx(context.Background(), func(tx *Tx) error {
+		var docs []Document
+		err := c.Query("comment").Select().All(context.Background(), tx, &docs)
+		require.NoError(t, err)
+		require.Equal(t, []Document{
+			{
+				Kind:       "comment",
+				ID:         "1",
+				ParentKind: "post",
+				ParentID:   "2",
+				Data:       []byte(`{"Hello":"World"}`),
+				CreateTime: createTime,
+				UpdateTime: createTime,
+			},
+			{
+				Kind:       "comment",
+				ID:         "3",
+				CreateTime: createTime,
+				UpdateTime: createTime,
+			},
+		}, docs)
+
+		return tx.Commit()
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
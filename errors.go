@@ -8,9 +8,19 @@ import (
 
 type Error = jdberrors.Error
 type ErrorType = jdberrors.ErrorType
+type UniqueError = jdberrors.UniqueError
+type SerializationError = jdberrors.SerializationError
 
 var (
 	ErrReadOnlyMode = errors.New("jdb: read-only mode")
 	ErrIDNotFound   = errors.New("jdb: id not found")
 	ErrNotFound     = errors.New("jdb: not found")
+	ErrCycle        = errors.New("jdb: parent would create a cycle")
+	ErrNestedCommit = errors.New("jdb: nested transaction can not be committed")
+	ErrTxCommitted  = errors.New("jdb: transaction already committed")
+	ErrNoTx         = errors.New("jdb: not in a transaction")
+	ErrTxTimeout    = errors.New("jdb: transaction timed out")
+
+	ErrAttachmentTooLarge = errors.New("jdb: attachment too large")
+	ErrKindNotRegistered  = errors.New("jdb: kind not registered")
 )
@@ -0,0 +1,50 @@
+package jdb
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Executor runs builders, it is implemented by *Tx and *Client.
+//
+// A *Tx runs builders in its transaction. A *Client runs writes in a
+// transaction of their own, which is committed when the write succeeds, and
+// reads directly on the database. Reads that take several statements, those
+// with includes, preloads or AfterLoad hooks, run in a read-only transaction
+// so they see one snapshot, and their hooks can not write.
+type Executor interface {
+	run(ctx context.Context, mode runMode, fn func(*Tx) error) error
+}
+
+// runMode is how an Executor runs a builder.
+type runMode int
+
+const (
+	// queryMode runs reads of a single statement.
+	queryMode runMode = iota
+	// snapshotMode runs reads of several statements.
+	snapshotMode
+	// writeMode runs writes.
+	writeMode
+)
+
+// sqlExecutor is implemented by *sql.Tx and *sql.DB.
+type sqlExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+func (t *Tx) run(ctx context.Context, mode runMode, fn func(*Tx) error) error {
+	return fn(t)
+}
+
+func (c *Client) run(ctx context.Context, mode runMode, fn func(*Tx) error) error {
+	switch mode {
+	case writeMode:
+		return c.Update(ctx, fn)
+	case snapshotMode:
+		return c.View(ctx, fn)
+	}
+	return fn(&Tx{c: c, tx: c.db, readOnly: c.readOnly})
+}
@@ -0,0 +1,106 @@
+package jdb
+
+import (
+	"context"
+	"testing"
+
+	"github.com/silas/jdb/internal/ptr"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestClient_Executor(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	ctx := context.Background()
+	q := c.Query("kind")
+
+	mock.ExpectQuery("SELECT id, data FROM jdb WHERE").
+		WithArgs("kind", "1").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "data"}).AddRow("1", `{"a":1}`))
+	mock.ExpectQuery("SELECT id FROM jdb WHERE").
+		WithArgs("kind").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO jdb").
+		WithArgs("kind", "2", nil, nil, nil, nil, nil, nil, ptr.String(`{"a":2}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO jdb").
+		WillReturnError(ErrIDNotFound)
+	mock.ExpectRollback()
+
+	var doc Document
+	require.NoError(t, q.Get("1").Select(c.ID, c.Data).First(ctx, c, &doc))
+	require.Equal(t, "1", doc.ID)
+	require.JSONEq(t, `{"a":1}`, string(doc.Data))
+
+	rows, err := q.Where().Select(c.ID).Rows(ctx, c)
+	require.NoError(t, err)
+	require.NoError(t, rows.Close())
+
+	require.NoError(t, q.Insert(Document{ID: "2", Data: []byte(`{"a":2}`)}).Exec(ctx, c))
+
+	err = q.Insert(Document{ID: "3"}).Exec(ctx, c)
+	require.Equal(t, ErrIDNotFound, err)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClient_ExecutorTx(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	err := c.run(ctx, queryMode, func(tx *Tx) error {
+		require.Equal(t, ErrNoTx, tx.Commit())
+		require.Equal(t, ErrNoTx, tx.Nested(ctx, func(*Tx) error { return nil }))
+		return nil
+	})
+	require.NoError(t, err)
+
+	c.readOnly = true
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	err = c.Query("kind").Delete("1").Exec(ctx, c)
+	require.Equal(t, ErrReadOnlyMode, err)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClient_ExecutorSnapshot(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	var loaded []string
+	c.RegisterHooks("kind", Hooks{
+		AfterLoad: func(ctx context.Context, tx *Tx, v interface{}) error {
+			require.True(t, tx.readOnly)
+			loaded = append(loaded, v.(*Document).ID)
+			return nil
+		},
+	})
+
+	mock.ExpectQuery("SELECT id FROM jdb WHERE").
+		WithArgs("other").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id FROM jdb WHERE").
+		WithArgs("kind").
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("2"))
+	mock.ExpectRollback()
+
+	var docs []Document
+	require.NoError(t, c.Query("other").Select(c.ID).All(ctx, c, &docs))
+	require.NoError(t, c.Query("kind").Select(c.ID).All(ctx, c, &docs))
+	require.Equal(t, []string{"2"}, loaded)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,11 +3,11 @@ package jdb
 import "github.com/silas/jdb/dialect"
 
 type SelectField interface {
-	toSelectField() string
+	SelectFieldSQL(w *SQLWriter) error
 }
 
 type WhereField interface {
-	toWhereField() string
+	WhereFieldSQL(w *SQLWriter) error
 	Asc() Order
 	Desc() Order
 }
@@ -16,20 +16,23 @@ type SelectColumn struct {
 	n string
 }
 
-func (c SelectColumn) toSelectField() string {
-	return c.n
+func (c SelectColumn) SelectFieldSQL(w *SQLWriter) error {
+	w.WriteString(c.n)
+	return nil
 }
 
 type SelectWhereColumn struct {
 	n string
 }
 
-func (c SelectWhereColumn) toSelectField() string {
-	return c.n
+func (c SelectWhereColumn) SelectFieldSQL(w *SQLWriter) error {
+	w.WriteString(c.n)
+	return nil
 }
 
-func (c SelectWhereColumn) toWhereField() string {
-	return c.n
+func (c SelectWhereColumn) WhereFieldSQL(w *SQLWriter) error {
+	w.WriteString(c.n)
+	return nil
 }
 
 func (c SelectWhereColumn) Asc() Order {
@@ -44,8 +47,9 @@ type WhereColumn struct {
 	n string
 }
 
-func (c WhereColumn) toWhereField() string {
-	return c.n
+func (c WhereColumn) WhereFieldSQL(w *SQLWriter) error {
+	w.WriteString(c.n)
+	return nil
 }
 
 func (c WhereColumn) Asc() Order {
@@ -71,11 +75,26 @@ var (
 )
 
 type PathField struct {
-	p dialect.Path
+	p       dialect.Path
+	numeric bool
 }
 
-func (p PathField) toWhereField() string {
-	return p.p.JSONExtract("data")
+func (p PathField) WhereFieldSQL(w *SQLWriter) error {
+	if np, ok := p.p.(dialect.NumericPath); ok && p.numeric {
+		w.WriteString(np.JSONExtractNumeric("data"))
+	} else {
+		w.WriteString(p.p.JSONExtract("data"))
+	}
+	return nil
+}
+
+// Numeric returns the field compared as a number, values that aren't JSON
+// numbers compare as NULL. Paths are otherwise extracted as text on some
+// dialects, so numbers compare lexicographically. It has no effect when the
+// dialect doesn't implement dialect.NumericPath.
+func (p PathField) Numeric() PathField {
+	p.numeric = true
+	return p
 }
 
 func (p PathField) Asc() Order {
@@ -95,3 +114,39 @@ func (p PathField) Index(index int) PathField {
 	p.p.Index(index)
 	return p
 }
+
+type RawExpression struct {
+	sql  string
+	args []interface{}
+}
+
+// RawField returns a field using the given SQL expression, which should use
+// "?" for placeholders, as with Raw.
+func RawField(sql string, args ...interface{}) RawExpression {
+	return RawExpression{sql, args}
+}
+
+func (e RawExpression) write(w *SQLWriter) error {
+	if err := checkRawArgs(e.sql, e.args); err != nil {
+		return err
+	}
+	w.WriteString(e.sql)
+	w.AddParams(e.args...)
+	return nil
+}
+
+func (e RawExpression) SelectFieldSQL(w *SQLWriter) error {
+	return e.write(w)
+}
+
+func (e RawExpression) WhereFieldSQL(w *SQLWriter) error {
+	return e.write(w)
+}
+
+func (e RawExpression) Asc() Order {
+	return Order{e, false}
+}
+
+func (e RawExpression) Desc() Order {
+	return Order{e, true}
+}
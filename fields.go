@@ -0,0 +1,134 @@
+package jdb
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/silas/jdb/internal/json"
+)
+
+// field describes a tagged, exported struct field.
+type field struct {
+	index     int
+	name      string
+	goName    string
+	tag       string
+	meta      bool
+	omitEmpty bool
+
+	indexName  string
+	indexOK    bool
+	uniqueName string
+	uniqueOK   bool
+	scope      string
+	refKind    string
+	refOK      bool
+
+	uniqueStringKey bool
+	stringKey       bool
+	numericKey      bool
+	timeKey         bool
+
+	// err is returned when a value of the type is written.
+	err error
+
+	// set assigns a scanned column value to the field.
+	set func(dest reflect.Value, src interface{}) error
+}
+
+// typeFields is the jdb mapping of a struct type.
+type typeFields struct {
+	fields []field
+	names  map[string]int
+}
+
+var fieldCache sync.Map // map[reflect.Type]*typeFields
+
+// cachedTypeFields returns the jdb mapping of t, which is computed once per
+// type.
+func cachedTypeFields(t reflect.Type) *typeFields {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.(*typeFields)
+	}
+	f, _ := fieldCache.LoadOrStore(t, newTypeFields(t))
+	return f.(*typeFields)
+}
+
+func newTypeFields(t reflect.Type) *typeFields {
+	tf := &typeFields{names: map[string]int{}}
+
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if sf.PkgPath != "" {
+			continue
+		}
+		tag := sf.Tag.Get(tagName)
+		if tag == "" {
+			continue
+		}
+		name, opts := json.ParseTag(tag)
+
+		f := field{
+			index:     i,
+			name:      name,
+			goName:    sf.Name,
+			tag:       tag,
+			omitEmpty: opts.Contains("omitempty"),
+
+			uniqueStringKey: opts.Contains(uniqueStringKeyTag),
+			stringKey:       opts.Contains(stringKeyTag),
+			numericKey:      opts.Contains(numericKeyTag),
+			timeKey:         opts.Contains(timeKeyTag),
+		}
+		f.indexName, f.indexOK = opts.Value(indexTag)
+		f.uniqueName, f.uniqueOK = opts.Value(uniqueTag)
+		f.refKind, f.refOK = opts.Value(refTag)
+
+		scope, scopeOK := opts.Value(scopeTag)
+		if scopeOK && (!f.uniqueOK || scope == "") {
+			f.err = fmt.Errorf("scope is invalid: %s", tag)
+		}
+		f.scope = scope
+
+		switch name {
+		case kindTag, idTag, parentKindTag, parentIDTag, createTimeTag, updateTimeTag, schemaVersionTag:
+			f.meta = true
+			f.set = fieldSetter(sf.Type)
+		}
+
+		if _, ok := tf.names[name]; !ok {
+			tf.names[name] = len(tf.fields)
+		}
+		tf.fields = append(tf.fields, f)
+	}
+
+	return tf
+}
+
+// fieldSetter returns a setter for scanned column values, avoiding the
+// generic conversion in setKeyValue for plain strings and times.
+func fieldSetter(t reflect.Type) func(reflect.Value, interface{}) error {
+	switch {
+	case t == timeType:
+		return func(dest reflect.Value, src interface{}) error {
+			v, ok := src.(time.Time)
+			if !ok {
+				return fmt.Errorf("key type is invalid: %s", dest.Type())
+			}
+			dest.Set(reflect.ValueOf(v))
+			return nil
+		}
+	case t.Kind() == reflect.String && !implementsAny(t, scannerType, textUnmarshalerType):
+		return func(dest reflect.Value, src interface{}) error {
+			v, ok := src.(string)
+			if !ok {
+				return fmt.Errorf("key type is invalid: %s", dest.Type())
+			}
+			dest.SetString(v)
+			return nil
+		}
+	}
+	return setKeyValue
+}
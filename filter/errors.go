@@ -0,0 +1,19 @@
+package filter
+
+import "fmt"
+
+// Error describes why a filter document was rejected. Pointer is a JSON
+// Pointer (RFC 6901) to the offending value and Key is the offending object
+// key, if any.
+type Error struct {
+	Pointer string
+	Key     string
+	Reason  string
+}
+
+func (e *Error) Error() string {
+	if e.Pointer == "" {
+		return "filter: " + e.Reason
+	}
+	return fmt.Sprintf("filter: %s: %s", e.Pointer, e.Reason)
+}
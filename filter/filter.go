@@ -0,0 +1,350 @@
+package filter
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/silas/jdb"
+	"github.com/silas/jdb/internal/json"
+)
+
+const (
+	defaultMaxDepth = 4
+	defaultMaxSize  = 64 * 1024
+	defaultMaxTerms = 100
+
+	opAnd  = "$and"
+	opOr   = "$or"
+	opEq   = "$eq"
+	opNe   = "$ne"
+	opGt   = "$gt"
+	opGte  = "$gte"
+	opLt   = "$lt"
+	opLte  = "$lte"
+	opIn   = "$in"
+	opNin  = "$nin"
+	opLike = "$like"
+)
+
+var operators = []string{opEq, opNe, opGt, opGte, opLt, opLte, opIn, opNin, opLike}
+
+type field struct {
+	field     jdb.WhereField
+	operators map[string]bool
+}
+
+// Filter compiles JSON filter documents into conditions on its allowed
+// fields.
+type Filter struct {
+	fields   map[string]field
+	maxDepth int
+	maxSize  int
+	maxTerms int
+}
+
+// New returns a Filter allowing the fields given as options, fields are
+// resolved using the client.
+func New(c *jdb.Client, opts ...Option) (*Filter, error) {
+	f := &Filter{
+		fields:   map[string]field{},
+		maxDepth: defaultMaxDepth,
+		maxSize:  defaultMaxSize,
+		maxTerms: defaultMaxTerms,
+	}
+
+	for _, opt := range opts {
+		switch v := opt.(type) {
+		case optionField:
+			if v.name == "" || strings.HasPrefix(v.name, "$") {
+				return nil, fmt.Errorf("filter: invalid field name: %q", v.name)
+			}
+			if _, ok := f.fields[v.name]; ok {
+				return nil, fmt.Errorf("filter: duplicate field: %s", v.name)
+			}
+			wf := v.field
+			if wf == nil {
+				keys := strings.Split(v.name, ".")
+				for _, key := range keys {
+					if key == "" {
+						return nil, fmt.Errorf("filter: invalid field name: %q", v.name)
+					}
+				}
+				wf = c.Path(keys...)
+			}
+			ops := v.operators
+			if len(ops) == 0 {
+				ops = operators
+			}
+			fo := map[string]bool{}
+			for _, op := range ops {
+				if !isOperator(op) {
+					return nil, fmt.Errorf("filter: unknown operator for %s: %s", v.name, op)
+				}
+				fo[op] = true
+			}
+			f.fields[v.name] = field{field: wf, operators: fo}
+		case optionMaxDepth:
+			if v.depth < 1 {
+				return nil, fmt.Errorf("filter: invalid max depth: %d", v.depth)
+			}
+			f.maxDepth = v.depth
+		case optionMaxSize:
+			if v.size < 2 {
+				return nil, fmt.Errorf("filter: invalid max size: %d", v.size)
+			}
+			f.maxSize = v.size
+		case optionMaxTerms:
+			if v.terms < 1 {
+				return nil, fmt.Errorf("filter: invalid max terms: %d", v.terms)
+			}
+			f.maxTerms = v.terms
+		default:
+			panic("unknown option")
+		}
+	}
+
+	return f, nil
+}
+
+// Parse compiles the JSON filter document into a condition, invalid or
+// disallowed documents return an *Error.
+func (f *Filter) Parse(data []byte) (jdb.Condition, error) {
+	if len(data) > f.maxSize {
+		return nil, &Error{Reason: fmt.Sprintf("document exceeds %d bytes", f.maxSize)}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	// JSON nesting is bounded before compiling so hostile input can't
+	// recurse deeply: each logical level is an object plus an array, and
+	// a field adds an operator object and an $in array.
+	d := &decoder{dec: dec, maxDepth: f.maxDepth*2 + 2}
+	v, err := d.value("", 0)
+	if err != nil {
+		return nil, err
+	}
+	if dec.More() {
+		return nil, &Error{Reason: "unexpected data after document"}
+	}
+	if _, err := dec.Token(); err == nil {
+		return nil, &Error{Reason: "unexpected data after document"}
+	}
+
+	p := &parser{f: f}
+	return p.document(v, "", 1)
+}
+
+func isOperator(op string) bool {
+	for _, v := range operators {
+		if v == op {
+			return true
+		}
+	}
+	return false
+}
+
+type parser struct {
+	f     *Filter
+	terms int
+}
+
+func (p *parser) term(pointer string) error {
+	p.terms++
+	if p.terms > p.f.maxTerms {
+		return &Error{Pointer: pointer, Reason: fmt.Sprintf("document exceeds %d terms", p.f.maxTerms)}
+	}
+	return nil
+}
+
+func (p *parser) document(v *value, pointer string, depth int) (jdb.Condition, error) {
+	if depth > p.f.maxDepth {
+		return nil, &Error{Pointer: pointer, Reason: fmt.Sprintf("document exceeds max depth %d", p.f.maxDepth)}
+	}
+	if v.kind != objectValue {
+		return nil, &Error{Pointer: pointer, Reason: "expected object"}
+	}
+
+	var conditions []jdb.Condition
+	for _, m := range v.object {
+		mp := pointer + "/" + escapePointer(m.key)
+
+		var c jdb.Condition
+		var err error
+		switch {
+		case m.key == opAnd || m.key == opOr:
+			c, err = p.logical(m.key, m.value, mp, depth)
+		case strings.HasPrefix(m.key, "$"):
+			err = &Error{Pointer: mp, Key: m.key, Reason: "unknown operator"}
+		default:
+			c, err = p.field(m.key, m.value, mp)
+		}
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, c)
+	}
+
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	return jdb.And(conditions...), nil
+}
+
+func (p *parser) logical(op string, v *value, pointer string, depth int) (jdb.Condition, error) {
+	if v.kind != arrayValue || len(v.array) == 0 {
+		return nil, &Error{Pointer: pointer, Key: op, Reason: "expected non-empty array"}
+	}
+
+	conditions := make([]jdb.Condition, len(v.array))
+	for i, e := range v.array {
+		c, err := p.document(e, fmt.Sprintf("%s/%d", pointer, i), depth+1)
+		if err != nil {
+			return nil, err
+		}
+		conditions[i] = c
+	}
+
+	if op == opOr {
+		return jdb.Or(conditions...), nil
+	}
+	return jdb.And(conditions...), nil
+}
+
+func (p *parser) field(name string, v *value, pointer string) (jdb.Condition, error) {
+	f, ok := p.f.fields[name]
+	if !ok {
+		return nil, &Error{Pointer: pointer, Key: name, Reason: "unknown field"}
+	}
+
+	if v.kind != objectValue {
+		if !f.operators[opEq] {
+			return nil, &Error{Pointer: pointer, Key: name, Reason: "operator not allowed: " + opEq}
+		}
+		return p.operator(f, opEq, v, pointer)
+	}
+	if len(v.object) == 0 {
+		return nil, &Error{Pointer: pointer, Key: name, Reason: "expected operator"}
+	}
+
+	var conditions []jdb.Condition
+	for _, m := range v.object {
+		mp := pointer + "/" + escapePointer(m.key)
+		if !isOperator(m.key) {
+			return nil, &Error{Pointer: mp, Key: m.key, Reason: "unknown operator"}
+		}
+		if !f.operators[m.key] {
+			return nil, &Error{Pointer: mp, Key: m.key, Reason: "operator not allowed"}
+		}
+		c, err := p.operator(f, m.key, m.value, mp)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, c)
+	}
+
+	if len(conditions) == 1 {
+		return conditions[0], nil
+	}
+	return jdb.And(conditions...), nil
+}
+
+func (p *parser) operator(f field, op string, v *value, pointer string) (jdb.Condition, error) {
+	key := pointerKey(pointer)
+
+	if op == opIn || op == opNin {
+		if v.kind != arrayValue {
+			return nil, &Error{Pointer: pointer, Key: key, Reason: "expected array"}
+		}
+		values := make([]interface{}, len(v.array))
+		for i, e := range v.array {
+			ep := fmt.Sprintf("%s/%d", pointer, i)
+			if e.kind != scalarValue {
+				return nil, &Error{Pointer: ep, Key: key, Reason: "expected scalar"}
+			}
+			if err := p.term(ep); err != nil {
+				return nil, err
+			}
+			values[i] = e.scalar
+		}
+		if p, ok := f.field.(*jdb.PathField); ok {
+			if op == opIn {
+				return jdb.Contains(*p, values...), nil
+			}
+			return jdb.NotContains(*p, values...), nil
+		}
+		if op == opIn {
+			return jdb.In(f.field, values...), nil
+		}
+		return jdb.NotIn(f.field, values...), nil
+	}
+
+	if v.kind != scalarValue {
+		return nil, &Error{Pointer: pointer, Key: key, Reason: "expected scalar"}
+	}
+	if err := p.term(pointer); err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case opGt, opGte, opLt, opLte:
+		switch v.scalar.(type) {
+		case int64, float64, string:
+		default:
+			return nil, &Error{Pointer: pointer, Key: key, Reason: "expected number or string"}
+		}
+	}
+
+	field := f.compared(v.scalar)
+
+	switch op {
+	case opEq:
+		return jdb.Eq(field, v.scalar), nil
+	case opNe:
+		return jdb.NotEq(field, v.scalar), nil
+	case opGt:
+		return jdb.Gt(field, v.scalar), nil
+	case opGte:
+		return jdb.Gte(field, v.scalar), nil
+	case opLt:
+		return jdb.Lt(field, v.scalar), nil
+	case opLte:
+		return jdb.Lte(field, v.scalar), nil
+	case opLike:
+		if _, ok := v.scalar.(string); !ok {
+			return nil, &Error{Pointer: pointer, Key: key, Reason: "expected string"}
+		}
+		return jdb.Like(f.field, v.scalar), nil
+	default:
+		return nil, &Error{Pointer: pointer, Key: key, Reason: "unknown operator"}
+	}
+}
+
+// compared returns the field to compare with values, document paths are
+// compared as numbers when every value is a number.
+func (f field) compared(values ...interface{}) jdb.WhereField {
+	p, ok := f.field.(*jdb.PathField)
+	if !ok {
+		return f.field
+	}
+	for _, v := range values {
+		switch v.(type) {
+		case int64, float64:
+		default:
+			return f.field
+		}
+	}
+	return p.Numeric()
+}
+
+func escapePointer(key string) string {
+	key = strings.Replace(key, "~", "~0", -1)
+	return strings.Replace(key, "/", "~1", -1)
+}
+
+func pointerKey(pointer string) string {
+	key := pointer[strings.LastIndex(pointer, "/")+1:]
+	key = strings.Replace(key, "~1", "/", -1)
+	return strings.Replace(key, "~0", "~", -1)
+}
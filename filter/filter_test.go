@@ -0,0 +1,205 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/silas/jdb"
+	jdbsqlmock "github.com/silas/jdb/dialect/sqlmock"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func init() {
+	jdb.RegisterDialect(jdbsqlmock.RegisterDialectArgs())
+}
+
+func createMockClient(t *testing.T) (*jdb.Client, sqlmock.Sqlmock) {
+	dsn := fmt.Sprintf("dsn-%d", time.Now().UnixNano())
+	_, mock, err := sqlmock.NewWithDSN(dsn)
+	require.NoError(t, err)
+
+	c, err := jdb.Open("sqlmock", dsn)
+	require.NoError(t, err)
+	return c, mock
+}
+
+func TestFilter_Parse(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	f, err := New(c,
+		Field("Age"),
+		Field("Name.GivenName", "$eq", "$like"),
+		Column("Name.Aliases", c.Index("by_alias"), "$in"),
+		Field("Tags", "$in", "$nin"),
+		Column("id", c.ID, "$eq", "$in"),
+	)
+	require.NoError(t, err)
+
+	age := "CAST(data->'$.Age' AS NUMERIC)"
+	aliases := "SELECT 1 FROM jdb_keys WHERE jdb_keys.kind = jdb.kind AND jdb_keys.id = jdb.id AND " +
+		"jdb_keys.name = ? AND jdb_keys.string_value IN (?)"
+
+	tests := []struct {
+		Filter string
+		Where  string
+		Params []interface{}
+	}{
+		{
+			`{}`,
+			"(1 = 1)",
+			nil,
+		},
+		{
+			`{"Age":34}`,
+			"(" + age + " = ?)",
+			[]interface{}{int64(34)},
+		},
+		{
+			`{"Age":"34"}`,
+			"(data->'$.Age' = ?)",
+			[]interface{}{"34"},
+		},
+		{
+			`{"Age":null}`,
+			"(data->'$.Age' IS NULL)",
+			nil,
+		},
+		{
+			`{"Age":{"$gte":30.5,"$lt":40}}`,
+			"((" + age + " >= ?) AND (" + age + " < ?))",
+			[]interface{}{30.5, int64(40)},
+		},
+		{
+			`{"Age":{"$gte":30},"Name.Aliases":{"$in":["Roe"]}}`,
+			"((" + age + " >= ?) AND (EXISTS (" + aliases + ")))",
+			[]interface{}{int64(30), "by_alias", "Roe"},
+		},
+		{
+			`{"$or":[{"Name.GivenName":{"$like":"J%"}},{"id":{"$in":["1","2"]}}]}`,
+			"((data->'$.Name.GivenName' LIKE ?) OR (id IN (?, ?)))",
+			[]interface{}{"J%", "1", "2"},
+		},
+		{
+			`{"$and":[{"Age":{"$ne":1}},{"$or":[{"Age":{"$nin":[2,3]}},{"Age":{"$gt":"b"}}]}]}`,
+			"((" + age + " != ?) AND ((NOT (JSON_CONTAINS(data->'$.Age', ?) OR JSON_CONTAINS(data->'$.Age', ?))) OR " +
+				"(data->'$.Age' > ?)))",
+			[]interface{}{int64(1), "2", "3", "b"},
+		},
+		{
+			`{"Age":{"$in":[1,"a"]}}`,
+			"(JSON_CONTAINS(data->'$.Age', ?) OR JSON_CONTAINS(data->'$.Age', ?))",
+			[]interface{}{"1", `"a"`},
+		},
+		{
+			`{"Tags":{"$in":["Roe",null]}}`,
+			"(JSON_CONTAINS(data->'$.Tags', ?) OR (data->'$.Tags' IS NULL))",
+			[]interface{}{`"Roe"`},
+		},
+		{
+			`{"Tags":{"$nin":[]}}`,
+			"(1 = 1)",
+			nil,
+		},
+	}
+
+	for i, test := range tests {
+		msg := fmt.Sprintf("Test: %d", i)
+
+		cond, err := f.Parse([]byte(test.Filter))
+		require.NoError(t, err, msg)
+
+		s, p, err := c.Query("user").Where(cond).Select(c.ID).ToSQL()
+		require.NoError(t, err, msg)
+		require.Equal(t, "SELECT id FROM jdb WHERE ((kind = ?) AND "+test.Where+")", s, msg)
+		require.Equal(t, append([]interface{}{"user"}, test.Params...), p, msg)
+	}
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestFilter_Parse_Error(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	f, err := New(c,
+		Field("Age"),
+		Field("Name.GivenName", "$eq"),
+		MaxDepth(2),
+		MaxSize(200),
+		MaxTerms(3),
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		Filter  string
+		Pointer string
+		Key     string
+		Error   string
+	}{
+		{`[]`, "", "", "filter: expected object"},
+		{`{"Age":1} {}`, "", "", "filter: unexpected data after document"},
+		{`{"Age":`, "/Age", "", "filter: /Age: unexpected end of document"},
+		{`{"Email":"a"}`, "/Email", "Email", "filter: /Email: unknown field"},
+		{`{"Age":1,"Age":2}`, "/Age", "Age", "filter: /Age: duplicate key"},
+		{`{"$where":"1"}`, "/$where", "$where", "filter: /$where: unknown operator"},
+		{`{"Age":{"$regex":"1"}}`, "/Age/$regex", "$regex", "filter: /Age/$regex: unknown operator"},
+		{`{"Age":{}}`, "/Age", "Age", "filter: /Age: expected operator"},
+		{`{"Age":{"$gt":[1]}}`, "/Age/$gt", "$gt", "filter: /Age/$gt: expected scalar"},
+		{`{"Age":{"$lte":true}}`, "/Age/$lte", "$lte", "filter: /Age/$lte: expected number or string"},
+		{`{"Age":{"$in":1}}`, "/Age/$in", "$in", "filter: /Age/$in: expected array"},
+		{`{"Age":{"$in":[[1]]}}`, "/Age/$in/0", "$in", "filter: /Age/$in/0: expected scalar"},
+		{`{"Age":{"$like":1}}`, "/Age/$like", "$like", "filter: /Age/$like: expected string"},
+		{`{"Name.GivenName":{"$ne":"a"}}`, "/Name.GivenName/$ne", "$ne",
+			"filter: /Name.GivenName/$ne: operator not allowed"},
+		{`{"$or":[]}`, "/$or", "$or", "filter: /$or: expected non-empty array"},
+		{`{"$or":[{"$and":[{"Age":1}]}]}`, "/$or/0/$and/0", "",
+			"filter: /$or/0/$and/0: document exceeds max depth 2"},
+		{`{"Age":{"$in":[1,2,3,4]}}`, "/Age/$in/3", "", "filter: /Age/$in/3: document exceeds 3 terms"},
+		{`{"Age":[[[[[[[[1]]]]]]]]}`, "/Age/0/0/0/0/0", "", "filter: /Age/0/0/0/0/0: document is nested too deeply"},
+		{`{"Age":"` + strings.Repeat("a", 200) + `"}`, "", "", "filter: document exceeds 200 bytes"},
+	}
+
+	for i, test := range tests {
+		msg := fmt.Sprintf("Test: %d", i)
+
+		_, err := f.Parse([]byte(test.Filter))
+		require.EqualError(t, err, test.Error, msg)
+		e, ok := err.(*Error)
+		require.True(t, ok, msg)
+		require.Equal(t, test.Pointer, e.Pointer, msg)
+		if test.Key != "" {
+			require.Equal(t, test.Key, e.Key, msg)
+		}
+	}
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNew(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	_, err := New(c, Field(""))
+	require.EqualError(t, err, `filter: invalid field name: ""`)
+
+	_, err = New(c, Field("$and"))
+	require.EqualError(t, err, `filter: invalid field name: "$and"`)
+
+	_, err = New(c, Field("Name..GivenName"))
+	require.EqualError(t, err, `filter: invalid field name: "Name..GivenName"`)
+
+	_, err = New(c, Field("Age"), Field("Age"))
+	require.EqualError(t, err, "filter: duplicate field: Age")
+
+	_, err = New(c, Field("Age", "$regex"))
+	require.EqualError(t, err, "filter: unknown operator for Age: $regex")
+
+	_, err = New(c, MaxDepth(0))
+	require.EqualError(t, err, "filter: invalid max depth: 0")
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,67 @@
+package filter
+
+import "github.com/silas/jdb"
+
+// Option configures a Filter.
+type Option interface {
+	filterOption()
+}
+
+type option struct{}
+
+func (o option) filterOption() {}
+
+type optionField struct {
+	option
+	name      string
+	field     jdb.WhereField
+	operators []string
+}
+
+// Field allows filtering on the document path named by a dotted field name
+// (e.g. "Name.GivenName") using the given operators, or all comparison
+// operators when none are given.
+//
+// Paths compare as numbers with numeric values, and as text otherwise. $in
+// and $nin compare values as JSON, matching paths equal to any of the
+// values, or arrays containing any of them (e.g. "Name.Aliases"), other
+// operators compare the value at the path as a whole.
+func Field(name string, operators ...string) Option {
+	return optionField{name: name, operators: operators}
+}
+
+// Column allows filtering on an arbitrary field, such as Client.ID or
+// Client.CreateTime, under the given name.
+func Column(name string, field jdb.WhereField, operators ...string) Option {
+	return optionField{name: name, field: field, operators: operators}
+}
+
+type optionMaxDepth struct {
+	option
+	depth int
+}
+
+// MaxDepth limits how deeply $and and $or documents can be nested.
+func MaxDepth(depth int) Option {
+	return optionMaxDepth{depth: depth}
+}
+
+type optionMaxSize struct {
+	option
+	size int
+}
+
+// MaxSize limits the size of a filter document in bytes.
+func MaxSize(size int) Option {
+	return optionMaxSize{size: size}
+}
+
+type optionMaxTerms struct {
+	option
+	terms int
+}
+
+// MaxTerms limits the total number of values compared in a filter document.
+func MaxTerms(terms int) Option {
+	return optionMaxTerms{terms: terms}
+}
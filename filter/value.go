@@ -0,0 +1,119 @@
+package filter
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/silas/jdb/internal/json"
+)
+
+type valueKind int
+
+const (
+	scalarValue valueKind = iota
+	arrayValue
+	objectValue
+)
+
+type member struct {
+	key   string
+	value *value
+}
+
+type value struct {
+	kind   valueKind
+	scalar interface{}
+	array  []*value
+	object []member
+}
+
+type decoder struct {
+	dec      *json.Decoder
+	maxDepth int
+}
+
+func (d *decoder) value(pointer string, depth int) (*value, error) {
+	token, err := d.dec.Token()
+	if err == io.EOF {
+		return nil, &Error{Pointer: pointer, Reason: "unexpected end of document"}
+	} else if err != nil {
+		return nil, &Error{Pointer: pointer, Reason: err.Error()}
+	}
+
+	switch t := token.(type) {
+	case json.Delim:
+		if depth >= d.maxDepth {
+			return nil, &Error{Pointer: pointer, Reason: "document is nested too deeply"}
+		}
+		switch t {
+		case '{':
+			return d.object(pointer, depth+1)
+		case '[':
+			return d.array(pointer, depth+1)
+		default:
+			return nil, &Error{Pointer: pointer, Reason: fmt.Sprintf("unexpected %s", t)}
+		}
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return &value{kind: scalarValue, scalar: i}, nil
+		}
+		f, err := t.Float64()
+		if err != nil {
+			return nil, &Error{Pointer: pointer, Reason: "invalid number"}
+		}
+		return &value{kind: scalarValue, scalar: f}, nil
+	default:
+		return &value{kind: scalarValue, scalar: t}, nil
+	}
+}
+
+func (d *decoder) object(pointer string, depth int) (*value, error) {
+	v := &value{kind: objectValue}
+	seen := map[string]bool{}
+
+	for d.dec.More() {
+		token, err := d.dec.Token()
+		if err != nil {
+			return nil, &Error{Pointer: pointer, Reason: err.Error()}
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, &Error{Pointer: pointer, Reason: "expected object key"}
+		}
+		mp := pointer + "/" + escapePointer(key)
+		if seen[key] {
+			return nil, &Error{Pointer: mp, Key: key, Reason: "duplicate key"}
+		}
+		seen[key] = true
+
+		mv, err := d.value(mp, depth)
+		if err != nil {
+			return nil, err
+		}
+		v.object = append(v.object, member{key: key, value: mv})
+	}
+
+	if _, err := d.dec.Token(); err != nil {
+		return nil, &Error{Pointer: pointer, Reason: err.Error()}
+	}
+
+	return v, nil
+}
+
+func (d *decoder) array(pointer string, depth int) (*value, error) {
+	v := &value{kind: arrayValue}
+
+	for i := 0; d.dec.More(); i++ {
+		ev, err := d.value(fmt.Sprintf("%s/%d", pointer, i), depth)
+		if err != nil {
+			return nil, err
+		}
+		v.array = append(v.array, ev)
+	}
+
+	if _, err := d.dec.Token(); err != nil {
+		return nil, &Error{Pointer: pointer, Reason: err.Error()}
+	}
+
+	return v, nil
+}
@@ -13,6 +13,12 @@ func params(v ...interface{}) []interface{} {
 	return nil
 }
 
+func whereFieldSQL(t *testing.T, f WhereField) string {
+	w := newSQLWriter(nil, "table")
+	require.NoError(t, f.WhereFieldSQL(w))
+	return w.String()
+}
+
 func setupQuery(t *testing.T) *Query {
 	d, err := Dialect("sqlmock")
 	require.NoError(t, err)
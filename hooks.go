@@ -0,0 +1,233 @@
+package jdb
+
+import (
+	"context"
+	"reflect"
+)
+
+// BeforeInserter is implemented by values that are changed or checked before
+// they are inserted, an error aborts the insert.
+//
+// Hooks with pointer receivers are also called for values passed by value,
+// on a copy that is the one written.
+type BeforeInserter interface {
+	BeforeInsert(ctx context.Context, tx *Tx) error
+}
+
+// AfterInserter is implemented by values that act on being inserted.
+type AfterInserter interface {
+	AfterInsert(ctx context.Context, tx *Tx) error
+}
+
+// BeforeUpdater is implemented by values that are changed or checked before
+// they are updated, an error aborts the update.
+type BeforeUpdater interface {
+	BeforeUpdate(ctx context.Context, tx *Tx) error
+}
+
+// AfterUpdater is implemented by values that act on being updated.
+type AfterUpdater interface {
+	AfterUpdate(ctx context.Context, tx *Tx) error
+}
+
+// BeforeDeleter is implemented by types registered with Register that check
+// documents before they are deleted, an error aborts the delete.
+type BeforeDeleter interface {
+	BeforeDelete(ctx context.Context, tx *Tx) error
+}
+
+// AfterLoader is implemented by values that act on being scanned, which are
+// always pointers.
+type AfterLoader interface {
+	AfterLoad(ctx context.Context, tx *Tx) error
+}
+
+// Hooks are called with the values of a kind, after the methods the values
+// implement, for types that can not implement the hook interfaces.
+type Hooks struct {
+	BeforeInsert func(ctx context.Context, tx *Tx, v interface{}) error
+	AfterInsert  func(ctx context.Context, tx *Tx, v interface{}) error
+	BeforeUpdate func(ctx context.Context, tx *Tx, v interface{}) error
+	AfterUpdate  func(ctx context.Context, tx *Tx, v interface{}) error
+	BeforeDelete func(ctx context.Context, tx *Tx, v interface{}) error
+	AfterLoad    func(ctx context.Context, tx *Tx, v interface{}) error
+}
+
+type hookEvent int
+
+const (
+	beforeInsertHook hookEvent = iota
+	afterInsertHook
+	beforeUpdateHook
+	afterUpdateHook
+	beforeDeleteHook
+	afterLoadHook
+)
+
+var (
+	beforeInserterType = reflect.TypeOf((*BeforeInserter)(nil)).Elem()
+	afterInserterType  = reflect.TypeOf((*AfterInserter)(nil)).Elem()
+	beforeUpdaterType  = reflect.TypeOf((*BeforeUpdater)(nil)).Elem()
+	afterUpdaterType   = reflect.TypeOf((*AfterUpdater)(nil)).Elem()
+	beforeDeleterType  = reflect.TypeOf((*BeforeDeleter)(nil)).Elem()
+	afterLoaderType    = reflect.TypeOf((*AfterLoader)(nil)).Elem()
+)
+
+// hookValue returns a pointer to a copy of v when v is not a pointer and
+// its pointer implements one of the given hooks, so pointer receiver hooks
+// are called and their changes are written.
+func hookValue(v interface{}, types ...reflect.Type) interface{} {
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || rv.Kind() == reflect.Ptr {
+		return v
+	}
+	pt := reflect.PtrTo(rv.Type())
+	for _, t := range types {
+		if !rv.Type().Implements(t) && pt.Implements(t) {
+			p := reflect.New(rv.Type())
+			p.Elem().Set(rv)
+			return p.Interface()
+		}
+	}
+	return v
+}
+
+// RegisterHooks sets the hooks called for documents of kind, replacing any
+// previously registered hooks.
+func (c *Client) RegisterHooks(kind string, hooks Hooks) {
+	c.kinds.mu.Lock()
+	defer c.kinds.mu.Unlock()
+	c.kinds.hooks[kind] = hooks
+}
+
+func (r *kindRegistry) kindHooks(kind string) (Hooks, bool) {
+	if r == nil {
+		return Hooks{}, false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	h, ok := r.hooks[kind]
+	return h, ok
+}
+
+// hook calls the method v implements for event, followed by the hook
+// registered for kind.
+func (r *kindRegistry) hook(ctx context.Context, tx *Tx, kind string, event hookEvent, v interface{}) error {
+	var err error
+	h, _ := r.kindHooks(kind)
+	var fn func(context.Context, *Tx, interface{}) error
+
+	switch event {
+	case beforeInsertHook:
+		if i, ok := v.(BeforeInserter); ok {
+			err = i.BeforeInsert(ctx, tx)
+		}
+		fn = h.BeforeInsert
+	case afterInsertHook:
+		if i, ok := v.(AfterInserter); ok {
+			err = i.AfterInsert(ctx, tx)
+		}
+		fn = h.AfterInsert
+	case beforeUpdateHook:
+		if i, ok := v.(BeforeUpdater); ok {
+			err = i.BeforeUpdate(ctx, tx)
+		}
+		fn = h.BeforeUpdate
+	case afterUpdateHook:
+		if i, ok := v.(AfterUpdater); ok {
+			err = i.AfterUpdate(ctx, tx)
+		}
+		fn = h.AfterUpdate
+	case beforeDeleteHook:
+		if i, ok := v.(BeforeDeleter); ok {
+			err = i.BeforeDelete(ctx, tx)
+		}
+		fn = h.BeforeDelete
+	case afterLoadHook:
+		if i, ok := v.(AfterLoader); ok {
+			err = i.AfterLoad(ctx, tx)
+		}
+		fn = h.AfterLoad
+	}
+
+	if err == nil && fn != nil {
+		err = fn(ctx, tx, v)
+	}
+	return err
+}
+
+// loadHooks reports whether scanning into dest, a pointer to a struct or a
+// slice of them, calls AfterLoad hooks for documents of kind. For the empty
+// kind a hook registered for any kind counts.
+func (r *kindRegistry) loadHooks(kind string, dest reflect.Type) bool {
+	t := dest
+	for t != nil && (t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice) {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return false
+	}
+	if implementsAny(t, afterLoaderType) {
+		return true
+	}
+	if r == nil {
+		return false
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if kind != "" {
+		return r.hooks[kind].AfterLoad != nil
+	}
+	for _, h := range r.hooks {
+		if h.AfterLoad != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// afterLoad calls the AfterLoad hooks of values, addressable structs scanned
+// from rows of the given kinds, once their rows are closed.
+func (r *kindRegistry) afterLoad(ctx context.Context, tx *Tx, kinds []string, values []reflect.Value) error {
+	for i, kind := range kinds {
+		if err := r.hook(ctx, tx, kind, afterLoadHook, values[i].Addr().Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// beforeDelete scans the documents matching wb into the type registered for
+// the kind, or a Document, and calls their delete hooks.
+func (b *DeleteBuilder) beforeDelete(ctx context.Context, tx *Tx) error {
+	h, _ := b.q.kinds.kindHooks(b.q.kind)
+
+	var t reflect.Type
+	if b.q.kinds != nil {
+		b.q.kinds.mu.RLock()
+		t = b.q.kinds.names[b.q.kind]
+		b.q.kinds.mu.RUnlock()
+	}
+	if t == nil || !implementsAny(t, beforeDeleterType) {
+		if h.BeforeDelete == nil {
+			return nil
+		}
+		if t == nil {
+			t = reflect.TypeOf(Document{})
+		}
+	}
+
+	values := reflect.New(reflect.SliceOf(reflect.PtrTo(t)))
+	if err := b.wb.Select().All(ctx, tx, values.Interface()); err != nil {
+		return err
+	}
+
+	values = values.Elem()
+	for i := 0; i < values.Len(); i++ {
+		if err := b.q.kinds.hook(ctx, tx, b.q.kind, beforeDeleteHook, values.Index(i).Interface()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,235 @@
+package jdb
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/silas/jdb/internal/ptr"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+var hookColumns = []string{"kind", "id", "parent_kind", "parent_id", "data", "create_time", "update_time"}
+
+type hookUser struct {
+	ID    string `jdb:"-id"`
+	Email string `jdb:"email"`
+
+	events []string
+}
+
+func (u *hookUser) BeforeInsert(ctx context.Context, tx *Tx) error {
+	u.events = append(u.events, "before insert")
+	u.Email = strings.ToLower(u.Email)
+	if u.Email == "" {
+		return errors.New("email required")
+	}
+	return nil
+}
+
+func (u *hookUser) AfterInsert(ctx context.Context, tx *Tx) error {
+	u.events = append(u.events, "after insert")
+	return nil
+}
+
+func (u *hookUser) BeforeUpdate(ctx context.Context, tx *Tx) error {
+	u.events = append(u.events, "before update")
+	return nil
+}
+
+func (u *hookUser) AfterUpdate(ctx context.Context, tx *Tx) error {
+	u.events = append(u.events, "after update")
+	return nil
+}
+
+func (u *hookUser) BeforeDelete(ctx context.Context, tx *Tx) error {
+	if u.Email == "admin@example.com" {
+		return errors.New("admin can not be deleted")
+	}
+	return nil
+}
+
+func (u *hookUser) AfterLoad(ctx context.Context, tx *Tx) error {
+	u.events = append(u.events, "after load")
+	return nil
+}
+
+func TestHooks_Methods(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	require.NoError(t, c.Register(hookUser{}, KindName("user")))
+
+	ctx := context.Background()
+	users := c.Query("user")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO jdb").
+		WithArgs("user", "1", nil, nil, nil, nil, nil, nil, ptr.String(`{"email":"a@example.com"}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE jdb SET").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT kind, id, .* FROM jdb WHERE").
+		WithArgs("user", "1").
+		WillReturnRows(sqlmock.NewRows(hookColumns).
+			AddRow("user", "1", nil, nil, `{"email":"admin@example.com"}`, nil, nil))
+	mock.ExpectRollback()
+
+	require.NoError(t, c.Tx(ctx, func(tx *Tx) error {
+		u := &hookUser{ID: "1", Email: "A@example.com"}
+		require.NoError(t, users.Insert(u).Exec(ctx, tx))
+		require.Equal(t, "a@example.com", u.Email)
+		require.Equal(t, []string{"before insert", "after insert"}, u.events)
+
+		u.events = nil
+		require.NoError(t, users.Update(u).Exec(ctx, tx))
+		require.Equal(t, []string{"before update", "after update"}, u.events)
+
+		err := users.Insert(&hookUser{ID: "2"}).Exec(ctx, tx)
+		require.EqualError(t, err, "email required")
+
+		err = users.Delete("1").Exec(ctx, tx)
+		require.EqualError(t, err, "admin can not be deleted")
+
+		return nil
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHooks_Value(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	require.NoError(t, c.Register(hookUser{}, KindName("user")))
+
+	ctx := context.Background()
+	users := c.Query("user")
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO jdb").
+		WithArgs("user", "1", nil, nil, nil, nil, nil, nil, ptr.String(`{"email":"a@example.com"}`)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	require.NoError(t, c.Tx(ctx, func(tx *Tx) error {
+		u := hookUser{ID: "1", Email: "A@example.com"}
+		require.NoError(t, users.Insert(u).Exec(ctx, tx))
+		require.Equal(t, "A@example.com", u.Email)
+
+		err := users.Insert(hookUser{ID: "2"}).Exec(ctx, tx)
+		require.EqualError(t, err, "email required")
+
+		return nil
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHooks_Registered(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	var events []string
+	record := func(event string) func(context.Context, *Tx, interface{}) error {
+		return func(ctx context.Context, tx *Tx, v interface{}) error {
+			events = append(events, event+" "+v.(*Document).ID)
+			return nil
+		}
+	}
+	c.RegisterHooks("doc", Hooks{
+		AfterInsert: record("after insert"),
+		AfterLoad:   record("after load"),
+		BeforeDelete: func(ctx context.Context, tx *Tx, v interface{}) error {
+			events = append(events, "before delete "+v.(*Document).ID)
+			return errors.New("delete not allowed")
+		},
+	})
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO jdb").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT kind, id, .* FROM jdb WHERE").
+		WithArgs("doc").
+		WillReturnRows(sqlmock.NewRows(hookColumns).
+			AddRow("doc", "1", nil, nil, nil, nil, nil).
+			AddRow("doc", "2", nil, nil, nil, nil, nil))
+	mock.ExpectRollback()
+
+	require.NoError(t, c.Tx(ctx, func(tx *Tx) error {
+		require.NoError(t, c.Query("doc").Insert(&Document{ID: "1"}).Exec(ctx, tx))
+
+		err := c.Query("doc").Where().Delete().Exec(ctx, tx)
+		require.EqualError(t, err, "delete not allowed")
+
+		return nil
+	}))
+
+	require.Equal(t, []string{"after insert 1", "after load 1", "after load 2", "before delete 1"}, events)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestHooks_AfterLoadIncluded(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	ctx := context.Background()
+	now := time.Date(2005, 3, 7, 8, 23, 34, 0, time.UTC)
+
+	var events []string
+	record := func(ctx context.Context, tx *Tx, v interface{}) error {
+		switch v := v.(type) {
+		case *includePost:
+			events = append(events, "post "+v.ID)
+		case *includeComment:
+			events = append(events, "comment "+v.ID)
+		case *refOrder:
+			events = append(events, "order "+v.ID)
+		case *refProduct:
+			events = append(events, "product "+v.ID)
+		}
+		return nil
+	}
+	for _, kind := range []string{"post", "comment", "order", "product"} {
+		c.RegisterHooks(kind, Hooks{AfterLoad: record})
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT .* FROM jdb WHERE \(\(kind = \?\)\)`).
+		WithArgs("post").
+		WillReturnRows(sqlmock.NewRows(hookColumns).
+			AddRow("post", "1", nil, nil, `{"title":"One"}`, now, now))
+	mock.ExpectQuery(`SELECT .* FROM jdb WHERE \(\(kind = \?\) AND .*parent_kind`).
+		WithArgs("comment", "post", "1").
+		WillReturnRows(sqlmock.NewRows(hookColumns).
+			AddRow("comment", "2", "post", "1", `{"body":"Two"}`, now, now))
+	mock.ExpectQuery(`SELECT .* FROM jdb WHERE \(\(kind = \?\)\)`).
+		WithArgs("order").
+		WillReturnRows(sqlmock.NewRows(hookColumns).
+			AddRow("order", "3", nil, nil, `{"productId":"4"}`, now, now))
+	mock.ExpectQuery(`SELECT .* FROM jdb WHERE \(\(kind = \?\) AND \(id IN \(\?\)\)\)`).
+		WithArgs("product", "4").
+		WillReturnRows(sqlmock.NewRows(hookColumns).
+			AddRow("product", "4", nil, nil, `{"name":"Four"}`, now, now))
+	mock.ExpectRollback()
+
+	require.NoError(t, c.Tx(ctx, func(tx *Tx) error {
+		var post includePost
+		require.NoError(t, c.Query("post").Select().Include("comment").First(ctx, tx, &post))
+		require.Len(t, post.Comments, 1)
+
+		var orders []refOrder
+		require.NoError(t, c.Query("order").Select().Preload("Product").All(ctx, tx, &orders))
+		require.Len(t, orders, 1)
+
+		return nil
+	}))
+
+	require.Equal(t, []string{"comment 2", "post 1", "product 4", "order 3"}, events)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,245 @@
+package jdb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/silas/jdb/internal/json"
+)
+
+type relation struct {
+	index    int
+	children bool
+	kind     string
+	elem     reflect.Type
+	ptr      bool
+}
+
+// relations returns the struct fields tagged with a children or parent
+// option, keyed by the name used to include them: the child kind for
+// children and "parent" for the parent.
+func relations(t reflect.Type) (map[string]relation, error) {
+	rels := map[string]relation{}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+		tag := field.Tag.Get(tagName)
+		if tag == "" {
+			continue
+		}
+		_, opts := json.ParseTag(tag)
+
+		if kind, ok := opts.Value(childrenTag); ok {
+			ft := field.Type
+			if ft.Kind() != reflect.Slice {
+				return nil, fmt.Errorf("%s must be a slice of structs", field.Name)
+			}
+			r := relation{index: i, children: true, kind: kind, elem: ft.Elem()}
+			if r.elem.Kind() == reflect.Ptr {
+				r.elem = r.elem.Elem()
+				r.ptr = true
+			}
+			if kind == "" || r.elem.Kind() != reflect.Struct {
+				return nil, fmt.Errorf("%s must be a slice of structs", field.Name)
+			}
+			if _, ok := rels[kind]; ok {
+				return nil, fmt.Errorf("has duplicate children: %s", kind)
+			}
+			rels[kind] = r
+		} else if opts.Contains(parentTag) {
+			r := relation{index: i, elem: field.Type}
+			if r.elem.Kind() == reflect.Ptr {
+				r.elem = r.elem.Elem()
+				r.ptr = true
+			}
+			if r.elem.Kind() != reflect.Struct {
+				return nil, fmt.Errorf("%s must be a struct", field.Name)
+			}
+			if _, ok := rels[parentTag]; ok {
+				return nil, fmt.Errorf("has duplicate parents")
+			}
+			rels[parentTag] = r
+		}
+	}
+	return rels, nil
+}
+
+func (b *SelectBuilder) hasColumns(columns ...SelectField) bool {
+	for _, c := range columns {
+		found := false
+		for _, v := range b.columns {
+			if v == c {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// include loads the included relations of values, which are the scanned
+// structs with their keys, using one query per relation.
+func (b *SelectBuilder) include(ctx context.Context, tx *Tx, t reflect.Type, values []reflect.Value,
+	keys []rowKey) error {
+
+	if len(b.includes) == 0 {
+		return nil
+	}
+	rels, err := relations(t)
+	if err != nil {
+		return fmt.Errorf("include: %s", err)
+	}
+
+	for _, name := range b.includes {
+		r, ok := rels[name]
+		if !ok {
+			return fmt.Errorf("include: unknown relation: %s", name)
+		}
+		if len(values) == 0 {
+			continue
+		}
+		if r.children {
+			err = b.includeChildren(ctx, tx, r, values, keys)
+		} else {
+			err = b.includeParent(ctx, tx, r, values, keys)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// keyConditions returns a condition matching the given kind and id pairs.
+func keyConditions(kindField, idField WhereField, keys map[string][]interface{}, order []string) Condition {
+	conditions := make([]Condition, len(order))
+	for i, kind := range order {
+		conditions[i] = And(Eq(kindField, kind), In(idField, keys[kind]...))
+	}
+	return Or(conditions...)
+}
+
+func (b *SelectBuilder) includeChildren(ctx context.Context, tx *Tx, r relation, values []reflect.Value,
+	keys []rowKey) error {
+
+	if !b.hasColumns(kindField, idField) {
+		return fmt.Errorf("include: %s requires kind and id to be selected", r.kind)
+	}
+
+	ids := map[string][]interface{}{}
+	var kinds []string
+	positions := map[[2]string][]int{}
+	for i, key := range keys {
+		k := [2]string{key.kind, key.id}
+		if _, ok := positions[k]; !ok {
+			if _, ok := ids[key.kind]; !ok {
+				kinds = append(kinds, key.kind)
+			}
+			ids[key.kind] = append(ids[key.kind], key.id)
+		}
+		positions[k] = append(positions[k], i)
+	}
+
+	q := b.q.subQuery(r.kind)
+	sb := q.Where(keyConditions(parentKindField, parentIdField, ids, kinds)).Select().
+		OrderBy(createTimeField.Asc(), idField.Asc())
+
+	return b.scanIncluded(ctx, tx, sb, r, func(e reflect.Value, key rowKey) {
+		for _, i := range positions[[2]string{key.parentKind, key.parentID}] {
+			field := values[i].Field(r.index)
+			if r.ptr {
+				field.Set(reflect.Append(field, e))
+			} else {
+				field.Set(reflect.Append(field, e.Elem()))
+			}
+		}
+	})
+}
+
+func (b *SelectBuilder) includeParent(ctx context.Context, tx *Tx, r relation, values []reflect.Value,
+	keys []rowKey) error {
+
+	if !b.hasColumns(parentKindField, parentIdField) {
+		return fmt.Errorf("include: %s requires parent kind and id to be selected", parentTag)
+	}
+
+	ids := map[string][]interface{}{}
+	var kinds []string
+	positions := map[[2]string][]int{}
+	for i, key := range keys {
+		if key.parentKind == "" || key.parentID == "" {
+			continue
+		}
+		k := [2]string{key.parentKind, key.parentID}
+		if _, ok := positions[k]; !ok {
+			if _, ok := ids[key.parentKind]; !ok {
+				kinds = append(kinds, key.parentKind)
+			}
+			ids[key.parentKind] = append(ids[key.parentKind], key.parentID)
+		}
+		positions[k] = append(positions[k], i)
+	}
+	if len(kinds) == 0 {
+		return nil
+	}
+
+	q := b.q.subQuery("")
+	wb := &WhereBuilder{q: q}
+	sb := newSelectBuilder(q, wb.Where(keyConditions(kindField, idField, ids, kinds)), defaultSelectColumns)
+
+	return b.scanIncluded(ctx, tx, sb, r, func(e reflect.Value, key rowKey) {
+		for _, i := range positions[[2]string{key.kind, key.id}] {
+			field := values[i].Field(r.index)
+			if r.ptr {
+				field.Set(e)
+			} else {
+				field.Set(e.Elem())
+			}
+		}
+	})
+}
+
+func (b *SelectBuilder) scanIncluded(ctx context.Context, tx *Tx, sb *SelectBuilder, r relation,
+	set func(e reflect.Value, key rowKey)) error {
+
+	rows, err := sb.Rows(ctx, tx)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var keys []rowKey
+	var kinds []string
+	rows.keys = &keys
+	rows.loadKinds = &kinds
+
+	var elems []reflect.Value
+	for rows.Next() {
+		e := reflect.New(r.elem)
+		if err := rows.scan(e); err != nil {
+			return err
+		}
+		elems = append(elems, e.Elem())
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	if err := b.q.kinds.afterLoad(ctx, tx, kinds, elems); err != nil {
+		return err
+	}
+	for i, e := range elems {
+		set(e.Addr(), keys[i])
+	}
+	return nil
+}
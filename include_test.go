@@ -0,0 +1,138 @@
+package jdb
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/silas/jdb/internal/json"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+type includeComment struct {
+	ID   string       `jdb:"-id"`
+	Body string       `jdb:"body"`
+	Post *includePost `jdb:",parent"`
+}
+
+type includePost struct {
+	ID       string           `jdb:"-id"`
+	Title    string           `jdb:"title"`
+	Comments []includeComment `jdb:",children=comment"`
+}
+
+func TestSelectBuilder_Include(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	columns := []string{"kind", "id", "parent_kind", "parent_id", "data", "create_time", "update_time"}
+	now := time.Date(2005, 3, 7, 8, 23, 34, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT kind, id, parent_kind, parent_id, data, create_time, update_time ` +
+		`FROM jdb WHERE ((kind = ?))`)).
+		WithArgs("post").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("post", "1", nil, nil, `{"title":"One"}`, now, now).
+			AddRow("post", "2", nil, nil, `{"title":"Two"}`, now, now))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT kind, id, parent_kind, parent_id, data, create_time, update_time `+
+		`FROM jdb WHERE ((kind = ?) AND (((parent_kind = ?) AND (parent_id IN (?, ?))))) `+
+		`ORDER BY create_time ASC, id ASC`)).
+		WithArgs("comment", "post", "1", "2").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("comment", "3", "post", "2", `{"body":"Three"}`, now, now).
+			AddRow("comment", "4", "post", "2", `{"body":"Four"}`, now, now))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT kind, id, parent_kind, parent_id, data, create_time, update_time `+
+		`FROM jdb WHERE ((kind = ?) AND (id = ?))`)).
+		WithArgs("comment", "3").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("comment", "3", "post", "2", `{"body":"Three"}`, now, now))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT kind, id, parent_kind, parent_id, data, create_time, update_time `+
+		`FROM jdb WHERE ((((kind = ?) AND (id IN (?)))))`)).
+		WithArgs("post", "2").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("post", "2", nil, nil, `{"title":"Two"}`, now, now))
+	mock.ExpectCommit()
+
+	ctx := context.Background()
+
+	require.NoError(t, c.Tx(ctx, func(tx *Tx) error {
+		var posts []includePost
+		err := c.Query("post").Select().Include("comment").All(ctx, tx, &posts)
+		require.NoError(t, err)
+		require.Equal(t, []includePost{
+			{ID: "1", Title: "One"},
+			{ID: "2", Title: "Two", Comments: []includeComment{{ID: "3", Body: "Three"}, {ID: "4", Body: "Four"}}},
+		}, posts)
+
+		var comment includeComment
+		err = c.Query("comment").Get("3").Select().Include("parent").First(ctx, tx, &comment)
+		require.NoError(t, err)
+		require.Equal(t, includeComment{ID: "3", Body: "Three", Post: &includePost{ID: "2", Title: "Two"}}, comment)
+
+		return tx.Commit()
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelectBuilder_Include_Error(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	columns := []string{"kind", "id", "parent_kind", "parent_id", "data", "create_time", "update_time"}
+	now := time.Date(2005, 3, 7, 8, 23, 34, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT .*`).
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("post", "1", nil, nil, nil, now, now))
+	mock.ExpectQuery(`SELECT .*`).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow("1"))
+	mock.ExpectCommit()
+
+	ctx := context.Background()
+
+	require.NoError(t, c.Tx(ctx, func(tx *Tx) error {
+		var posts []includePost
+		err := c.Query("post").Select().Include("tag").All(ctx, tx, &posts)
+		require.EqualError(t, err, "include: unknown relation: tag")
+
+		err = c.Query("post").Select(c.ID).Include("comment").All(ctx, tx, &posts)
+		require.EqualError(t, err, "include: comment requires kind and id to be selected")
+
+		return tx.Commit()
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRelations(t *testing.T) {
+	rels, err := relations(reflect.TypeOf(includePost{}))
+	require.NoError(t, err)
+	require.Equal(t, map[string]relation{
+		"comment": {index: 2, children: true, kind: "comment", elem: reflect.TypeOf(includeComment{})},
+	}, rels)
+
+	rels, err = relations(reflect.TypeOf(includeComment{}))
+	require.NoError(t, err)
+	require.Equal(t, map[string]relation{
+		"parent": {index: 2, elem: reflect.TypeOf(includePost{}), ptr: true},
+	}, rels)
+
+	_, err = relations(reflect.TypeOf(struct {
+		Comments []string `jdb:",children=comment"`
+	}{}))
+	require.EqualError(t, err, "Comments must be a slice of structs")
+
+	_, err = relations(reflect.TypeOf(struct {
+		Post string `jdb:",parent"`
+	}{}))
+	require.EqualError(t, err, "Post must be a struct")
+
+	data, err := json.Marshal(includePost{ID: "1", Title: "One", Comments: []includeComment{{ID: "2"}}})
+	require.NoError(t, err)
+	require.Equal(t, `{"title":"One"}`, string(data))
+}
@@ -0,0 +1,405 @@
+package jdb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+const maxKeyName = 64
+
+type key struct {
+	Name       string
+	String     *string
+	Numeric    *float64
+	Time       *time.Time
+	Unique     *string
+	Scope      string
+	ScopeValue string
+}
+
+// scopeValue returns the value unique keys are scoped to, parent scoped keys
+// use the parent of the row.
+func (k key) scopeValue(r *row) string {
+	if k.Scope != parentTag {
+		return k.ScopeValue
+	}
+	if r.ParentKind == nil || r.ParentID == nil {
+		return ""
+	}
+	return *r.ParentKind + "/" + *r.ParentID
+}
+
+// keyError names the unique key in a unique constraint violation.
+func keyError(err error, k key) error {
+	if e, ok := err.(*UniqueError); ok {
+		e.Key = k.Name
+	}
+	return err
+}
+
+func keysTable(table string) string {
+	return table + "_keys"
+}
+
+// newKey converts an indexed field value into a key, it returns nil when the
+// value converts to nil.
+func newKey(name string, unique bool, value reflect.Value) (*key, error) {
+	if name == "" || len(name) > maxKeyName {
+		return nil, fmt.Errorf("index name is invalid: %q", name)
+	}
+
+	kv, err := keyValue(value)
+	if err != nil {
+		return nil, fmt.Errorf("index %s is invalid: %v: %w", name, value, err)
+	}
+
+	k := &key{Name: name}
+	var s string
+
+	switch v := kv.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		s = v
+		if len(s) > maxStringKey {
+			return nil, fmt.Errorf("index %s max length 255 characters: %s (%d)", name, s, len(s))
+		}
+		k.String = &s
+	case float64:
+		k.Numeric = &v
+		s = strconv.FormatFloat(v, 'g', -1, 64)
+	case time.Time:
+		// sqlite compares times as strings, so they're all stored in UTC
+		v = v.UTC()
+		k.Time = &v
+		s = v.Format(time.RFC3339Nano)
+	}
+
+	if unique {
+		k.Unique = &s
+	}
+
+	return k, nil
+}
+
+// newKeys converts an indexed field value into keys, slices and arrays get
+// one key per distinct element.
+func newKeys(name string, unique bool, value reflect.Value) ([]key, error) {
+	multi := (value.Kind() == reflect.Slice || value.Kind() == reflect.Array) &&
+		value.Type().Elem().Kind() != reflect.Uint8 && !isEncodedKey(value)
+	if !multi {
+		k, err := newKey(name, unique, value)
+		if err != nil || k == nil {
+			return nil, err
+		}
+		return []key{*k}, nil
+	}
+
+	var keys []key
+	seen := map[string]bool{}
+	for i := 0; i < value.Len(); i++ {
+		k, err := newKey(name, true, value.Index(i))
+		if err != nil {
+			return nil, err
+		}
+		if k == nil || seen[*k.Unique] {
+			continue
+		}
+		seen[*k.Unique] = true
+		if !unique {
+			k.Unique = nil
+		}
+		keys = append(keys, *k)
+	}
+	return keys, nil
+}
+
+// column returns the keys table column holding the value.
+func (k *key) column() (string, interface{}) {
+	switch {
+	case k.String != nil:
+		return "string_value", *k.String
+	case k.Numeric != nil:
+		return "numeric_value", *k.Numeric
+	default:
+		return "time_value", *k.Time
+	}
+}
+
+// IndexField is a key from the keys table, it compares string values unless
+// changed using Numeric or Time.
+//
+// Conditions on the field are written as semi-joins on the keys table, so
+// they can use its indexes. They match documents with at least one key
+// satisfying them, so Eq on a multi-valued index matches documents
+// containing the value, and NotIn those containing none of the values.
+// Orders use the least key value ascending and the greatest descending.
+type IndexField struct {
+	name      string
+	column    string
+	aggregate string
+}
+
+// Index returns a field for the index or unique key with the given name.
+func (c *Client) Index(name string) IndexField {
+	return IndexField{name: name, column: "string_value", aggregate: "min"}
+}
+
+func (f IndexField) Numeric() IndexField {
+	f.column = "numeric_value"
+	return f
+}
+
+func (f IndexField) Time() IndexField {
+	f.column = "time_value"
+	return f
+}
+
+// writeKeys writes the keys table subquery selecting expr from the keys of
+// the outer row.
+func (f IndexField) writeKeys(w *SQLWriter, expr string) error {
+	if w.table == "" {
+		return fmt.Errorf("index: table not defined")
+	}
+
+	table := keysTable(w.table)
+	outer := w.Table()
+
+	w.WriteString("SELECT " + expr + " FROM " + table)
+	w.WriteString(" WHERE " + table + ".kind = " + outer + ".kind")
+	w.WriteString(" AND " + table + ".id = " + outer + ".id")
+	w.WriteString(" AND " + table + ".name = ")
+	w.WriteParam(f.name)
+
+	return nil
+}
+
+func (f IndexField) WhereFieldSQL(w *SQLWriter) error {
+	table := keysTable(w.table)
+	w.WriteString("(")
+	if err := f.writeKeys(w, f.aggregate+"("+table+"."+f.column+")"); err != nil {
+		return err
+	}
+	w.WriteString(")")
+	return nil
+}
+
+// comparisonSQL writes "(EXISTS (... AND column op ?))", which matches rows
+// with at least one key satisfying the comparison, or for nil values the
+// rows with (IS NOT NULL) or without (IS NULL) keys. NOT IN matches rows
+// without a key in values.
+func (f IndexField) comparisonSQL(w *SQLWriter, op string, values []interface{}) error {
+	column := keysTable(w.table) + "." + f.column
+
+	exists := "(EXISTS ("
+	if op == "IS NULL" || op == "NOT IN" {
+		exists = "(NOT EXISTS ("
+	}
+	w.WriteString(exists)
+	if err := f.writeKeys(w, "1"); err != nil {
+		return err
+	}
+
+	switch op {
+	case "IS NULL", "IS NOT NULL":
+	case "IN", "NOT IN":
+		w.WriteString(" AND " + column + " IN (")
+		w.WriteString(placeholders(len(values)))
+		w.WriteString(")")
+		for _, v := range values {
+			w.AddParams(utcTime(v))
+		}
+	default:
+		w.WriteString(" AND " + column + " " + op)
+		for _, v := range values {
+			w.WriteString(" ")
+			w.WriteParam(utcTime(v))
+		}
+	}
+	w.WriteString("))")
+
+	return nil
+}
+
+// utcTime returns time values in UTC, as they're stored in the keys table.
+func utcTime(v interface{}) interface{} {
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC()
+	case *time.Time:
+		if t != nil {
+			return t.UTC()
+		}
+	}
+	return v
+}
+
+func (f IndexField) Asc() Order {
+	f.aggregate = "min"
+	return Order{f, false}
+}
+
+func (f IndexField) Desc() Order {
+	f.aggregate = "max"
+	return Order{f, true}
+}
+
+type keysCondition struct {
+	all    bool
+	name   string
+	values []interface{}
+}
+
+// AnyKey matches documents with at least one of the values in the
+// multi-valued index with the given name.
+func AnyKey(name string, values ...interface{}) Condition {
+	return keysCondition{false, name, values}
+}
+
+// AllKeys matches documents with every one of the values in the
+// multi-valued index with the given name.
+func AllKeys(name string, values ...interface{}) Condition {
+	return keysCondition{true, name, values}
+}
+
+func (c keysCondition) ConditionSQL(w *SQLWriter) error {
+	if w.table == "" {
+		return fmt.Errorf("index: table not defined")
+	}
+
+	column := ""
+	var values []interface{}
+	seen := map[interface{}]bool{}
+	for _, v := range c.values {
+		k, err := newKey(c.name, false, reflect.ValueOf(v))
+		if err != nil {
+			return err
+		}
+		if k == nil {
+			continue
+		}
+		col, value := k.column()
+		if column != "" && col != column {
+			return fmt.Errorf("index %s has mixed value types", c.name)
+		}
+		column = col
+		if !seen[value] {
+			seen[value] = true
+			values = append(values, value)
+		}
+	}
+
+	if len(values) == 0 {
+		if c.all {
+			w.WriteString(trueCondition)
+		} else {
+			w.WriteString(falseCondition)
+		}
+		return nil
+	}
+
+	table := keysTable(w.table)
+	outer := w.Table()
+
+	if c.all {
+		w.WriteString("((SELECT count(DISTINCT " + table + "." + column + ") FROM " + table)
+	} else {
+		w.WriteString("(EXISTS (SELECT 1 FROM " + table)
+	}
+	w.WriteString(" WHERE " + table + ".kind = " + outer + ".kind")
+	w.WriteString(" AND " + table + ".id = " + outer + ".id")
+	w.WriteString(" AND " + table + ".name = ")
+	w.WriteParam(c.name)
+	w.WriteString(" AND " + table + "." + column + " IN (")
+	for i, v := range values {
+		if i > 0 {
+			w.WriteString(", ")
+		}
+		w.WriteParam(v)
+	}
+	if c.all {
+		w.WriteString(")) = ")
+		w.WriteParam(len(values))
+		w.WriteString(")")
+	} else {
+		w.WriteString(")))")
+	}
+
+	return nil
+}
+
+const keysColumnsSQL = " (kind, id, name, string_value, numeric_value, time_value, unique_value, scope, scope_value) "
+
+// insertKeys adds keys for rows that were just inserted, unique keys are
+// inserted one at a time so violations name the key.
+func insertKeys(ctx context.Context, tx *Tx, q *Query, rows []*row) error {
+	w := newSQLWriter(q.d, q.table)
+	n := 0
+	for _, r := range rows {
+		for _, k := range r.Keys {
+			if k.Unique != nil {
+				uw := newSQLWriter(q.d, q.table)
+				uw.WriteString("INSERT INTO " + keysTable(q.table) + keysColumnsSQL)
+				uw.WriteString("VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")
+				uw.AddParams(r.Kind, r.ID, k.Name, k.String, k.Numeric, k.Time, k.Unique, k.Scope, k.scopeValue(r))
+				query, params := uw.toSQL()
+				if _, err := tx.exec(ctx, sqlQuery{query, params}); err != nil {
+					return keyError(err, k)
+				}
+				continue
+			}
+			if n == 0 {
+				w.WriteString("INSERT INTO " + keysTable(q.table) + keysColumnsSQL + "VALUES ")
+			} else {
+				w.WriteString(", ")
+			}
+			w.WriteString("(?, ?, ?, ?, ?, ?, ?, ?, ?)")
+			w.AddParams(r.Kind, r.ID, k.Name, k.String, k.Numeric, k.Time, k.Unique, k.Scope, k.scopeValue(r))
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+
+	query, params := w.toSQL()
+	_, err := tx.exec(ctx, sqlQuery{query, params})
+	return err
+}
+
+// replaceKeys replaces the keys of the updated document matching wb.
+func replaceKeys(ctx context.Context, tx *Tx, q *Query, wb *WhereBuilder, r *row) error {
+	if err := deleteSide(ctx, tx, q, wb, keysTable(q.table)); err != nil {
+		return err
+	}
+
+	for _, k := range r.Keys {
+		w := newSQLWriter(q.d, q.table)
+		w.WriteString("INSERT INTO " + keysTable(q.table) + keysColumnsSQL)
+		w.WriteString("SELECT kind, id, ?, ?, ?, ?, ?, ?, ? FROM " + q.table + " ")
+		w.AddParams(k.Name, k.String, k.Numeric, k.Time, k.Unique, k.Scope, k.scopeValue(r))
+		if err := wb.toWhereSQL(w); err != nil {
+			return err
+		}
+		query, params := w.toSQL()
+		if _, err := tx.exec(ctx, sqlQuery{query, params}); err != nil {
+			return keyError(err, k)
+		}
+	}
+
+	return nil
+}
+
+// moveKeys updates the scope of parent scoped keys after a move.
+func moveKeys(ctx context.Context, tx *Tx, q *Query, id string, r *row) error {
+	w := newSQLWriter(q.d, q.table)
+	w.WriteString("UPDATE " + keysTable(q.table) + " SET scope_value = ? ")
+	w.WriteString("WHERE kind = ? AND id = ? AND scope = ?")
+	w.AddParams(key{Scope: parentTag}.scopeValue(r), q.kind, id, parentTag)
+	query, params := w.toSQL()
+	_, err := tx.exec(ctx, sqlQuery{query, params})
+	return err
+}
@@ -0,0 +1,307 @@
+package jdb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/silas/jdb/internal/ptr"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+type indexUser struct {
+	ID     string `jdb:"-id"`
+	Email  string `jdb:"email,unique=by_email"`
+	Domain string `jdb:"domain,index=by_domain"`
+	Age    int    `jdb:"age,index=by_age,omitempty"`
+}
+
+func TestIndexField(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	keys := "FROM jdb_keys WHERE jdb_keys.kind = jdb.kind AND jdb_keys.id = jdb.id AND jdb_keys.name = ?"
+
+	tests := []struct {
+		Builder QueryBuilder
+		Query   string
+		Params  []interface{}
+	}{
+		{
+			c.Query("user").Where(Eq(c.Index("by_domain"), "example.com")).Select(c.ID),
+			"SELECT id FROM jdb WHERE ((kind = ?) AND (EXISTS (SELECT 1 " + keys +
+				" AND jdb_keys.string_value = ?)))",
+			params("user", "by_domain", "example.com"),
+		},
+		{
+			c.Query("user").Where(Gt(c.Index("by_age").Numeric(), 30)).Select(c.ID).
+				OrderBy(c.Index("by_time").Time().Desc()),
+			"SELECT id FROM jdb WHERE ((kind = ?) AND (EXISTS (SELECT 1 " + keys +
+				" AND jdb_keys.numeric_value > ?))) ORDER BY (SELECT max(jdb_keys.time_value) " + keys + ") DESC",
+			params("user", "by_age", 30, "by_time"),
+		},
+		{
+			c.Query("user").Where(In(c.Index("by_tag"), "a", nil, "b")).Select(c.ID).
+				OrderBy(c.Index("by_tag").Asc()),
+			"SELECT id FROM jdb WHERE ((kind = ?) AND ((NOT EXISTS (SELECT 1 " + keys + ")) OR " +
+				"(EXISTS (SELECT 1 " + keys + " AND jdb_keys.string_value IN (?, ?))))) " +
+				"ORDER BY (SELECT min(jdb_keys.string_value) " + keys + ") ASC",
+			params("user", "by_tag", "by_tag", "a", "b", "by_tag"),
+		},
+		{
+			c.Query("user").Where(NotIn(c.Index("by_tag"), "a")).Select(c.ID),
+			"SELECT id FROM jdb WHERE ((kind = ?) AND ((NOT EXISTS (SELECT 1 " + keys + ")) OR " +
+				"(NOT EXISTS (SELECT 1 " + keys + " AND jdb_keys.string_value IN (?)))))",
+			params("user", "by_tag", "by_tag", "a"),
+		},
+		{
+			c.Query("user").Where(NotEq(c.Index("by_tag"), nil)).Select(c.ID),
+			"SELECT id FROM jdb WHERE ((kind = ?) AND (EXISTS (SELECT 1 " + keys + ")))",
+			params("user", "by_tag"),
+		},
+	}
+
+	for i, test := range tests {
+		msg := fmt.Sprintf("Test: %d", i)
+
+		s, p, err := test.Builder.ToSQL()
+		require.NoError(t, err, msg)
+		require.Equal(t, test.Query, s, msg)
+		require.Equal(t, test.Params, p, msg)
+	}
+
+	err := c.Index("by_domain").WhereFieldSQL(newSQLWriter(nil, ""))
+	require.EqualError(t, err, "index: table not defined")
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestNewKey(t *testing.T) {
+	tm := time.Date(2005, 3, 7, 8, 23, 34, 0, time.FixedZone("", 3600))
+	utcTm := tm.UTC()
+	utc := "2005-03-07T07:23:34Z"
+
+	tests := []struct {
+		Value  interface{}
+		Unique bool
+		Key    *key
+	}{
+		{"a", false, &key{Name: "k", String: ptr.String("a")}},
+		{"a", true, &key{Name: "k", String: ptr.String("a"), Unique: ptr.String("a")}},
+		{int8(-3), true, &key{Name: "k", Numeric: ptr.Float64(-3), Unique: ptr.String("-3")}},
+		{uint(3), false, &key{Name: "k", Numeric: ptr.Float64(3)}},
+		{1.5, true, &key{Name: "k", Numeric: ptr.Float64(1.5), Unique: ptr.String("1.5")}},
+		{tm, true, &key{Name: "k", Time: &utcTm, Unique: &utc}},
+	}
+
+	for i, test := range tests {
+		msg := fmt.Sprintf("Test: %d", i)
+
+		k, err := newKey("k", test.Unique, reflect.ValueOf(test.Value))
+		require.NoError(t, err, msg)
+		require.Equal(t, test.Key, k, msg)
+	}
+
+	_, err := newKey("k", false, reflect.ValueOf(true))
+	require.EqualError(t, err, "index k is invalid: true: key type is invalid: bool")
+
+	_, err = newKey("", false, reflect.ValueOf("a"))
+	require.EqualError(t, err, `index name is invalid: ""`)
+}
+
+func TestRowScanMeta_Keys(t *testing.T) {
+	r, err := rowScanMeta(indexUser{ID: "1", Email: "a@example.com", Domain: "example.com"}, false)
+	require.NoError(t, err)
+	require.True(t, r.HasKeys)
+	require.Equal(t, []key{
+		{Name: "by_email", String: ptr.String("a@example.com"), Unique: ptr.String("a@example.com")},
+		{Name: "by_domain", String: ptr.String("example.com")},
+	}, r.Keys)
+
+	r, err = rowScanMeta(indexUser{ID: "1", Age: 3}, false)
+	require.NoError(t, err)
+	require.Len(t, r.Keys, 3)
+	require.Equal(t, key{Name: "by_age", Numeric: ptr.Float64(3)}, r.Keys[2])
+
+	_, err = rowScanMeta(struct {
+		A string `jdb:",index=by_a"`
+		B string `jdb:",unique=by_a"`
+	}{}, false)
+	require.EqualError(t, err, "has duplicate index: by_a")
+}
+
+func TestIndex_Exec(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jdb ")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jdb_keys (kind, id, name, string_value, numeric_value, "+
+		"time_value, unique_value, scope, scope_value) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")).
+		WithArgs("user", "1", "by_email", ptr.String("a"), (*float64)(nil), (*time.Time)(nil), ptr.String("a"),
+			"", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jdb_keys (kind, id, name, string_value, numeric_value, "+
+		"time_value, unique_value, scope, scope_value) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)")).
+		WithArgs("user", "1", "by_domain", ptr.String("b"), (*float64)(nil), (*time.Time)(nil), (*string)(nil),
+			"", "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE jdb SET ")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM jdb_keys WHERE EXISTS (SELECT 1 FROM jdb "+
+		"WHERE ((kind = ?) AND (id = ?)) AND jdb.kind = jdb_keys.kind AND jdb.id = jdb_keys.id)")).
+		WithArgs("user", "1").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	for _, name := range []string{"by_email", "by_domain"} {
+		mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jdb_keys (kind, id, name, string_value, numeric_value, "+
+			"time_value, unique_value, scope, scope_value) SELECT kind, id, ?, ?, ?, ?, ?, ?, ? FROM jdb "+
+			"WHERE ((kind = ?) AND (id = ?))")).
+			WithArgs(name, ptr.String("c"), (*float64)(nil), (*time.Time)(nil), sqlmock.AnyArg(), "", "", "user", "1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	mock.ExpectCommit()
+
+	ctx := context.Background()
+
+	require.NoError(t, c.Tx(ctx, func(tx *Tx) error {
+		err := c.Query("user").Insert(indexUser{ID: "1", Email: "a", Domain: "b"}).Exec(ctx, tx)
+		require.NoError(t, err)
+
+		err = c.Query("user").Update(indexUser{ID: "1", Email: "c", Domain: "c"}).Exec(ctx, tx)
+		require.NoError(t, err)
+
+		return tx.Commit()
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRowScanMeta_MultiValuedKeys(t *testing.T) {
+	r, err := rowScanMeta(struct {
+		ID     string    `jdb:"-id"`
+		Tags   []string  `jdb:"tags,index=by_tag"`
+		Scores []float64 `jdb:"scores,unique=by_score"`
+	}{ID: "1", Tags: []string{"a", "b", "a"}, Scores: []float64{1.5}}, false)
+	require.NoError(t, err)
+	require.True(t, r.HasKeys)
+	require.Equal(t, []key{
+		{Name: "by_tag", String: ptr.String("a")},
+		{Name: "by_tag", String: ptr.String("b")},
+		{Name: "by_score", Numeric: ptr.Float64(1.5), Unique: ptr.String("1.5")},
+	}, r.Keys)
+
+	_, err = rowScanMeta(struct {
+		Tags []bool `jdb:",index=by_tag"`
+	}{Tags: []bool{true}}, false)
+	require.EqualError(t, err, "index by_tag is invalid: true: key type is invalid: bool")
+}
+
+func TestConditions_Keys(t *testing.T) {
+	sub := "SELECT %s FROM table_keys WHERE table_keys.kind = table.kind AND table_keys.id = table.id AND " +
+		"table_keys.name = ? AND table_keys.%s IN (%s)"
+
+	tests := []struct {
+		Condition Condition
+		Query     string
+		Params    []interface{}
+	}{
+		{
+			AnyKey("by_tag", "a", "b", "a"),
+			"(EXISTS (" + fmt.Sprintf(sub, "1", "string_value", "?, ?") + "))",
+			params("by_tag", "a", "b"),
+		},
+		{
+			AllKeys("by_score", 1, 2.5),
+			"((" + fmt.Sprintf(sub, "count(DISTINCT table_keys.numeric_value)", "numeric_value", "?, ?") + ") = ?)",
+			params("by_score", float64(1), 2.5, 2),
+		},
+		{
+			AnyKey("by_tag"),
+			falseCondition,
+			nil,
+		},
+		{
+			AllKeys("by_tag"),
+			trueCondition,
+			nil,
+		},
+	}
+
+	for i, test := range tests {
+		msg := fmt.Sprintf("Test: %d", i)
+
+		w := newSQLWriter(nil, "table")
+
+		err := test.Condition.ConditionSQL(w)
+		require.NoError(t, err, msg)
+		require.Equal(t, test.Query, w.String(), msg)
+		require.Equal(t, test.Params, *w.params, msg)
+	}
+
+	err := AnyKey("by_tag", "a", 1).ConditionSQL(newSQLWriter(nil, "table"))
+	require.EqualError(t, err, "index by_tag has mixed value types")
+
+	err = AllKeys("by_tag", "a").ConditionSQL(newSQLWriter(nil, ""))
+	require.EqualError(t, err, "index: table not defined")
+}
+
+func TestRowScanMeta_ScopedKeys(t *testing.T) {
+	type scopedUser struct {
+		ID     string `jdb:"-id"`
+		Tenant string `jdb:"tenant"`
+		Email  string `jdb:"email,unique=by_email,scope=tenant"`
+		Slug   string `jdb:"slug,unique=by_slug,scope=parent"`
+	}
+
+	r, err := rowScanMeta(scopedUser{ID: "1", Tenant: "t1", Email: "a", Slug: "s"}, false)
+	require.NoError(t, err)
+	require.Equal(t, []key{
+		{Name: "by_email", String: ptr.String("a"), Unique: ptr.String("a"), Scope: "tenant", ScopeValue: "t1"},
+		{Name: "by_slug", String: ptr.String("s"), Unique: ptr.String("s"), Scope: "parent"},
+	}, r.Keys)
+
+	require.Equal(t, "", r.Keys[1].scopeValue(r))
+	r.ParentKind = ptr.String("folder")
+	r.ParentID = ptr.String("2")
+	require.Equal(t, "folder/2", r.Keys[1].scopeValue(r))
+	require.Equal(t, "t1", r.Keys[0].scopeValue(r))
+
+	_, err = rowScanMeta(struct {
+		Email string `jdb:"email,index=by_email,scope=tenant"`
+	}{}, false)
+	require.EqualError(t, err, "scope is invalid: email,index=by_email,scope=tenant")
+
+	_, err = rowScanMeta(struct {
+		Email string `jdb:"email,unique=by_email,scope=tenant"`
+	}{}, false)
+	require.EqualError(t, err, "index by_email scope not found: tenant")
+}
+
+func TestInsertKeys_UniqueError(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jdb ")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jdb_keys ")).
+		WithArgs("user", "1", "by_email", ptr.String("a"), (*float64)(nil), (*time.Time)(nil), ptr.String("a"),
+			"", "").
+		WillReturnError(&UniqueError{Constraint: "jdb_r24"})
+	mock.ExpectRollback()
+
+	ctx := context.Background()
+
+	err := c.Tx(ctx, func(tx *Tx) error {
+		return c.Query("user").Insert(indexUser{ID: "1", Email: "a", Domain: "b"}).Exec(ctx, tx)
+	})
+	require.EqualError(t, err, "jdb: unique key violation: by_email")
+	require.Equal(t, "jdb_r24", err.(*UniqueError).Constraint)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"reflect"
 )
 
 const insertColumnsSQL = "kind, id, parent_kind, parent_id, unique_string_key, string_key, numeric_key, time_key, data"
@@ -13,7 +12,8 @@ const insertPlaceholdersSQL = "?, ?, ?, ?, ?, ?, ?, ?, ?"
 type InsertBuilder struct {
 	q *Query
 
-	values []interface{}
+	values    []interface{}
+	checkRefs bool
 }
 
 func newInsertBuilder(q *Query) *InsertBuilder {
@@ -41,13 +41,76 @@ func (b *InsertBuilder) Add(values ...interface{}) *InsertBuilder {
 	return &n
 }
 
-func (b *InsertBuilder) Exec(ctx context.Context, tx *Tx) error {
-	_, err := tx.exec(ctx, b)
-	return err
+// CheckRefs makes Exec return a RefError when a ref points at a missing
+// document.
+func (b *InsertBuilder) CheckRefs() *InsertBuilder {
+	n := *b
+	n.checkRefs = true
+	return &n
+}
+
+func (b *InsertBuilder) Exec(ctx context.Context, e Executor) error {
+	return e.run(ctx, writeMode, func(tx *Tx) error {
+		return b.exec(ctx, tx)
+	})
+}
+
+func (b *InsertBuilder) exec(ctx context.Context, tx *Tx) error {
+	n := *b
+	n.values = make([]interface{}, len(b.values))
+	for i, v := range b.values {
+		n.values[i] = hookValue(v, beforeInserterType, afterInserterType)
+	}
+	b = &n
+
+	for _, v := range b.values {
+		if err := b.q.kinds.hook(ctx, tx, b.q.kind, beforeInsertHook, v); err != nil {
+			return err
+		}
+	}
+
+	query, params, rows, err := b.toSQL()
+	if err != nil {
+		return err
+	}
+
+	if b.checkRefs {
+		var refs []ref
+		for _, r := range rows {
+			refs = append(refs, r.Refs...)
+		}
+		if err := checkRefs(ctx, tx, b.q, refs); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.exec(ctx, sqlQuery{query, params}); err != nil {
+		return err
+	}
+
+	if err := insertRefs(ctx, tx, b.q, rows); err != nil {
+		return err
+	}
+	if err := insertKeys(ctx, tx, b.q, rows); err != nil {
+		return err
+	}
+
+	for _, v := range b.values {
+		if err := b.q.kinds.hook(ctx, tx, b.q.kind, afterInsertHook, v); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (b *InsertBuilder) ToSQL() (string, []interface{}, error) {
+	query, params, _, err := b.toSQL()
+	return query, params, err
+}
+
+func (b *InsertBuilder) toSQL() (string, []interface{}, []*row, error) {
 	var params []interface{}
+	var rows []*row
 	query := &bytes.Buffer{}
 
 	query.WriteString("INSERT INTO ")
@@ -57,10 +120,17 @@ func (b *InsertBuilder) ToSQL() (string, []interface{}, error) {
 	query.WriteString(") VALUES")
 
 	for i, v := range b.values {
-		r, err := rowScanInput(b.q.kind, v)
+		r, err := b.q.rowScanInput(v)
 		if err != nil {
-			return "", nil, fmt.Errorf("value %d %s", i, err)
+			return "", nil, nil, fmt.Errorf("value %d %s", i, err)
 		}
+		if err := b.q.validate(r); err != nil {
+			return "", nil, nil, err
+		}
+		if err := b.q.setSchemaVersion(r); err != nil {
+			return "", nil, nil, err
+		}
+		rows = append(rows, r)
 
 		if i > 0 {
 			query.WriteString(",")
@@ -72,10 +142,5 @@ func (b *InsertBuilder) ToSQL() (string, []interface{}, error) {
 			r.TimeKey, r.Data)
 	}
 
-	return b.q.d.ReplacePlaceHolders(query.String()), params, nil
-}
-
-func isZero(t reflect.Type, v reflect.Value) bool {
-	zero := reflect.Zero(t).Interface()
-	return reflect.DeepEqual(v, zero)
+	return b.q.d.ReplacePlaceHolders(query.String()), params, rows, nil
 }
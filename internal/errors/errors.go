@@ -18,3 +18,44 @@ type Error interface {
 	Source() error
 	Type() ErrorType
 }
+
+// UniqueError is an integrity error caused by a unique constraint, Key is set
+// when the violated constraint is a unique key.
+type UniqueError struct {
+	Err        Error
+	Constraint string
+	Key        string
+}
+
+func (e *UniqueError) Error() string {
+	if e.Key != "" {
+		return "jdb: unique key violation: " + e.Key
+	}
+	return "jdb: unique constraint violation: " + e.Constraint
+}
+
+func (e *UniqueError) Source() error {
+	return e.Err.Source()
+}
+
+func (e *UniqueError) Type() ErrorType {
+	return IntegrityError
+}
+
+// SerializationError is a transaction error caused by a serialization
+// failure or deadlock, the transaction can be retried.
+type SerializationError struct {
+	Err Error
+}
+
+func (e *SerializationError) Error() string {
+	return "jdb: serialization failure: " + e.Err.Error()
+}
+
+func (e *SerializationError) Source() error {
+	return e.Err.Source()
+}
+
+func (e *SerializationError) Type() ErrorType {
+	return TransactionError
+}
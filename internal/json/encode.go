@@ -1115,6 +1115,11 @@ func typeFields(t reflect.Type) []field {
 				if strings.HasPrefix(name, "-") {
 					continue
 				}
+				// Related documents are loaded separately and never stored
+				// in data.
+				if _, ok := opts.Value("children"); ok || opts.Contains("parent") {
+					continue
+				}
 				if !isValidTag(name) {
 					name = ""
 				}
@@ -0,0 +1,105 @@
+package json_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/silas/jdb"
+	jdbsqlmock "github.com/silas/jdb/dialect/sqlmock"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+// The benchmarks below measure the struct mapping of the jdb package, which
+// uses the tag parsing of this package and caches it per type.
+
+type benchDoc struct {
+	Kind       string    `jdb:"-kind"`
+	ID         string    `jdb:"-id"`
+	ParentKind string    `jdb:"-parentkind"`
+	ParentID   string    `jdb:"-parentid"`
+	CreateTime time.Time `jdb:"-createtime"`
+	UpdateTime time.Time `jdb:"-updatetime"`
+	Email      string    `jdb:"email,unique=by_email,uniquestringkey"`
+	Name       string    `jdb:"name,stringkey"`
+	Age        int       `jdb:"age,index=by_age,numerickey"`
+	Born       time.Time `jdb:"born,timekey"`
+	OwnerID    string    `jdb:"owner_id,ref=user"`
+	Bio        string    `jdb:"bio"`
+}
+
+func init() {
+	jdb.RegisterDialect(jdbsqlmock.RegisterDialectArgs())
+}
+
+func newBenchClient(b *testing.B) (*jdb.Client, sqlmock.Sqlmock) {
+	dsn := fmt.Sprintf("bench-%d", time.Now().UnixNano())
+	_, mock, err := sqlmock.NewWithDSN(dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	c, err := jdb.Open("sqlmock", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return c, mock
+}
+
+func BenchmarkInsertToSQL(b *testing.B) {
+	c, _ := newBenchClient(b)
+	defer c.Close()
+
+	doc := benchDoc{
+		ID:      "1",
+		Email:   "a@example.com",
+		Name:    "Alice",
+		Age:     30,
+		Born:    time.Date(1990, 1, 2, 3, 4, 5, 0, time.UTC),
+		OwnerID: "2",
+		Bio:     "Hello World",
+	}
+	q := c.Query("doc")
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := q.Insert(doc).ToSQL(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRowsScan(b *testing.B) {
+	c, mock := newBenchClient(b)
+	defer c.Close()
+
+	now := time.Date(2018, 5, 22, 1, 5, 2, 0, time.UTC)
+	data := `{"email":"a@example.com","name":"Alice","age":30,"bio":"Hello World"}`
+
+	mockRows := sqlmock.NewRows([]string{"kind", "id", "parent_kind", "parent_id", "data", "create_time",
+		"update_time"})
+	for i := 0; i < b.N; i++ {
+		mockRows.AddRow("doc", "1", "user", "2", data, now, now)
+	}
+	mock.ExpectQuery("SELECT").WillReturnRows(mockRows)
+
+	rows, err := c.Query("doc").Select().Rows(context.Background(), c)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer rows.Close()
+
+	var doc benchDoc
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for rows.Next() {
+		if err := rows.Scan(&doc); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		b.Fatal(err)
+	}
+}
@@ -500,6 +500,13 @@ func (dec *Decoder) peek() (byte, error) {
 	}
 }
 
+// InputOffset returns the input stream byte offset of the current decoder
+// position. The offset gives the location of the end of the most recently
+// returned token and the beginning of the next token.
+func (dec *Decoder) InputOffset() int64 {
+	return dec.offset()
+}
+
 func (dec *Decoder) offset() int64 {
 	return dec.scanned + int64(dec.scanp)
 }
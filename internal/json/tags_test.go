@@ -26,3 +26,21 @@ func TestTagParsing(t *testing.T) {
 		}
 	}
 }
+
+func TestTagValue(t *testing.T) {
+	_, opts := ParseTag("field,foo,children=comment,bar=")
+	for _, tt := range []struct {
+		opt   string
+		value string
+		ok    bool
+	}{
+		{"children", "comment", true},
+		{"bar", "", true},
+		{"foo", "", false},
+		{"child", "", false},
+	} {
+		if value, ok := opts.Value(tt.opt); value != tt.value || ok != tt.ok {
+			t.Errorf("Value(%q) = %q, %v", tt.opt, value, ok)
+		}
+	}
+}
@@ -0,0 +1,510 @@
+// Package schema validates JSON values against a subset of JSON Schema draft
+// 2020-12.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// annotations are keywords that are accepted but do not affect validation.
+var annotations = map[string]bool{
+	"$schema":     true,
+	"$id":         true,
+	"$comment":    true,
+	"title":       true,
+	"description": true,
+	"default":     true,
+	"examples":    true,
+	"format":      true,
+	"deprecated":  true,
+	"readOnly":    true,
+	"writeOnly":   true,
+}
+
+var types = map[string]bool{
+	"null":    true,
+	"boolean": true,
+	"object":  true,
+	"array":   true,
+	"number":  true,
+	"string":  true,
+	"integer": true,
+}
+
+// Violation is a single validation failure, Pointer is a JSON pointer to the
+// invalid value.
+type Violation struct {
+	Pointer string
+	Reason  string
+}
+
+type Schema struct {
+	always *bool
+
+	types []string
+	enum  []interface{}
+	cnst  *interface{}
+
+	properties           map[string]*Schema
+	required             []string
+	additionalProperties *Schema
+	minProperties        *int
+	maxProperties        *int
+
+	items       *Schema
+	minItems    *int
+	maxItems    *int
+	uniqueItems bool
+
+	minLength *int
+	maxLength *int
+	pattern   *regexp.Regexp
+
+	minimum          *float64
+	maximum          *float64
+	exclusiveMinimum *float64
+	exclusiveMaximum *float64
+	multipleOf       *float64
+
+	allOf []*Schema
+	anyOf []*Schema
+	oneOf []*Schema
+	not   *Schema
+}
+
+// Compile parses a JSON Schema document.
+func Compile(data []byte) (*Schema, error) {
+	v, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return compile(v, "")
+}
+
+func decode(data []byte) (interface{}, error) {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func compile(v interface{}, path string) (*Schema, error) {
+	if b, ok := v.(bool); ok {
+		return &Schema{always: &b}, nil
+	}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("schema%s: must be an object or boolean", path)
+	}
+
+	s := &Schema{}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := m[key]
+		kp := path + "/" + key
+
+		var err error
+		switch key {
+		case "type":
+			switch t := value.(type) {
+			case string:
+				s.types = []string{t}
+			case []interface{}:
+				for _, v := range t {
+					name, ok := v.(string)
+					if !ok {
+						return nil, fmt.Errorf("schema%s: must be a string or array of strings", kp)
+					}
+					s.types = append(s.types, name)
+				}
+			default:
+				return nil, fmt.Errorf("schema%s: must be a string or array of strings", kp)
+			}
+			for _, t := range s.types {
+				if !types[t] {
+					return nil, fmt.Errorf("schema%s: unknown type: %s", kp, t)
+				}
+			}
+		case "enum":
+			values, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("schema%s: must be an array", kp)
+			}
+			s.enum = values
+		case "const":
+			s.cnst = &value
+		case "properties":
+			props, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("schema%s: must be an object", kp)
+			}
+			s.properties = map[string]*Schema{}
+			for name, prop := range props {
+				if s.properties[name], err = compile(prop, kp+"/"+escape(name)); err != nil {
+					return nil, err
+				}
+			}
+		case "required":
+			names, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("schema%s: must be an array of strings", kp)
+			}
+			for _, v := range names {
+				name, ok := v.(string)
+				if !ok {
+					return nil, fmt.Errorf("schema%s: must be an array of strings", kp)
+				}
+				s.required = append(s.required, name)
+			}
+		case "additionalProperties":
+			s.additionalProperties, err = compile(value, kp)
+		case "items":
+			s.items, err = compile(value, kp)
+		case "not":
+			s.not, err = compile(value, kp)
+		case "allOf", "anyOf", "oneOf":
+			list, ok := value.([]interface{})
+			if !ok || len(list) == 0 {
+				return nil, fmt.Errorf("schema%s: must be a non-empty array", kp)
+			}
+			schemas := make([]*Schema, len(list))
+			for i, v := range list {
+				if schemas[i], err = compile(v, kp+"/"+strconv.Itoa(i)); err != nil {
+					return nil, err
+				}
+			}
+			switch key {
+			case "allOf":
+				s.allOf = schemas
+			case "anyOf":
+				s.anyOf = schemas
+			default:
+				s.oneOf = schemas
+			}
+		case "minProperties":
+			s.minProperties, err = compileInt(value, kp)
+		case "maxProperties":
+			s.maxProperties, err = compileInt(value, kp)
+		case "minItems":
+			s.minItems, err = compileInt(value, kp)
+		case "maxItems":
+			s.maxItems, err = compileInt(value, kp)
+		case "minLength":
+			s.minLength, err = compileInt(value, kp)
+		case "maxLength":
+			s.maxLength, err = compileInt(value, kp)
+		case "uniqueItems":
+			b, ok := value.(bool)
+			if !ok {
+				return nil, fmt.Errorf("schema%s: must be a boolean", kp)
+			}
+			s.uniqueItems = b
+		case "pattern":
+			p, ok := value.(string)
+			if !ok {
+				return nil, fmt.Errorf("schema%s: must be a string", kp)
+			}
+			if s.pattern, err = regexp.Compile(p); err != nil {
+				return nil, fmt.Errorf("schema%s: %s", kp, err)
+			}
+		case "minimum":
+			s.minimum, err = compileNumber(value, kp)
+		case "maximum":
+			s.maximum, err = compileNumber(value, kp)
+		case "exclusiveMinimum":
+			s.exclusiveMinimum, err = compileNumber(value, kp)
+		case "exclusiveMaximum":
+			s.exclusiveMaximum, err = compileNumber(value, kp)
+		case "multipleOf":
+			if s.multipleOf, err = compileNumber(value, kp); err == nil && *s.multipleOf <= 0 {
+				err = fmt.Errorf("schema%s: must be greater than 0", kp)
+			}
+		default:
+			if !annotations[key] {
+				return nil, fmt.Errorf("schema%s: unsupported keyword", kp)
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+func compileInt(v interface{}, path string) (*int, error) {
+	n, ok := v.(json.Number)
+	if ok {
+		if i, err := strconv.Atoi(n.String()); err == nil && i >= 0 {
+			return &i, nil
+		}
+	}
+	return nil, fmt.Errorf("schema%s: must be a non-negative integer", path)
+}
+
+func compileNumber(v interface{}, path string) (*float64, error) {
+	n, ok := v.(json.Number)
+	if ok {
+		if f, err := n.Float64(); err == nil {
+			return &f, nil
+		}
+	}
+	return nil, fmt.Errorf("schema%s: must be a number", path)
+}
+
+// escape encodes a property name as a JSON pointer token.
+func escape(name string) string {
+	return strings.Replace(strings.Replace(name, "~", "~0", -1), "/", "~1", -1)
+}
+
+// Validate decodes data and validates it against the schema.
+func (s *Schema) Validate(data []byte) ([]Violation, error) {
+	v, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return s.validate(v, ""), nil
+}
+
+func typeOf(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case json.Number:
+		if f, err := v.Float64(); err == nil && f == math.Trunc(f) {
+			return "integer"
+		}
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+func hasType(v interface{}, t string) bool {
+	vt := typeOf(v)
+	return vt == t || (t == "number" && vt == "integer")
+}
+
+// equal compares decoded JSON values, numbers are compared by value.
+func equal(a, b interface{}) bool {
+	an, aok := a.(json.Number)
+	bn, bok := b.(json.Number)
+	if aok && bok {
+		af, _ := an.Float64()
+		bf, _ := bn.Float64()
+		return af == bf
+	}
+	if aok || bok {
+		return false
+	}
+
+	switch av := a.(type) {
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !equal(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k := range av {
+			if _, ok := bv[k]; !ok || !equal(av[k], bv[k]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func (s *Schema) validate(v interface{}, path string) []Violation {
+	if s.always != nil {
+		if *s.always {
+			return nil
+		}
+		return []Violation{{path, "not allowed"}}
+	}
+
+	var vs []Violation
+	fail := func(format string, args ...interface{}) {
+		vs = append(vs, Violation{path, fmt.Sprintf(format, args...)})
+	}
+
+	if len(s.types) > 0 {
+		ok := false
+		for _, t := range s.types {
+			if hasType(v, t) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			fail("expected %s, got %s", strings.Join(s.types, " or "), typeOf(v))
+			return vs
+		}
+	}
+
+	if s.enum != nil {
+		ok := false
+		for _, e := range s.enum {
+			if equal(v, e) {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			fail("must be one of the enum values")
+		}
+	}
+	if s.cnst != nil && !equal(v, *s.cnst) {
+		fail("must be the const value")
+	}
+
+	switch tv := v.(type) {
+	case map[string]interface{}:
+		for _, name := range s.required {
+			if _, ok := tv[name]; !ok {
+				fail("missing required property: %s", name)
+			}
+		}
+		if s.minProperties != nil && len(tv) < *s.minProperties {
+			fail("must have at least %d properties", *s.minProperties)
+		}
+		if s.maxProperties != nil && len(tv) > *s.maxProperties {
+			fail("must have at most %d properties", *s.maxProperties)
+		}
+		names := make([]string, 0, len(tv))
+		for name := range tv {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			p := path + "/" + escape(name)
+			if ps, ok := s.properties[name]; ok {
+				vs = append(vs, ps.validate(tv[name], p)...)
+			} else if s.additionalProperties != nil {
+				if s.additionalProperties.always != nil && !*s.additionalProperties.always {
+					vs = append(vs, Violation{p, "additional property not allowed"})
+				} else {
+					vs = append(vs, s.additionalProperties.validate(tv[name], p)...)
+				}
+			}
+		}
+	case []interface{}:
+		if s.minItems != nil && len(tv) < *s.minItems {
+			fail("must have at least %d items", *s.minItems)
+		}
+		if s.maxItems != nil && len(tv) > *s.maxItems {
+			fail("must have at most %d items", *s.maxItems)
+		}
+		if s.uniqueItems {
+		unique:
+			for i := range tv {
+				for j := i + 1; j < len(tv); j++ {
+					if equal(tv[i], tv[j]) {
+						fail("items must be unique")
+						break unique
+					}
+				}
+			}
+		}
+		if s.items != nil {
+			for i, item := range tv {
+				vs = append(vs, s.items.validate(item, path+"/"+strconv.Itoa(i))...)
+			}
+		}
+	case string:
+		n := utf8.RuneCountInString(tv)
+		if s.minLength != nil && n < *s.minLength {
+			fail("must be at least %d characters", *s.minLength)
+		}
+		if s.maxLength != nil && n > *s.maxLength {
+			fail("must be at most %d characters", *s.maxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(tv) {
+			fail("must match pattern: %s", s.pattern)
+		}
+	case json.Number:
+		f, _ := tv.Float64()
+		if s.minimum != nil && f < *s.minimum {
+			fail("must be >= %v", *s.minimum)
+		}
+		if s.maximum != nil && f > *s.maximum {
+			fail("must be <= %v", *s.maximum)
+		}
+		if s.exclusiveMinimum != nil && f <= *s.exclusiveMinimum {
+			fail("must be > %v", *s.exclusiveMinimum)
+		}
+		if s.exclusiveMaximum != nil && f >= *s.exclusiveMaximum {
+			fail("must be < %v", *s.exclusiveMaximum)
+		}
+		if s.multipleOf != nil {
+			if q := f / *s.multipleOf; q != math.Trunc(q) {
+				fail("must be a multiple of %v", *s.multipleOf)
+			}
+		}
+	}
+
+	for _, sub := range s.allOf {
+		vs = append(vs, sub.validate(v, path)...)
+	}
+	if s.anyOf != nil {
+		ok := false
+		for _, sub := range s.anyOf {
+			if len(sub.validate(v, path)) == 0 {
+				ok = true
+				break
+			}
+		}
+		if !ok {
+			fail("must match at least one schema in anyOf")
+		}
+	}
+	if s.oneOf != nil {
+		n := 0
+		for _, sub := range s.oneOf {
+			if len(sub.validate(v, path)) == 0 {
+				n++
+			}
+		}
+		if n != 1 {
+			fail("must match exactly one schema in oneOf, matched %d", n)
+		}
+	}
+	if s.not != nil && len(s.not.validate(v, path)) == 0 {
+		fail("must not match schema in not")
+	}
+
+	return vs
+}
@@ -0,0 +1,107 @@
+package schema
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const userSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "required": ["name", "age"],
+  "properties": {
+    "name": {"type": "string", "minLength": 1, "maxLength": 8},
+    "age": {"type": "integer", "minimum": 0, "exclusiveMaximum": 200},
+    "email": {"type": ["string", "null"], "pattern": "@"},
+    "role": {"enum": ["admin", "user"]},
+    "tags": {"type": "array", "items": {"type": "string"}, "maxItems": 2, "uniqueItems": true},
+    "a/b": {"const": 1}
+  },
+  "additionalProperties": false
+}`
+
+func TestSchema_Validate(t *testing.T) {
+	s, err := Compile([]byte(userSchema))
+	require.NoError(t, err)
+
+	tests := []struct {
+		Data       string
+		Violations []Violation
+	}{
+		{`{"name": "a", "age": 30}`, nil},
+		{`{"name": "a", "age": 30, "email": null, "role": "admin", "tags": ["a", "b"], "a/b": 1.0}`, nil},
+		{`[]`, []Violation{{"", "expected object, got array"}}},
+		{`{"name": "a"}`, []Violation{{"", "missing required property: age"}}},
+		{`{"name": "a", "age": "thirty"}`, []Violation{{"/age", "expected integer, got string"}}},
+		{`{"name": "a", "age": 1.5}`, []Violation{{"/age", "expected integer, got number"}}},
+		{`{"name": "a", "age": 200}`, []Violation{{"/age", "must be < 200"}}},
+		{`{"name": "", "age": -1}`, []Violation{
+			{"/age", "must be >= 0"},
+			{"/name", "must be at least 1 characters"},
+		}},
+		{`{"name": "a", "age": 1, "email": "a"}`, []Violation{{"/email", "must match pattern: @"}}},
+		{`{"name": "a", "age": 1, "role": "root"}`, []Violation{{"/role", "must be one of the enum values"}}},
+		{`{"name": "a", "age": 1, "tags": ["a", "a", 1]}`, []Violation{
+			{"/tags", "must have at most 2 items"},
+			{"/tags", "items must be unique"},
+			{"/tags/2", "expected string, got integer"},
+		}},
+		{`{"name": "a", "age": 1, "a/b": 2, "other": 1}`, []Violation{
+			{"/a~1b", "must be the const value"},
+			{"/other", "additional property not allowed"},
+		}},
+	}
+
+	for i, test := range tests {
+		msg := fmt.Sprintf("Test: %d", i)
+
+		violations, err := s.Validate([]byte(test.Data))
+		require.NoError(t, err, msg)
+		require.Equal(t, test.Violations, violations, msg)
+	}
+}
+
+func TestSchema_Combinators(t *testing.T) {
+	s, err := Compile([]byte(`{
+  "anyOf": [{"type": "string"}, {"type": "number", "multipleOf": 5}],
+  "oneOf": [{"type": "string"}, {"maxLength": 2}],
+  "not": {"const": "no"}
+}`))
+	require.NoError(t, err)
+
+	violations, err := s.Validate([]byte(`"yes"`))
+	require.NoError(t, err)
+	require.Empty(t, violations)
+
+	violations, err = s.Validate([]byte(`"no"`))
+	require.NoError(t, err)
+	require.Equal(t, []Violation{
+		{"", "must match exactly one schema in oneOf, matched 2"},
+		{"", "must not match schema in not"},
+	}, violations)
+
+	violations, err = s.Validate([]byte(`7`))
+	require.NoError(t, err)
+	require.Equal(t, []Violation{{"", "must match at least one schema in anyOf"}}, violations)
+}
+
+func TestCompile_Invalid(t *testing.T) {
+	tests := []struct {
+		Schema string
+		Error  string
+	}{
+		{`[]`, "schema: must be an object or boolean"},
+		{`{"type": "text"}`, "schema/type: unknown type: text"},
+		{`{"minLength": -1}`, "schema/minLength: must be a non-negative integer"},
+		{`{"properties": {"a": {"$ref": "#"}}}`, "schema/properties/a/$ref: unsupported keyword"},
+		{`{"pattern": "("}`, "schema/pattern: error parsing regexp: missing closing ): `(`"},
+		{`{"allOf": []}`, "schema/allOf: must be a non-empty array"},
+	}
+
+	for i, test := range tests {
+		_, err := Compile([]byte(test.Schema))
+		require.EqualError(t, err, test.Error, fmt.Sprintf("Test: %d", i))
+	}
+}
@@ -1,6 +1,13 @@
 package jdb
 
-import "time"
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding"
+	"fmt"
+	"reflect"
+	"time"
+)
 
 type DatabaseUniqueStringKey interface {
 	DatabaseUniqueStringKey() (*string, bool)
@@ -17,3 +24,150 @@ type DatabaseNumericKey interface {
 type DatabaseTimeKey interface {
 	DatabaseTimeKey() (*time.Time, bool)
 }
+
+// KeyEncoder is implemented by types that convert themselves into a key
+// value, which must be a string, number, time.Time or nil.
+type KeyEncoder interface {
+	EncodeKey() (interface{}, error)
+}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	keyEncoderType      = reflect.TypeOf((*KeyEncoder)(nil)).Elem()
+	valuerType          = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+	scannerType         = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// keyInterface returns v, or its address, as an interface if it implements t.
+func keyInterface(v reflect.Value, t reflect.Type) (interface{}, bool) {
+	if v.Type().Implements(t) {
+		return v.Interface(), true
+	}
+	if v.Kind() != reflect.Ptr && v.CanAddr() && v.Addr().Type().Implements(t) {
+		return v.Addr().Interface(), true
+	}
+	return nil, false
+}
+
+// isEncodedKey reports whether v converts itself into a key value.
+func isEncodedKey(v reflect.Value) bool {
+	for _, t := range []reflect.Type{keyEncoderType, valuerType, textMarshalerType} {
+		if _, ok := keyInterface(v, t); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// keyValue converts v into a string, float64, time.Time or nil.
+func keyValue(v reflect.Value) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if (v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil, nil
+	}
+
+	if i, ok := keyInterface(v, keyEncoderType); ok {
+		kv, err := i.(KeyEncoder).EncodeKey()
+		if err != nil {
+			return nil, err
+		}
+		return basicKeyValue(reflect.ValueOf(kv))
+	}
+	if v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		return keyValue(v.Elem())
+	}
+	if v.Type() == timeType {
+		return v.Interface(), nil
+	}
+	if i, ok := keyInterface(v, valuerType); ok {
+		dv, err := i.(driver.Valuer).Value()
+		if err != nil {
+			return nil, err
+		}
+		return basicKeyValue(reflect.ValueOf(dv))
+	}
+	if i, ok := keyInterface(v, textMarshalerType); ok {
+		b, err := i.(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, err
+		}
+		return string(b), nil
+	}
+
+	return basicKeyValue(v)
+}
+
+// maxExactKey is the largest integer a numeric key stores exactly.
+const maxExactKey = 1 << 53
+
+func basicKeyValue(v reflect.Value) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil, nil
+		}
+		v = v.Elem()
+	}
+	if v.Type() == timeType {
+		return v.Interface(), nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// numeric keys are stored as float64, which is exact up to 2^53
+		i := v.Int()
+		if i < -maxExactKey || i > maxExactKey {
+			return nil, fmt.Errorf("key value is out of range: %d", i)
+		}
+		return float64(i), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u := v.Uint()
+		if u > maxExactKey {
+			return nil, fmt.Errorf("key value is out of range: %d", u)
+		}
+		return float64(u), nil
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), nil
+	case reflect.Slice:
+		if v.Type().Elem().Kind() == reflect.Uint8 {
+			return string(v.Bytes()), nil
+		}
+	}
+
+	return nil, fmt.Errorf("key type is invalid: %s", v.Type())
+}
+
+// setKeyValue sets dest from a column value read back from the database,
+// using the same conversions as keyValue in reverse.
+func setKeyValue(dest reflect.Value, src interface{}) error {
+	if dest.Kind() == reflect.Ptr {
+		if dest.IsNil() {
+			dest.Set(reflect.New(dest.Type().Elem()))
+		}
+		return setKeyValue(dest.Elem(), src)
+	}
+
+	if i, ok := keyInterface(dest, scannerType); ok {
+		return i.(sql.Scanner).Scan(src)
+	}
+	if s, ok := src.(string); ok && dest.Type() != timeType {
+		if i, ok := keyInterface(dest, textUnmarshalerType); ok {
+			return i.(encoding.TextUnmarshaler).UnmarshalText([]byte(s))
+		}
+	}
+
+	sv := reflect.ValueOf(src)
+	if sv.Kind() != dest.Kind() || !sv.Type().ConvertibleTo(dest.Type()) {
+		return fmt.Errorf("key type is invalid: %s", dest.Type())
+	}
+	dest.Set(sv.Convert(dest.Type()))
+	return nil
+}
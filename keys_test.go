@@ -0,0 +1,185 @@
+package jdb
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/silas/jdb/internal/ptr"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+type keyName string
+
+type keyUpper string
+
+func (k keyUpper) EncodeKey() (interface{}, error) {
+	return strings.ToUpper(string(k)), nil
+}
+
+func TestKeyValue(t *testing.T) {
+	now := time.Date(2005, 3, 7, 8, 23, 34, 0, time.UTC)
+
+	tests := []struct {
+		Value interface{}
+		Key   interface{}
+	}{
+		{"a", "a"},
+		{ptr.String("a"), "a"},
+		{(*string)(nil), nil},
+		{keyName("a"), "a"},
+		{int(-3), float64(-3)},
+		{int64(3), float64(3)},
+		{uint32(3), float64(3)},
+		{float32(1.5), float64(1.5)},
+		{now, now},
+		{&now, now},
+		{[]byte("a"), "a"},
+		{sql.NullString{String: "a", Valid: true}, "a"},
+		{sql.NullString{}, nil},
+		{sql.NullInt64{Int64: 3, Valid: true}, float64(3)},
+		{net.ParseIP("127.0.0.1"), "127.0.0.1"},
+		{keyUpper("a"), "A"},
+	}
+
+	for i, test := range tests {
+		msg := fmt.Sprintf("Test: %d", i)
+
+		k, err := keyValue(reflect.ValueOf(test.Value))
+		require.NoError(t, err, msg)
+		require.Equal(t, test.Key, k, msg)
+	}
+
+	_, err := keyValue(reflect.ValueOf(true))
+	require.EqualError(t, err, "key type is invalid: bool")
+
+	_, err = keyValue(reflect.ValueOf(struct{}{}))
+	require.EqualError(t, err, "key type is invalid: struct {}")
+
+	k, err := keyValue(reflect.ValueOf(int64(-1 << 53)))
+	require.NoError(t, err)
+	require.Equal(t, float64(-1<<53), k)
+
+	_, err = keyValue(reflect.ValueOf(int64(1<<53 + 1)))
+	require.EqualError(t, err, "key value is out of range: 9007199254740993")
+
+	_, err = keyValue(reflect.ValueOf(uint64(1<<64 - 1)))
+	require.EqualError(t, err, "key value is out of range: 18446744073709551615")
+
+	_, err = keyValue(reflect.ValueOf(sql.NullInt64{Int64: 1 << 62, Valid: true}))
+	require.EqualError(t, err, "key value is out of range: 4611686018427387904")
+}
+
+func TestSetKeyValue(t *testing.T) {
+	var s string
+	require.NoError(t, setKeyValue(reflect.ValueOf(&s).Elem(), "a"))
+	require.Equal(t, "a", s)
+
+	var name keyName
+	require.NoError(t, setKeyValue(reflect.ValueOf(&name).Elem(), "a"))
+	require.Equal(t, keyName("a"), name)
+
+	var p *keyName
+	require.NoError(t, setKeyValue(reflect.ValueOf(&p).Elem(), "a"))
+	require.Equal(t, keyName("a"), *p)
+
+	var ns sql.NullString
+	require.NoError(t, setKeyValue(reflect.ValueOf(&ns).Elem(), "a"))
+	require.Equal(t, sql.NullString{String: "a", Valid: true}, ns)
+
+	var ip net.IP
+	require.NoError(t, setKeyValue(reflect.ValueOf(&ip).Elem(), "127.0.0.1"))
+	require.Equal(t, "127.0.0.1", ip.String())
+
+	now := time.Date(2005, 3, 7, 8, 23, 34, 0, time.UTC)
+	var tp *time.Time
+	require.NoError(t, setKeyValue(reflect.ValueOf(&tp).Elem(), now))
+	require.Equal(t, now, *tp)
+
+	var n int
+	require.EqualError(t, setKeyValue(reflect.ValueOf(&n).Elem(), "a"), "key type is invalid: int")
+}
+
+func TestRowScanMeta_KeyTypes(t *testing.T) {
+	now := time.Date(2005, 3, 7, 8, 23, 34, 0, time.UTC)
+
+	r, err := rowScanMeta(struct {
+		ID              keyName        `jdb:"-id"`
+		ParentKind      *keyName       `jdb:"-parentkind"`
+		UniqueStringKey keyUpper       `jdb:",uniquestringkey"`
+		StringKey       sql.NullString `jdb:",stringkey"`
+		NumericKey      int64          `jdb:",numerickey"`
+		TimeKey         *time.Time     `jdb:",timekey"`
+	}{
+		ID:              "1",
+		ParentKind:      (*keyName)(ptr.String("folder")),
+		UniqueStringKey: "a",
+		StringKey:       sql.NullString{String: "b", Valid: true},
+		NumericKey:      3,
+		TimeKey:         &now,
+	}, false)
+	require.NoError(t, err)
+	require.Equal(t, "1", r.ID)
+	require.Equal(t, ptr.String("folder"), r.ParentKind)
+	require.Equal(t, ptr.String("A"), r.UniqueStringKey)
+	require.Equal(t, ptr.String("b"), r.StringKey)
+	require.Equal(t, ptr.Float64(3), r.NumericKey)
+	require.Equal(t, &now, r.TimeKey)
+
+	r, err = rowScanMeta(struct {
+		StringKey  sql.NullString `jdb:",stringkey"`
+		NumericKey uint32         `jdb:",numerickey,omitempty"`
+	}{}, false)
+	require.NoError(t, err)
+	requireFieldsSet(t, r, rowFieldsSet{})
+
+	_, err = rowScanMeta(struct {
+		NumericKey string `jdb:",numerickey"`
+	}{"a"}, false)
+	require.EqualError(t, err, "numeric key is invalid: a")
+
+	_, err = rowScanMeta(struct {
+		TimeKey int `jdb:",timekey"`
+	}{1}, false)
+	require.EqualError(t, err, "time key is invalid: 1")
+}
+
+func TestRows_ScanKeyTypes(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	createTime := time.Date(2005, 3, 7, 8, 23, 34, 0, time.UTC)
+	columns := []string{"kind", "id", "parent_kind", "parent_id", "data", "create_time", "update_time"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT kind, id, .*`).
+		WithArgs("page").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("page", "1", "folder", "2", nil, createTime, createTime))
+	mock.ExpectCommit()
+
+	var page struct {
+		ID         keyName        `jdb:"-id"`
+		ParentKind *keyName       `jdb:"-parentkind"`
+		ParentID   sql.NullString `jdb:"-parentid"`
+		CreateTime *time.Time     `jdb:"-createtime"`
+	}
+
+	require.NoError(t, c.Tx(context.Background(), func(tx *Tx) error {
+		require.NoError(t, c.Query("page").Select().First(context.Background(), tx, &page))
+		return tx.Commit()
+	}))
+
+	require.Equal(t, keyName("1"), page.ID)
+	require.Equal(t, keyName("folder"), *page.ParentKind)
+	require.Equal(t, sql.NullString{String: "2", Valid: true}, page.ParentID)
+	require.Equal(t, createTime, *page.CreateTime)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
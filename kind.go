@@ -0,0 +1,267 @@
+package jdb
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+type RegisterOption interface {
+	jdbRegisterOption()
+}
+
+type registerOption struct{}
+
+func (o registerOption) jdbRegisterOption() {}
+
+type registerOptionKindName struct {
+	registerOption
+	name string
+}
+
+// KindName sets the kind of a registered struct, which defaults to the
+// lower case struct name.
+func KindName(name string) RegisterOption {
+	return registerOptionKindName{name: name}
+}
+
+type registerOptionSchema struct {
+	registerOption
+	data []byte
+}
+
+// KindSchema registers a JSON Schema for the kind, see Client.RegisterSchema.
+func KindSchema(data []byte) RegisterOption {
+	return registerOptionSchema{data: data}
+}
+
+func structType(v interface{}) reflect.Type {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// Register associates the struct type of v with a kind, after checking its
+// jdb tags, so queries can be created with QueryFor and QueryT.
+func (c *Client) Register(v interface{}, opts ...RegisterOption) error {
+	t := structType(v)
+	if t == nil || t.Kind() != reflect.Struct {
+		return fmt.Errorf("jdb: register: %v must be a struct", v)
+	}
+
+	name := strings.ToLower(t.Name())
+	var schemaData []byte
+
+	for _, opt := range opts {
+		switch o := opt.(type) {
+		case registerOptionKindName:
+			name = o.name
+		case registerOptionSchema:
+			schemaData = o.data
+		default:
+			panic("unknown option")
+		}
+	}
+
+	if name == "" || len(name) > maxKind {
+		return fmt.Errorf("jdb: register: %s kind is invalid: %q", t, name)
+	}
+	if err := checkType(t); err != nil {
+		return fmt.Errorf("jdb: register: %s %s", t, err)
+	}
+
+	c.kinds.mu.Lock()
+	if other, ok := c.kinds.names[name]; ok && other != t {
+		c.kinds.mu.Unlock()
+		return fmt.Errorf("jdb: register: %s kind already registered: %s", t, name)
+	}
+	if other, ok := c.kinds.types[t]; ok && other != name {
+		c.kinds.mu.Unlock()
+		return fmt.Errorf("jdb: register: %s already registered as kind: %s", t, other)
+	}
+	c.kinds.names[name] = t
+	c.kinds.types[t] = name
+	c.kinds.mu.Unlock()
+
+	if schemaData != nil {
+		return c.RegisterSchema(name, schemaData)
+	}
+	return nil
+}
+
+// KindOf returns the kind registered for the struct type of v.
+func (c *Client) KindOf(v interface{}) (string, bool) {
+	c.kinds.mu.RLock()
+	defer c.kinds.mu.RUnlock()
+	name, ok := c.kinds.types[structType(v)]
+	return name, ok
+}
+
+// QueryFor returns a query for the kind registered for the struct type of v,
+// or an error wrapping ErrKindNotRegistered.
+func (c *Client) QueryFor(v interface{}) (*Query, error) {
+	name, ok := c.KindOf(v)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKindNotRegistered, structType(v))
+	}
+	return c.Query(name), nil
+}
+
+// QueryT returns a query for the kind registered for T.
+func QueryT[T any](c *Client) (*Query, error) {
+	return c.QueryFor((*T)(nil))
+}
+
+func implementsAny(t reflect.Type, types ...reflect.Type) bool {
+	for _, i := range types {
+		if t.Implements(i) || reflect.PtrTo(t).Implements(i) {
+			return true
+		}
+	}
+	return false
+}
+
+// keyKind returns the key value type, "string", "numeric" or "time", that
+// values of t convert to, or "" when it depends on the value.
+func keyKind(t reflect.Type) (string, error) {
+	if implementsAny(t, keyEncoderType) {
+		return "", nil
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return "time", nil
+	}
+	if implementsAny(t, valuerType) {
+		return "", nil
+	}
+	if implementsAny(t, textMarshalerType) {
+		return "string", nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "numeric", nil
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return "string", nil
+		}
+	}
+
+	return "", fmt.Errorf("key type is invalid: %s", t)
+}
+
+func checkKeyKind(field reflect.StructField, want string) error {
+	kind, err := keyKind(field.Type)
+	if err == nil && kind != "" && kind != want {
+		err = fmt.Errorf("must be a %s key type: %s", want, field.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("%s %s", field.Name, err)
+	}
+	return nil
+}
+
+// checkType checks the jdb tags of a struct type, reporting the errors
+// rowScanMeta would only find when writing a value.
+func checkType(t reflect.Type) error {
+	if _, err := relations(t); err != nil {
+		return err
+	}
+
+	tf := cachedTypeFields(t)
+	keys := map[string]bool{}
+	columns := map[string]bool{}
+
+	for _, f := range tf.fields {
+		if f.err != nil {
+			return f.err
+		}
+		field := t.Field(f.index)
+
+		switch f.name {
+		case kindTag, idTag, parentKindTag, parentIDTag:
+			if err := checkKeyKind(field, "string"); err != nil {
+				return err
+			}
+			continue
+		case createTimeTag, updateTimeTag:
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft != timeType {
+				return fmt.Errorf("%s must be a time.Time value", field.Name)
+			}
+			continue
+		case schemaVersionTag:
+			if field.Type.Kind() != reflect.Int {
+				return fmt.Errorf("%s must be an int", field.Name)
+			}
+			continue
+		}
+
+		for _, column := range []struct {
+			tag  string
+			kind string
+			ok   bool
+		}{
+			{uniqueStringKeyTag, "string", f.uniqueStringKey},
+			{stringKeyTag, "string", f.stringKey},
+			{numericKeyTag, "numeric", f.numericKey},
+			{timeKeyTag, "time", f.timeKey},
+		} {
+			if !column.ok {
+				continue
+			}
+			if columns[column.tag] {
+				return fmt.Errorf("has duplicate %s tags", column.tag)
+			}
+			columns[column.tag] = true
+			if err := checkKeyKind(field, column.kind); err != nil {
+				return err
+			}
+		}
+
+		if f.uniqueOK && f.scope != "" && f.scope != parentTag {
+			if _, ok := tf.names[f.scope]; !ok {
+				return fmt.Errorf("scope not found: %s", f.scope)
+			}
+		}
+		for _, n := range []string{f.indexName, f.uniqueName} {
+			if n == "" {
+				continue
+			}
+			if keys[n] {
+				return fmt.Errorf("has duplicate index: %s", n)
+			}
+			keys[n] = true
+		}
+		if f.indexOK || f.uniqueOK {
+			ft := field.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if (ft.Kind() == reflect.Slice || ft.Kind() == reflect.Array) && ft.Elem().Kind() != reflect.Uint8 &&
+				!implementsAny(ft, keyEncoderType, valuerType, textMarshalerType) {
+				ft = ft.Elem()
+			}
+			if _, err := keyKind(ft); err != nil {
+				return fmt.Errorf("%s %s", field.Name, err)
+			}
+		}
+
+		if f.refOK && (field.Type.Kind() != reflect.String || f.refKind == "") {
+			return fmt.Errorf("%s ref is invalid", field.Name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package jdb
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type KindUser struct {
+	ID       string         `jdb:"-id"`
+	Email    string         `jdb:"email,unique=by_email"`
+	Tenant   string         `jdb:"tenant"`
+	Slug     string         `jdb:"slug,unique=by_slug,scope=tenant"`
+	Tags     []string       `jdb:"tags,index=by_tag"`
+	Nickname sql.NullString `jdb:"nickname,stringkey"`
+	Age      int            `jdb:"age,numerickey"`
+	Joined   *time.Time     `jdb:"joined,timekey"`
+}
+
+func TestClient_Register(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	require.NoError(t, c.Register(KindUser{}, KindName("user")))
+	require.NoError(t, c.Register(&KindUser{}, KindName("user")))
+
+	kind, ok := c.KindOf(&KindUser{})
+	require.True(t, ok)
+	require.Equal(t, "user", kind)
+
+	q, err := c.QueryFor(&KindUser{})
+	require.NoError(t, err)
+	require.Equal(t, "user", q.kind)
+	q, err = QueryT[KindUser](c)
+	require.NoError(t, err)
+	require.Equal(t, "user", q.kind)
+	require.Equal(t, c.kinds, q.kinds)
+
+	_, err = c.QueryFor(schemaUser{})
+	require.True(t, errors.Is(err, ErrKindNotRegistered))
+	require.EqualError(t, err, "jdb: kind not registered: jdb.schemaUser")
+
+	require.EqualError(t, c.Register(KindUser{}, KindName("person")),
+		"jdb: register: jdb.KindUser already registered as kind: user")
+	require.EqualError(t, c.Register(schemaUser{}, KindName("user")),
+		"jdb: register: jdb.schemaUser kind already registered: user")
+
+	require.NoError(t, c.Register(schemaUser{}, KindSchema([]byte(schemaUserSchema))))
+	kind, _ = c.KindOf(schemaUser{})
+	require.Equal(t, "schemauser", kind)
+	require.NotNil(t, c.kinds.schema("schemauser"))
+
+	require.EqualError(t, c.Register(1), "jdb: register: 1 must be a struct")
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCheckType(t *testing.T) {
+	tests := []struct {
+		Value interface{}
+		Error string
+	}{
+		{struct {
+			ID int `jdb:"-id"`
+		}{}, "ID must be a string key type: int"},
+		{struct {
+			Created string `jdb:"-createtime"`
+		}{}, "Created must be a time.Time value"},
+		{struct {
+			Version string `jdb:"-schemaversion"`
+		}{}, "Version must be an int"},
+		{struct {
+			A string `jdb:",stringkey"`
+			B string `jdb:",stringkey"`
+		}{}, "has duplicate stringkey tags"},
+		{struct {
+			A string `jdb:",numerickey"`
+		}{}, "A must be a numeric key type: string"},
+		{struct {
+			A int `jdb:",timekey"`
+		}{}, "A must be a time key type: int"},
+		{struct {
+			A string `jdb:",index=by_a"`
+			B string `jdb:",unique=by_a"`
+		}{}, "has duplicate index: by_a"},
+		{struct {
+			A []bool `jdb:",index=by_a"`
+		}{}, "A key type is invalid: bool"},
+		{struct {
+			A string `jdb:",index=by_a,scope=b"`
+		}{}, "scope is invalid: ,index=by_a,scope=b"},
+		{struct {
+			A string `jdb:",unique=by_a,scope=b"`
+		}{}, "scope not found: b"},
+		{struct {
+			A int `jdb:",ref=user"`
+		}{}, "A ref is invalid"},
+		{struct {
+			A string `jdb:",children=comment"`
+		}{}, "A must be a slice of structs"},
+	}
+
+	for _, test := range tests {
+		err := checkType(structType(test.Value))
+		require.EqualError(t, err, test.Error)
+	}
+
+	require.NoError(t, checkType(structType(KindUser{})))
+	require.NoError(t, checkType(structType(includePost{})))
+	require.NoError(t, checkType(structType(Document{})))
+}
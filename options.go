@@ -25,3 +25,25 @@ type optionReadOnly struct {
 func ReadOnly(readOnly bool) Option {
 	return optionReadOnly{readOnly: readOnly}
 }
+
+type optionTxRetry struct {
+	option
+	policy RetryPolicy
+}
+
+// TxRetry makes Client.Tx run its callback again when it fails with a
+// retryable error, see RetryPolicy.
+func TxRetry(policy RetryPolicy) Option {
+	return optionTxRetry{policy: policy}
+}
+
+type optionMaxAttachmentSize struct {
+	option
+	size int64
+}
+
+// MaxAttachmentSize limits the size of attachments written with
+// Tx.PutAttachment, which defaults to 16 MiB.
+func MaxAttachmentSize(size int64) Option {
+	return optionMaxAttachmentSize{size: size}
+}
@@ -5,10 +5,39 @@ type Order struct {
 	desc  bool
 }
 
+// OrderField returns the SQL of the ordered field, or an empty string for
+// fields that need parameters or a table, which are written using OrderSQL.
 func (o Order) OrderField() string {
-	return o.field.toWhereField()
+	w := newSQLWriter(nil, "")
+	if err := w.WriteField(o.field); err != nil || len(*w.params) > 0 {
+		return ""
+	}
+	return w.String()
 }
 
 func (o Order) OrderDesc() bool {
 	return o.desc
 }
+
+// OrderSQL writes the order expression using the dialect's null ordering.
+func (o Order) OrderSQL(w *SQLWriter) error {
+	fw := w.buffer()
+	if err := fw.WriteField(o.field); err != nil {
+		return err
+	}
+	w.WriteString(w.d.OrderExpression(orderField{fw.String(), o.desc}))
+	return nil
+}
+
+type orderField struct {
+	field string
+	desc  bool
+}
+
+func (o orderField) OrderField() string {
+	return o.field
+}
+
+func (o orderField) OrderDesc() bool {
+	return o.desc
+}
@@ -6,12 +6,30 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestOrder_OrderField(t *testing.T) {
-	o := Order{idField, true}
-	require.Equal(t, o.OrderField(), idField.toWhereField())
+func TestOrder_OrderSQL(t *testing.T) {
+	q := setupQuery(t)
+
+	w := newSQLWriter(q.d, q.table)
+	require.NoError(t, Order{idField, true}.OrderSQL(w))
+	require.Equal(t, "id DESC", w.String())
+
+	w = newSQLWriter(q.d, q.table)
+	require.NoError(t, RawField("coalesce(string_key, ?)", "a").Asc().OrderSQL(w))
+	require.Equal(t, "coalesce(string_key, ?) ASC", w.String())
+	require.Equal(t, params("a"), *w.params)
 }
 
 func TestOrder_OrderDesc(t *testing.T) {
 	o := Order{idField, true}
 	require.True(t, o.OrderDesc())
 }
+
+func TestOrder_OrderField(t *testing.T) {
+	c, _ := createMockClient(t)
+	defer c.Close()
+
+	require.Equal(t, "id", c.ID.Desc().OrderField())
+	require.Equal(t, "data->'$.name'", c.Path("name").Asc().OrderField())
+	require.Equal(t, "", RawField("coalesce(string_key, ?)", "a").Asc().OrderField())
+	require.Equal(t, "", c.Index("by_name").Asc().OrderField())
+}
@@ -0,0 +1,232 @@
+package jdb
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"regexp"
+
+	"github.com/silas/jdb/dialect"
+)
+
+const (
+	metaKind        = "jdb"
+	pathIndexesID   = "path_indexes"
+	maxIndexNameLen = 63
+
+	// pathIndexAttempts limits retries of conflicting changes to the
+	// recorded path indexes.
+	pathIndexAttempts = 5
+)
+
+var indexNameRegexp = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type PathIndex = dialect.PathIndex
+
+type PathIndexOpts struct {
+	Name   string
+	Unique bool
+}
+
+type pathIndexes struct {
+	ID      string      `jdb:"-id"`
+	Indexes []PathIndex `jdb:"indexes"`
+}
+
+func (c *Client) pathIndexName(kind string, path []string) string {
+	h := fnv.New32a()
+	h.Write([]byte(kind))
+	for _, key := range path {
+		h.Write([]byte{0})
+		h.Write([]byte(key))
+	}
+	return fmt.Sprintf("%s_p%08x", c.table, h.Sum32())
+}
+
+func (c *Client) loadPathIndexes(ctx context.Context) (*pathIndexes, error) {
+	meta := &pathIndexes{}
+	err := c.View(ctx, func(tx *Tx) error {
+		return c.Query(metaKind).Get(pathIndexesID).Select().First(ctx, tx, meta)
+	})
+	if err == ErrNotFound {
+		return &pathIndexes{ID: pathIndexesID}, nil
+	}
+	return meta, err
+}
+
+// updatePathIndexes changes the recorded path indexes using fn, which
+// returns whether they changed, in a transaction that is retried when a
+// concurrent change conflicts with it.
+func (c *Client) updatePathIndexes(ctx context.Context, fn func(meta *pathIndexes) (bool, error)) error {
+	for attempt := 1; ; attempt++ {
+		err := c.Update(ctx, func(tx *Tx) error {
+			q := c.Query(metaKind)
+
+			meta := &pathIndexes{}
+			err := q.Get(pathIndexesID).Select().First(ctx, tx, meta)
+			exists := err == nil
+			if err == ErrNotFound {
+				meta.ID = pathIndexesID
+			} else if err != nil {
+				return err
+			}
+
+			changed, err := fn(meta)
+			if err != nil || !changed {
+				return err
+			}
+
+			if exists {
+				return q.Update(meta).Exec(ctx, tx)
+			}
+			return q.Insert(meta).Exec(ctx, tx)
+		})
+
+		// a concurrent first insert of the record is a unique violation
+		_, unique := err.(*UniqueError)
+		if attempt >= pathIndexAttempts || !(unique || IsRetryable(err)) {
+			return err
+		}
+		if err := c.retry.wait(ctx, attempt); err != nil {
+			return err
+		}
+	}
+}
+
+// EnsurePathIndex creates an index on the given path of the data for
+// documents of kind, and records it so Migrate can recreate it.
+//
+// The index is recorded before it is created, so Migrate creates it if
+// creating it is interrupted, and creating an existing index does nothing.
+func (c *Client) EnsurePathIndex(ctx context.Context, kind string, path []string, opts PathIndexOpts) (*PathIndex, error) {
+	if c.readOnly {
+		return nil, ErrReadOnlyMode
+	}
+	if kind == "" {
+		return nil, fmt.Errorf("jdb: path index kind required")
+	}
+	if len(path) == 0 {
+		return nil, fmt.Errorf("jdb: path index path required")
+	}
+
+	index := PathIndex{
+		Name:   opts.Name,
+		Kind:   kind,
+		Path:   path,
+		Unique: opts.Unique,
+	}
+	if index.Name == "" {
+		index.Name = c.pathIndexName(kind, path)
+	}
+	if len(index.Name) > maxIndexNameLen || !indexNameRegexp.MatchString(index.Name) {
+		return nil, fmt.Errorf("jdb: invalid path index name: %s", index.Name)
+	}
+
+	added := false
+	err := c.updatePathIndexes(ctx, func(meta *pathIndexes) (bool, error) {
+		added = false
+		for _, v := range meta.Indexes {
+			if v.Name != index.Name {
+				continue
+			}
+			if !reflect.DeepEqual(v, index) {
+				return false, fmt.Errorf("jdb: path index already exists with different definition: %s", index.Name)
+			}
+			return false, nil
+		}
+		meta.Indexes = append(meta.Indexes, index)
+		added = true
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.d.CreatePathIndex(ctx, c.db, c.table, index)
+	if err != nil {
+		if added {
+			c.updatePathIndexes(ctx, removePathIndex(index.Name, nil))
+		}
+		return nil, c.d.ErrorMap(err)
+	}
+
+	return &index, nil
+}
+
+// removePathIndex returns a change removing the named index from the
+// recorded path indexes, found reports whether it was recorded.
+func removePathIndex(name string, found *bool) func(meta *pathIndexes) (bool, error) {
+	return func(meta *pathIndexes) (bool, error) {
+		for i, index := range meta.Indexes {
+			if index.Name == name {
+				meta.Indexes = append(meta.Indexes[:i], meta.Indexes[i+1:]...)
+				if found != nil {
+					*found = true
+				}
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+}
+
+// PathIndexes returns the path indexes created by EnsurePathIndex.
+func (c *Client) PathIndexes(ctx context.Context) ([]PathIndex, error) {
+	meta, err := c.loadPathIndexes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return meta.Indexes, nil
+}
+
+// DropPathIndex drops a path index created by EnsurePathIndex. The index is
+// dropped before its record is removed, so an interrupted drop can be
+// repeated.
+func (c *Client) DropPathIndex(ctx context.Context, name string) error {
+	if c.readOnly {
+		return ErrReadOnlyMode
+	}
+
+	meta, err := c.loadPathIndexes(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, index := range meta.Indexes {
+		if index.Name != name {
+			continue
+		}
+
+		err = c.d.DropPathIndex(ctx, c.db, c.table, index)
+		if err != nil {
+			return c.d.ErrorMap(err)
+		}
+
+		found := false
+		if err := c.updatePathIndexes(ctx, removePathIndex(name, &found)); err != nil {
+			return err
+		}
+		if !found {
+			return ErrNotFound
+		}
+		return nil
+	}
+
+	return ErrNotFound
+}
+
+func (c *Client) migratePathIndexes(ctx context.Context) error {
+	meta, err := c.loadPathIndexes(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, index := range meta.Indexes {
+		err = c.d.CreatePathIndex(ctx, c.db, c.table, index)
+		if err != nil {
+			return c.d.ErrorMap(err)
+		}
+	}
+	return nil
+}
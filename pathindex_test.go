@@ -0,0 +1,132 @@
+package jdb
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestClient_EnsurePathIndex(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	ctx := context.Background()
+	columns := []string{"kind", "id", "parent_kind", "parent_id", "data", "create_time", "update_time"}
+	now := time.Date(2005, 3, 7, 8, 23, 34, 0, time.UTC)
+	data := `{"indexes":[{"Name":"jdb_name","Kind":"user","Path":["Name","FamilyName"],"Unique":true}]}`
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM jdb WHERE").
+		WithArgs("jdb", "path_indexes").
+		WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectExec("INSERT INTO jdb ").
+		WithArgs("jdb", "path_indexes", nil, nil, nil, nil, nil, nil, data).
+		WillReturnError(&UniqueError{})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM jdb WHERE").
+		WithArgs("jdb", "path_indexes").
+		WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectExec("INSERT INTO jdb ").
+		WithArgs("jdb", "path_indexes", nil, nil, nil, nil, nil, nil, data).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE UNIQUE INDEX jdb_name ON jdb (kind, data->'$.Name.FamilyName')")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	index, err := c.EnsurePathIndex(ctx, "user", []string{"Name", "FamilyName"}, PathIndexOpts{
+		Name:   "jdb_name",
+		Unique: true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, &PathIndex{
+		Name:   "jdb_name",
+		Kind:   "user",
+		Path:   []string{"Name", "FamilyName"},
+		Unique: true,
+	}, index)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM jdb WHERE").
+		WithArgs("jdb", "path_indexes").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("jdb", "path_indexes", nil, nil, data, now, now))
+	mock.ExpectCommit()
+	mock.ExpectExec(regexp.QuoteMeta("CREATE UNIQUE INDEX jdb_name ON jdb (kind, data->'$.Name.FamilyName')")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	_, err = c.EnsurePathIndex(ctx, "user", []string{"Name", "FamilyName"}, PathIndexOpts{
+		Name:   "jdb_name",
+		Unique: true,
+	})
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM jdb WHERE").
+		WithArgs("jdb", "path_indexes").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("jdb", "path_indexes", nil, nil, data, now, now))
+	mock.ExpectRollback()
+
+	_, err = c.EnsurePathIndex(ctx, "user", []string{"Name"}, PathIndexOpts{Name: "jdb_name"})
+	require.EqualError(t, err, "jdb: path index already exists with different definition: jdb_name")
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	_, err = c.EnsurePathIndex(ctx, "user", []string{"Name"}, PathIndexOpts{Name: "bad name"})
+	require.EqualError(t, err, "jdb: invalid path index name: bad name")
+
+	_, err = c.EnsurePathIndex(ctx, "user", nil, PathIndexOpts{})
+	require.EqualError(t, err, "jdb: path index path required")
+
+	require.Equal(t, "jdb_p50ee6c35", c.pathIndexName("user", []string{"Name"}))
+}
+
+func TestClient_DropPathIndex(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	ctx := context.Background()
+	columns := []string{"kind", "id", "parent_kind", "parent_id", "data", "create_time", "update_time"}
+	now := time.Date(2005, 3, 7, 8, 23, 34, 0, time.UTC)
+	data := `{"indexes":[{"Name":"jdb_name","Kind":"user","Path":["Name"],"Unique":false}]}`
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM jdb WHERE").
+		WithArgs("jdb", "path_indexes").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("jdb", "path_indexes", nil, nil, data, now, now))
+	mock.ExpectRollback()
+	mock.ExpectExec(regexp.QuoteMeta("DROP INDEX jdb_name")).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM jdb WHERE").
+		WithArgs("jdb", "path_indexes").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("jdb", "path_indexes", nil, nil, data, now, now))
+	mock.ExpectExec("UPDATE jdb SET ").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, c.DropPathIndex(ctx, "jdb_name"))
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT .* FROM jdb WHERE").
+		WithArgs("jdb", "path_indexes").
+		WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectRollback()
+
+	require.Equal(t, ErrNotFound, c.DropPathIndex(ctx, "jdb_name"))
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClient_PathIndex_ReadOnly(t *testing.T) {
+	c, err := Open("sqlmock", "require-read-only-true", ReadOnly(true))
+	require.NoError(t, err)
+
+	_, err = c.EnsurePathIndex(context.Background(), "user", []string{"Name"}, PathIndexOpts{})
+	require.Equal(t, ErrReadOnlyMode, err)
+	require.Equal(t, ErrReadOnlyMode, c.DropPathIndex(context.Background(), "name"))
+}
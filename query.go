@@ -1,6 +1,8 @@
 package jdb
 
 import (
+	"fmt"
+
 	"github.com/silas/jdb/dialect"
 )
 
@@ -12,6 +14,10 @@ type Query struct {
 	d     dialect.Dialect
 	table string
 	kind  string
+	kinds *kindRegistry
+
+	parentKind string
+	parentID   string
 }
 
 func newQuery(d dialect.Dialect, table, kind string) *Query {
@@ -22,6 +28,47 @@ func newQuery(d dialect.Dialect, table, kind string) *Query {
 	}
 }
 
+// subQuery returns a query of kind on the table of q, sharing its kind
+// registry so hooks and upgrades apply to the documents it reads.
+func (q *Query) subQuery(kind string) *Query {
+	n := newQuery(q.d, q.table, kind)
+	n.kinds = q.kinds
+	return n
+}
+
+// Under returns a copy of the query scoped to the children of
+// parentKind/parentID. Inserts without a parent are assigned it.
+func (q *Query) Under(parentKind, parentID string) *Query {
+	n := *q
+	n.parentKind = parentKind
+	n.parentID = parentID
+	return &n
+}
+
+func (q *Query) scoped() bool {
+	return q.parentKind != "" || q.parentID != ""
+}
+
+func (q *Query) rowScanInput(src interface{}) (*row, error) {
+	r, err := rowScanInput(q.kind, src)
+	if err != nil || !q.scoped() {
+		return r, err
+	}
+
+	if r.ParentKind == nil && r.ParentID == nil {
+		r.ParentKind = &q.parentKind
+		r.ParentID = &q.parentID
+	} else if r.ParentID == nil || *r.ParentKind != q.parentKind || *r.ParentID != q.parentID {
+		var parentID string
+		if r.ParentID != nil {
+			parentID = *r.ParentID
+		}
+		return nil, fmt.Errorf("parent mismatch: %s/%s != %s/%s", q.parentKind, q.parentID, *r.ParentKind, parentID)
+	}
+
+	return r, nil
+}
+
 func (q *Query) get(ids ...string) *WhereBuilder {
 	if len(ids) == 0 {
 		return q.Where()
@@ -0,0 +1,87 @@
+package jdb
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/silas/jdb/internal/ptr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuery_Under(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	q := c.Query("comment").Under("post", "1")
+	require.Equal(t, "", c.Query("comment").parentKind)
+
+	type comment struct {
+		ID         string `jdb:"-id"`
+		ParentKind string `jdb:"-parentkind"`
+		ParentID   string `jdb:"-parentid"`
+	}
+
+	tests := []struct {
+		Builder QueryBuilder
+		Query   string
+		Params  []interface{}
+	}{
+		{
+			q.Select(c.ID),
+			"SELECT id FROM jdb WHERE ((kind = ?) AND (parent_kind = ?) AND (parent_id = ?))",
+			params("comment", "post", "1"),
+		},
+		{
+			q.Count(),
+			"SELECT count(*) AS count FROM jdb WHERE ((kind = ?) AND (parent_kind = ?) AND (parent_id = ?))",
+			params("comment", "post", "1"),
+		},
+		{
+			q.Get("2").Select(c.ID),
+			"SELECT id FROM jdb WHERE ((kind = ?) AND (parent_kind = ?) AND (parent_id = ?) AND (id = ?))",
+			params("comment", "post", "1", "2"),
+		},
+		{
+			q.Delete("2"),
+			"DELETE FROM jdb WHERE ((kind = ?) AND (parent_kind = ?) AND (parent_id = ?) AND (id = ?))",
+			params("comment", "post", "1", "2"),
+		},
+		{
+			q.Insert(comment{ID: "2"}, comment{ID: "3", ParentKind: "post", ParentID: "1"}),
+			"INSERT INTO jdb (kind, id, parent_kind, parent_id, unique_string_key, string_key, numeric_key, " +
+				"time_key, data) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?), (?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			params("comment", "2", ptr.String("post"), ptr.String("1"), (*string)(nil), (*string)(nil),
+				(*float64)(nil), (*time.Time)(nil), (*string)(nil), "comment", "3", ptr.String("post"), ptr.String("1"),
+				(*string)(nil), (*string)(nil), (*float64)(nil), (*time.Time)(nil), (*string)(nil)),
+		},
+		{
+			q.Update(comment{ID: "2"}),
+			"UPDATE jdb SET parent_kind = ?, parent_id = ?, unique_string_key = ?, string_key = ?, " +
+				"numeric_key = ?, time_key = ?, data = ?, update_time = CURRENT_TIMESTAMP " +
+				"WHERE ((kind = ?) AND (parent_kind = ?) AND (parent_id = ?) AND (id = ?))",
+			params(ptr.String("post"), ptr.String("1"), (*string)(nil), (*string)(nil), (*float64)(nil), (*time.Time)(nil),
+				(*string)(nil), "comment", "post", "1", "2"),
+		},
+	}
+
+	for i, test := range tests {
+		msg := fmt.Sprintf("Test: %d", i)
+
+		s, p, err := test.Builder.ToSQL()
+		require.NoError(t, err, msg)
+		require.Equal(t, test.Query, s, msg)
+		require.Equal(t, test.Params, p, msg)
+	}
+
+	_, _, err := q.Insert(comment{ID: "2", ParentKind: "post", ParentID: "2"}).ToSQL()
+	require.EqualError(t, err, "value 0 parent mismatch: post/1 != post/2")
+
+	_, _, err = q.Insert(comment{ID: "2", ParentKind: "account"}).ToSQL()
+	require.EqualError(t, err, "value 0 parent mismatch: post/1 != account/")
+
+	_, _, err = q.Update(comment{ID: "2", ParentKind: "account", ParentID: "1"}).ToSQL()
+	require.EqualError(t, err, "parent mismatch: post/1 != account/1")
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,264 @@
+package jdb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+
+	"github.com/silas/jdb/internal/json"
+)
+
+type ref struct {
+	Name string
+	Kind string
+	ID   string
+}
+
+// RefError is returned when a checked ref points at a missing document.
+type RefError struct {
+	Name string
+	Kind string
+	ID   string
+}
+
+func (e *RefError) Error() string {
+	return fmt.Sprintf("jdb: %s ref not found: %s/%s", e.Name, e.Kind, e.ID)
+}
+
+func refsTable(table string) string {
+	return table + "_refs"
+}
+
+// sqlQuery is a query that has already been rendered.
+type sqlQuery struct {
+	query  string
+	params []interface{}
+}
+
+func (q sqlQuery) ToSQL() (string, []interface{}, error) {
+	return q.query, q.params, nil
+}
+
+type refersTo struct {
+	kind  string
+	id    string
+	names []string
+}
+
+// RefersTo matches documents with a ref to kind/id, optionally limited to
+// refs with the given names.
+func RefersTo(kind, id string, names ...string) Condition {
+	return refersTo{kind, id, names}
+}
+
+func (c refersTo) ConditionSQL(w *SQLWriter) error {
+	if w.table == "" {
+		return fmt.Errorf("refers to: table not defined")
+	}
+
+	table := refsTable(w.table)
+	outer := w.Table()
+
+	w.WriteString("(EXISTS (SELECT 1 FROM ")
+	w.WriteString(table)
+	w.WriteString(" WHERE ")
+	w.WriteString(table + ".ref_kind = ")
+	w.WriteParam(c.kind)
+	w.WriteString(" AND " + table + ".ref_id = ")
+	w.WriteParam(c.id)
+	w.WriteString(" AND " + table + ".kind = " + outer + ".kind")
+	w.WriteString(" AND " + table + ".id = " + outer + ".id")
+	if len(c.names) > 0 {
+		w.WriteString(" AND " + table + ".name IN (")
+		w.WriteString(placeholders(len(c.names)))
+		w.WriteString(")")
+		for _, name := range c.names {
+			w.AddParams(name)
+		}
+	}
+	w.WriteString("))")
+
+	return nil
+}
+
+func checkRefs(ctx context.Context, tx *Tx, q *Query, refs []ref) error {
+	if len(refs) == 0 {
+		return nil
+	}
+
+	ids := map[string][]interface{}{}
+	var kinds []string
+	for _, r := range refs {
+		if _, ok := ids[r.Kind]; !ok {
+			kinds = append(kinds, r.Kind)
+		}
+		ids[r.Kind] = append(ids[r.Kind], r.ID)
+	}
+
+	rq := q.subQuery("")
+	wb := &WhereBuilder{q: rq}
+	sb := newSelectBuilder(rq, wb.Where(keyConditions(kindField, idField, ids, kinds)),
+		[]SelectField{kindField, idField})
+
+	var docs []Document
+	if err := sb.All(ctx, tx, &docs); err != nil {
+		return err
+	}
+
+	found := map[[2]string]bool{}
+	for _, doc := range docs {
+		found[[2]string{doc.Kind, doc.ID}] = true
+	}
+	for _, r := range refs {
+		if !found[[2]string{r.Kind, r.ID}] {
+			return &RefError{Name: r.Name, Kind: r.Kind, ID: r.ID}
+		}
+	}
+
+	return nil
+}
+
+// insertRefs adds refs for rows that were just inserted.
+func insertRefs(ctx context.Context, tx *Tx, q *Query, rows []*row) error {
+	w := newSQLWriter(q.d, q.table)
+	n := 0
+	for _, r := range rows {
+		for _, rf := range r.Refs {
+			if n == 0 {
+				w.WriteString("INSERT INTO ")
+				w.WriteString(refsTable(q.table))
+				w.WriteString(" (kind, id, name, ref_kind, ref_id) VALUES ")
+			} else {
+				w.WriteString(", ")
+			}
+			w.WriteString("(?, ?, ?, ?, ?)")
+			w.AddParams(r.Kind, r.ID, rf.Name, rf.Kind, rf.ID)
+			n++
+		}
+	}
+	if n == 0 {
+		return nil
+	}
+
+	query, params := w.toSQL()
+	_, err := tx.exec(ctx, sqlQuery{query, params})
+	return err
+}
+
+// replaceRefs replaces the refs of the updated document matching wb.
+func replaceRefs(ctx context.Context, tx *Tx, q *Query, wb *WhereBuilder, r *row) error {
+	if err := deleteSide(ctx, tx, q, wb, refsTable(q.table)); err != nil {
+		return err
+	}
+
+	for _, rf := range r.Refs {
+		w := newSQLWriter(q.d, q.table)
+		w.WriteString("INSERT INTO " + refsTable(q.table) + " (kind, id, name, ref_kind, ref_id) ")
+		w.WriteString("SELECT kind, id, ?, ?, ? FROM " + q.table + " ")
+		w.AddParams(rf.Name, rf.Kind, rf.ID)
+		if err := wb.toWhereSQL(w); err != nil {
+			return err
+		}
+		query, params := w.toSQL()
+		if _, err := tx.exec(ctx, sqlQuery{query, params}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteSide removes the rows of a side table, such as refs or keys, that
+// belong to documents matching wb.
+func deleteSide(ctx context.Context, tx *Tx, q *Query, wb *WhereBuilder, table string) error {
+	w := newSQLWriter(q.d, q.table)
+	w.WriteString("DELETE FROM " + table + " WHERE EXISTS (SELECT 1 FROM " + q.table + " ")
+	if err := wb.toWhereSQL(w); err != nil {
+		return err
+	}
+	w.WriteString(" AND " + q.table + ".kind = " + table + ".kind AND " + q.table + ".id = " + table + ".id)")
+
+	query, params := w.toSQL()
+	_, err := tx.exec(ctx, sqlQuery{query, params})
+	return err
+}
+
+type preload struct {
+	index   int
+	idIndex int
+	kind    string
+	elem    reflect.Type
+	ptr     bool
+}
+
+// preloadField returns the field name and its sibling ref field, name+"ID".
+func preloadField(t reflect.Type, name string) (*preload, error) {
+	field, ok := t.FieldByName(name)
+	if !ok || len(field.Index) != 1 {
+		return nil, fmt.Errorf("preload: unknown field: %s", name)
+	}
+	idField, ok := t.FieldByName(name + "ID")
+	if !ok || len(idField.Index) != 1 {
+		return nil, fmt.Errorf("preload: unknown field: %sID", name)
+	}
+
+	_, opts := json.ParseTag(idField.Tag.Get(tagName))
+	kind, ok := opts.Value(refTag)
+	if !ok || kind == "" {
+		return nil, fmt.Errorf("preload: %sID is not a ref", name)
+	}
+
+	p := &preload{index: field.Index[0], idIndex: idField.Index[0], kind: kind, elem: field.Type}
+	if p.elem.Kind() == reflect.Ptr {
+		p.elem = p.elem.Elem()
+		p.ptr = true
+	}
+	if p.elem.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("preload: %s must be a struct", name)
+	}
+
+	return p, nil
+}
+
+func (b *SelectBuilder) preload(ctx context.Context, tx *Tx, t reflect.Type, values []reflect.Value) error {
+	for _, name := range b.preloads {
+		p, err := preloadField(t, name)
+		if err != nil {
+			return err
+		}
+
+		var ids []interface{}
+		positions := map[string][]int{}
+		for i, v := range values {
+			id := reflect.Indirect(v.Field(p.idIndex))
+			if !id.IsValid() || id.String() == "" {
+				continue
+			}
+			if _, ok := positions[id.String()]; !ok {
+				ids = append(ids, id.String())
+			}
+			positions[id.String()] = append(positions[id.String()], i)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+
+		sb := b.q.subQuery(p.kind).Where(In(idField, ids...)).Select()
+		r := relation{elem: p.elem}
+		err = b.scanIncluded(ctx, tx, sb, r, func(e reflect.Value, key rowKey) {
+			for _, i := range positions[key.id] {
+				field := values[i].Field(p.index)
+				if p.ptr {
+					field.Set(e)
+				} else {
+					field.Set(e.Elem())
+				}
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
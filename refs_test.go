@@ -0,0 +1,176 @@
+package jdb
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/silas/jdb/internal/ptr"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+type refProduct struct {
+	ID   string `jdb:"-id"`
+	Name string `jdb:"name"`
+}
+
+type refOrder struct {
+	ID        string      `jdb:"-id"`
+	ProductID string      `jdb:"productId,ref=product"`
+	Product   *refProduct `jdb:"-"`
+}
+
+func TestRefersTo(t *testing.T) {
+	w := newSQLWriter(nil, "table")
+	err := RefersTo("product", "1").ConditionSQL(w)
+	require.NoError(t, err)
+	require.Equal(t, "(EXISTS (SELECT 1 FROM table_refs WHERE table_refs.ref_kind = ? AND "+
+		"table_refs.ref_id = ? AND table_refs.kind = table.kind AND table_refs.id = table.id))", w.String())
+	require.Equal(t, params("product", "1"), *w.params)
+
+	w = newSQLWriter(nil, "table").subquery()
+	err = RefersTo("product", "1", "productId", "giftId").ConditionSQL(w)
+	require.NoError(t, err)
+	require.Equal(t, "(EXISTS (SELECT 1 FROM table_refs WHERE table_refs.ref_kind = ? AND "+
+		"table_refs.ref_id = ? AND table_refs.kind = table_1.kind AND table_refs.id = table_1.id AND "+
+		"table_refs.name IN (?, ?)))", w.String())
+	require.Equal(t, params("product", "1", "productId", "giftId"), *w.params)
+
+	err = RefersTo("product", "1").ConditionSQL(newSQLWriter(nil, ""))
+	require.EqualError(t, err, "refers to: table not defined")
+}
+
+func TestRefs_Exec(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	columns := []string{"kind", "id"}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT kind, id FROM jdb WHERE ((((kind = ?) AND (id IN (?, ?)))))")).
+		WithArgs("product", "1", "2").
+		WillReturnRows(sqlmock.NewRows(columns).AddRow("product", "1").AddRow("product", "2"))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jdb ")).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jdb_refs (kind, id, name, ref_kind, ref_id) VALUES "+
+		"(?, ?, ?, ?, ?), (?, ?, ?, ?, ?)")).
+		WithArgs("order", "3", "productId", "product", "1", "order", "4", "productId", "product", "2").
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT kind, id FROM jdb WHERE ((((kind = ?) AND (id IN (?)))))")).
+		WithArgs("product", "5").
+		WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE jdb SET ")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM jdb_refs WHERE EXISTS (SELECT 1 FROM jdb "+
+		"WHERE ((kind = ?) AND (id = ?)) AND jdb.kind = jdb_refs.kind AND jdb.id = jdb_refs.id)")).
+		WithArgs("order", "3").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO jdb_refs (kind, id, name, ref_kind, ref_id) "+
+		"SELECT kind, id, ?, ?, ? FROM jdb WHERE ((kind = ?) AND (id = ?))")).
+		WithArgs("productId", "product", "2", "order", "3").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("UPDATE jdb SET ")).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM jdb_refs WHERE EXISTS")).
+		WithArgs("order", "4").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ctx := context.Background()
+
+	require.NoError(t, c.Tx(ctx, func(tx *Tx) error {
+		q := c.Query("order")
+
+		err := q.Insert(refOrder{ID: "3", ProductID: "1"}, refOrder{ID: "4", ProductID: "2"}).CheckRefs().
+			Exec(ctx, tx)
+		require.NoError(t, err)
+
+		err = q.Update(refOrder{ID: "3", ProductID: "5"}).CheckRefs().Exec(ctx, tx)
+		require.EqualError(t, err, "jdb: productId ref not found: product/5")
+		require.Equal(t, &RefError{Name: "productId", Kind: "product", ID: "5"}, err)
+
+		err = q.Update(refOrder{ID: "3", ProductID: "2"}).Exec(ctx, tx)
+		require.NoError(t, err)
+
+		err = q.Update(refOrder{ID: "4"}).Exec(ctx, tx)
+		require.NoError(t, err)
+
+		return tx.Commit()
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelectBuilder_Preload(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	columns := []string{"kind", "id", "parent_kind", "parent_id", "data", "create_time", "update_time"}
+	now := time.Date(2005, 3, 7, 8, 23, 34, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT .* FROM jdb WHERE \(\(kind = \?\)\)`).
+		WithArgs("order").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("order", "3", nil, nil, `{"productId":"1"}`, now, now).
+			AddRow("order", "4", nil, nil, `{"productId":"1"}`, now, now).
+			AddRow("order", "5", nil, nil, `{}`, now, now))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT kind, id, parent_kind, parent_id, data, create_time, update_time `+
+		`FROM jdb WHERE ((kind = ?) AND (id IN (?)))`)).
+		WithArgs("product", "1").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("product", "1", nil, nil, `{"name":"One"}`, now, now))
+	mock.ExpectCommit()
+
+	ctx := context.Background()
+
+	require.NoError(t, c.Tx(ctx, func(tx *Tx) error {
+		var orders []*refOrder
+		err := c.Query("order").Select().Preload("Product").All(ctx, tx, &orders)
+		require.NoError(t, err)
+		product := &refProduct{ID: "1", Name: "One"}
+		require.Equal(t, []*refOrder{
+			{ID: "3", ProductID: "1", Product: product},
+			{ID: "4", ProductID: "1", Product: product},
+			{ID: "5"},
+		}, orders)
+
+		return tx.Commit()
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRowScanMeta_Refs(t *testing.T) {
+	r, err := rowScanMeta(refOrder{ID: "1", ProductID: "2"}, false)
+	require.NoError(t, err)
+	require.True(t, r.HasRefs)
+	require.Equal(t, []ref{{Name: "productId", Kind: "product", ID: "2"}}, r.Refs)
+
+	r, err = rowScanMeta(struct {
+		ProductID *string `jdb:",ref=product"`
+	}{}, false)
+	require.NoError(t, err)
+	require.True(t, r.HasRefs)
+	require.Nil(t, r.Refs)
+
+	r, err = rowScanMeta(struct {
+		ProductID *string `jdb:",ref=product"`
+	}{ptr.String("2")}, false)
+	require.NoError(t, err)
+	require.Equal(t, []ref{{Name: "ProductID", Kind: "product", ID: "2"}}, r.Refs)
+
+	_, err = rowScanMeta(struct {
+		ProductID int `jdb:",ref=product"`
+	}{1}, false)
+	require.EqualError(t, err, "ref is invalid: 1")
+
+	_, err = preloadField(reflect.TypeOf(refOrder{}), "Missing")
+	require.EqualError(t, err, "preload: unknown field: Missing")
+
+	_, err = preloadField(reflect.TypeOf(refProduct{}), "Name")
+	require.EqualError(t, err, "preload: unknown field: NameID")
+}
@@ -0,0 +1,77 @@
+package jdb
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	jdberrors "github.com/silas/jdb/internal/errors"
+)
+
+const (
+	defaultMinBackoff = 10 * time.Millisecond
+	defaultMaxBackoff = time.Second
+)
+
+// RetryPolicy configures how Client.Tx retries transactions.
+//
+// Retries wait for a jittered exponential backoff between MinBackoff and
+// MaxBackoff, and only happen when the transaction was not committed, so fn
+// must not have side effects outside the transaction.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times fn is run, retries are
+	// disabled when it is less than 2.
+	MaxAttempts int
+	MinBackoff  time.Duration
+	MaxBackoff  time.Duration
+
+	// OnRetry is called with the failed attempt and its error before waiting
+	// to retry.
+	OnRetry func(attempt int, err error)
+}
+
+// IsRetryable reports whether err is a serialization failure, deadlock or
+// busy database error.
+func IsRetryable(err error) bool {
+	var serr *SerializationError
+	if errors.As(err, &serr) {
+		return true
+	}
+	var e Error
+	return errors.As(err, &e) && e.Type() == jdberrors.BusyError
+}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	min, max := p.MinBackoff, p.MaxBackoff
+	if min <= 0 {
+		min = defaultMinBackoff
+	}
+	if max < min {
+		max = defaultMaxBackoff
+		if max < min {
+			max = min
+		}
+	}
+
+	d := min
+	for i := 1; i < attempt && d < max; i++ {
+		d *= 2
+	}
+	if d > max {
+		d = max
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
+}
+
+func (p RetryPolicy) wait(ctx context.Context, attempt int) error {
+	t := time.NewTimer(p.backoff(attempt))
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,153 @@
+package jdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	jdberrors "github.com/silas/jdb/internal/errors"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+type testError struct {
+	t jdberrors.ErrorType
+}
+
+func (e testError) Error() string             { return "test error" }
+func (e testError) Source() error             { return nil }
+func (e testError) Type() jdberrors.ErrorType { return e.t }
+
+func createRetryClient(t *testing.T, policy RetryPolicy) (*Client, sqlmock.Sqlmock) {
+	dsn := fmt.Sprintf("dsn-%d", time.Now().UnixNano())
+	_, mock, err := sqlmock.NewWithDSN(dsn)
+	require.NoError(t, err)
+
+	c, err := Open("sqlmock", dsn, TxRetry(policy))
+	require.NoError(t, err)
+	return c, mock
+}
+
+func TestIsRetryable(t *testing.T) {
+	serr := &SerializationError{Err: testError{jdberrors.TransactionError}}
+	require.Equal(t, "jdb: serialization failure: test error", serr.Error())
+
+	require.True(t, IsRetryable(serr))
+	require.True(t, IsRetryable(fmt.Errorf("insert: %w", serr)))
+	require.True(t, IsRetryable(testError{jdberrors.BusyError}))
+	require.False(t, IsRetryable(testError{jdberrors.TransactionError}))
+	require.False(t, IsRetryable(&UniqueError{Err: testError{jdberrors.IntegrityError}}))
+	require.False(t, IsRetryable(errors.New("failed")))
+	require.False(t, IsRetryable(nil))
+}
+
+func TestRetryPolicy_backoff(t *testing.T) {
+	p := RetryPolicy{MinBackoff: 10 * time.Millisecond, MaxBackoff: 50 * time.Millisecond}
+
+	for attempt, max := range map[int]time.Duration{1: 10, 2: 20, 3: 40, 4: 50, 10: 50} {
+		for i := 0; i < 20; i++ {
+			d := p.backoff(attempt)
+			require.True(t, d >= max*time.Millisecond/2, "%d: %s", attempt, d)
+			require.True(t, d <= max*time.Millisecond, "%d: %s", attempt, d)
+		}
+	}
+
+	d := RetryPolicy{}.backoff(20)
+	require.True(t, d <= defaultMaxBackoff)
+}
+
+func TestClient_TxRetry(t *testing.T) {
+	var retries []int
+	c, mock := createRetryClient(t, RetryPolicy{
+		MaxAttempts: 3,
+		MinBackoff:  time.Millisecond,
+		MaxBackoff:  time.Millisecond,
+		OnRetry: func(attempt int, err error) {
+			retries = append(retries, attempt)
+		},
+	})
+	defer c.Close()
+
+	ctx := context.Background()
+	serr := &SerializationError{Err: testError{jdberrors.TransactionError}}
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	attempts := 0
+	err := c.Tx(ctx, func(tx *Tx) error {
+		attempts++
+		if attempts < 3 {
+			return serr
+		}
+		return tx.Commit()
+	})
+	require.NoError(t, err)
+	require.Equal(t, 3, attempts)
+	require.Equal(t, []int{1, 2}, retries)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	retries = nil
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	attempts = 0
+	err = c.Tx(ctx, func(tx *Tx) error {
+		attempts++
+		return testError{jdberrors.BusyError}
+	})
+	require.Equal(t, testError{jdberrors.BusyError}, err)
+	require.Equal(t, 3, attempts)
+	require.Equal(t, []int{1, 2}, retries)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	attempts = 0
+	err = c.Tx(ctx, func(tx *Tx) error {
+		attempts++
+		require.NoError(t, tx.Commit())
+		return serr
+	})
+	require.Equal(t, serr, err)
+	require.Equal(t, 1, attempts)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	attempts = 0
+	err = c.Tx(ctx, func(tx *Tx) error {
+		attempts++
+		return ErrNotFound
+	})
+	require.Equal(t, ErrNotFound, err)
+	require.Equal(t, 1, attempts)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClient_TxNoRetry(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	serr := &SerializationError{Err: testError{jdberrors.TransactionError}}
+	err := c.Tx(context.Background(), func(tx *Tx) error {
+		return serr
+	})
+	require.Equal(t, serr, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+}
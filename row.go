@@ -14,6 +14,7 @@ const (
 	maxID              = 64
 	maxUniqueStringKey = 255
 	maxStringKey       = 255
+	maxScopeValue      = 191
 )
 
 var (
@@ -32,6 +33,10 @@ type row struct {
 	TimeKey         *time.Time
 	CreateTime      *time.Time
 	UpdateTime      *time.Time
+	Refs            []ref
+	HasRefs         bool
+	Keys            []key
+	HasKeys         bool
 }
 
 var timeValue = reflect.ValueOf(time.Time{})
@@ -71,19 +76,21 @@ func rowScanMeta(src interface{}, ro bool) (*row, error) {
 		}
 	}
 
-	t := v.Type()
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		if field.PkgPath != "" {
-			continue
+	tf := cachedTypeFields(v.Type())
+	for i := range tf.fields {
+		f := &tf.fields[i]
+		name := f.name
+		if f.refOK {
+			r.HasRefs = true
 		}
-		tag := field.Tag.Get(tagName)
-		if tag == "" {
-			continue
+		if f.indexOK || f.uniqueOK {
+			r.HasKeys = true
+		}
+		if f.err != nil {
+			return nil, f.err
 		}
-		name, tagOpts := json.ParseTag(tag)
 
-		value := v.Field(i)
+		value := v.Field(f.index)
 		if !value.IsValid() {
 			continue
 		}
@@ -93,39 +100,23 @@ func rowScanMeta(src interface{}, ro bool) (*row, error) {
 		value = reflect.Indirect(value)
 
 		switch name {
-		case kindTag:
-			if v, ok := value.Interface().(string); ok {
-				if v != "" {
-					r.Kind = v
-				}
-			} else {
-				return nil, fmt.Errorf("%s is invalid: %v", name, value)
-			}
-		case idTag:
-			if v, ok := value.Interface().(string); ok {
-				if v != "" {
-					r.ID = v
-				}
-			} else {
+		case kindTag, idTag, parentKindTag, parentIDTag:
+			v, err := stringKeyValue(value)
+			if err != nil {
 				return nil, fmt.Errorf("%s is invalid: %v", name, value)
 			}
-		case parentKindTag:
-			if value.Kind() == reflect.String {
-				s := value.Interface().(string)
-				if s != "" {
-					r.ParentKind = ptr.String(s)
-				}
-			} else {
-				return nil, fmt.Errorf("%s is invalid: %v", name, value)
+			if v == nil || *v == "" {
+				continue
 			}
-		case parentIDTag:
-			if value.Kind() == reflect.String {
-				s := value.Interface().(string)
-				if s != "" {
-					r.ParentID = ptr.String(s)
-				}
-			} else {
-				return nil, fmt.Errorf("%s is invalid: %v", name, value)
+			switch name {
+			case kindTag:
+				r.Kind = *v
+			case idTag:
+				r.ID = *v
+			case parentKindTag:
+				r.ParentKind = v
+			case parentIDTag:
+				r.ParentID = v
 			}
 		case createTimeTag, updateTimeTag:
 			if !ro {
@@ -133,7 +124,7 @@ func rowScanMeta(src interface{}, ro bool) (*row, error) {
 			}
 			if value.Kind() == timeValue.Kind() && value.Type() == timeValue.Type() {
 				s := value.Interface().(time.Time)
-				if !isZero(value.Type(), value) {
+				if !s.IsZero() {
 					if name == createTimeTag {
 						r.CreateTime = &s
 					} else {
@@ -144,73 +135,155 @@ func rowScanMeta(src interface{}, ro bool) (*row, error) {
 				return nil, fmt.Errorf("%s is invalid: %v", name, value)
 			}
 		default:
-			omitempty := tagOpts.Contains("omitempty")
+			omitempty := f.omitEmpty
 
-			if tagOpts.Contains(uniqueStringKeyTag) {
-				if v, ok := value.Interface().(string); ok {
-					if !omitempty || !isZero(value.Type(), value) {
-						if uniqueStringKeyDefined {
-							return nil, fmt.Errorf("has duplicate unique string keys")
+			if (f.indexOK || f.uniqueOK) && (!omitempty || !value.IsZero()) {
+				for _, n := range []string{f.indexName, f.uniqueName} {
+					if n == "" {
+						continue
+					}
+					for _, k := range r.Keys {
+						if k.Name == n {
+							return nil, fmt.Errorf("has duplicate index: %s", n)
 						}
-						uniqueStringKeyDefined = true
-
-						r.UniqueStringKey = ptr.String(v)
 					}
-				} else {
-					return nil, fmt.Errorf("unique string key is invalid: %v", value)
+					keys, err := newKeys(n, n == f.uniqueName, value)
+					if err != nil {
+						return nil, err
+					}
+					if n == f.uniqueName {
+						for i := range keys {
+							keys[i].Scope = f.scope
+						}
+					}
+					r.Keys = append(r.Keys, keys...)
 				}
 			}
 
-			if tagOpts.Contains(stringKeyTag) {
-				if v, ok := value.Interface().(string); ok {
-					if !omitempty || !isZero(value.Type(), value) {
-						if stringKeyDefined {
-							return nil, fmt.Errorf("has duplicate string keys")
-						}
-						stringKeyDefined = true
-
-						r.StringKey = ptr.String(v)
+			if f.refOK {
+				if value.Kind() != reflect.String || f.refKind == "" {
+					return nil, fmt.Errorf("ref is invalid: %v", value)
+				}
+				if id := value.String(); id != "" {
+					refName := name
+					if refName == "" {
+						refName = f.goName
 					}
-				} else {
-					return nil, fmt.Errorf("string key is invalid: %v", value)
+					r.Refs = append(r.Refs, ref{Name: refName, Kind: f.refKind, ID: id})
 				}
 			}
 
-			if tagOpts.Contains(numericKeyTag) {
-				if v, ok := value.Interface().(float64); ok {
-					if !omitempty || !isZero(value.Type(), value) {
-						if numericKeyDefined {
-							return nil, fmt.Errorf("has duplicate numeric keys")
-						}
-						numericKeyDefined = true
-
-						r.NumericKey = ptr.Float64(v)
+			if f.uniqueStringKey {
+				v, err := stringKeyValue(value)
+				if err != nil {
+					return nil, fmt.Errorf("unique string key is invalid: %v", value)
+				}
+				if v != nil && (!omitempty || *v != "") {
+					if uniqueStringKeyDefined {
+						return nil, fmt.Errorf("has duplicate unique string keys")
 					}
-				} else {
-					return nil, fmt.Errorf("numeric key is invalid: %v", value)
+					uniqueStringKeyDefined = true
+
+					r.UniqueStringKey = v
 				}
 			}
 
-			if tagOpts.Contains(timeKeyTag) {
-				if v, ok := value.Interface().(time.Time); ok {
-					if !omitempty || !v.IsZero() {
-						if timeKeyDefined {
-							return nil, fmt.Errorf("has duplicate time keys")
-						}
-						timeKeyDefined = true
+			if f.stringKey {
+				v, err := stringKeyValue(value)
+				if err != nil {
+					return nil, fmt.Errorf("string key is invalid: %v", value)
+				}
+				if v != nil && (!omitempty || *v != "") {
+					if stringKeyDefined {
+						return nil, fmt.Errorf("has duplicate string keys")
+					}
+					stringKeyDefined = true
+
+					r.StringKey = v
+				}
+			}
 
-						r.TimeKey = &v
+			if f.numericKey {
+				kv, err := keyValue(value)
+				v, ok := kv.(float64)
+				if err != nil || (kv != nil && !ok) {
+					return nil, fmt.Errorf("numeric key is invalid: %v", value)
+				}
+				if ok && (!omitempty || v != 0) {
+					if numericKeyDefined {
+						return nil, fmt.Errorf("has duplicate numeric keys")
 					}
-				} else {
+					numericKeyDefined = true
+
+					r.NumericKey = ptr.Float64(v)
+				}
+			}
+
+			if f.timeKey {
+				kv, err := keyValue(value)
+				v, ok := kv.(time.Time)
+				if err != nil || (kv != nil && !ok) {
 					return nil, fmt.Errorf("time key is invalid: %v", value)
 				}
+				if ok && (!omitempty || !v.IsZero()) {
+					if timeKeyDefined {
+						return nil, fmt.Errorf("has duplicate time keys")
+					}
+					timeKeyDefined = true
+
+					r.TimeKey = &v
+				}
 			}
 		}
 	}
 
+	for i := range r.Keys {
+		k := &r.Keys[i]
+		if k.Scope == "" || k.Scope == parentTag {
+			continue
+		}
+		fi, ok := tf.names[k.Scope]
+		if !ok {
+			return nil, fmt.Errorf("index %s scope not found: %s", k.Name, k.Scope)
+		}
+		value := v.Field(tf.fields[fi].index)
+		if value.Kind() == reflect.Ptr && value.IsNil() {
+			continue
+		}
+		value = reflect.Indirect(value)
+		if value.IsValid() {
+			sk, err := newKey(k.Name, true, value)
+			if err != nil {
+				return nil, err
+			}
+			if sk == nil {
+				continue
+			}
+			if len(*sk.Unique) > maxScopeValue {
+				return nil, fmt.Errorf("index %s scope max length 191 characters: %s (%d)", k.Name, *sk.Unique,
+					len(*sk.Unique))
+			}
+			k.ScopeValue = *sk.Unique
+		}
+	}
+
 	return r, nil
 }
 
+// stringKeyValue converts value into a string key, which is nil when the
+// value converts to nil.
+func stringKeyValue(value reflect.Value) (*string, error) {
+	kv, err := keyValue(value)
+	if err != nil || kv == nil {
+		return nil, err
+	}
+	s, ok := kv.(string)
+	if !ok {
+		return nil, fmt.Errorf("key must be a string: %v", value)
+	}
+	return &s, nil
+}
+
 func rowScanInput(kind string, src interface{}) (*row, error) {
 	r, err := rowScanMeta(src, false)
 	if err != nil {
@@ -157,6 +157,13 @@ func TestRowScanMeta(t *testing.T) {
 	requireFieldsSet(t, r, rowFieldsSet{CreateTime: true})
 	require.Equal(t, &now, r.CreateTime)
 
+	createTimeZero := struct {
+		CreateTime time.Time `jdb:"-createtime"`
+	}{}
+	r, err = rowScanMeta(createTimeZero, true)
+	require.NoError(t, err)
+	requireFieldsSet(t, r, rowFieldsSet{})
+
 	createTimeNil := struct {
 		CreateTime *time.Time `jdb:"-createtime"`
 	}{nil}
@@ -1,6 +1,7 @@
 package jdb
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -14,10 +15,31 @@ type Rows struct {
 	*sql.Rows
 
 	columns []SelectField
+
+	ctx   context.Context
+	tx    *Tx
+	kind  string
+	kinds *kindRegistry
+
+	// keys records the key of each scanned struct when set, which is used to
+	// stitch included documents onto their relations.
+	keys *[]rowKey
+
+	// loadKinds records the kind of each scanned struct when set, and leaves
+	// its AfterLoad hooks to the caller, which runs them once the rows are
+	// closed so they can query the transaction.
+	loadKinds *[]string
+}
+
+type rowKey struct {
+	kind       string
+	id         string
+	parentKind string
+	parentID   string
 }
 
 func newRows(rows *sql.Rows, columns []SelectField) *Rows {
-	return &Rows{rows, columns}
+	return &Rows{Rows: rows, columns: columns}
 }
 
 func (rs *Rows) Close() error {
@@ -41,15 +63,25 @@ func (rs *Rows) scan(dest reflect.Value) error {
 		return errors.New("dest must be a struct")
 	}
 	if s.Kind() == reflect.Struct {
-		return rs.scanColumns(dest)
+		kind, err := rs.scanColumns(dest)
+		if err != nil {
+			return err
+		}
+		if rs.loadKinds != nil {
+			*rs.loadKinds = append(*rs.loadKinds, kind)
+			return nil
+		}
+		return rs.kinds.hook(rs.ctx, rs.tx, kind, afterLoadHook, dest.Interface())
 	} else {
 		return rs.Rows.Scan(dest.Interface())
 	}
 }
 
-func (rs *Rows) scanColumns(dest reflect.Value) error {
+// scanColumns scans the current row into the struct dest points at and
+// returns the kind of the row.
+func (rs *Rows) scanColumns(dest reflect.Value) (string, error) {
 	if dest.IsNil() {
-		return errors.New("dest must be non-nil")
+		return "", errors.New("dest must be non-nil")
 	}
 
 	s := dest.Elem()
@@ -75,141 +107,114 @@ func (rs *Rows) scanColumns(dest reflect.Value) error {
 			columns = append(columns, &createTime)
 		case updateTimeField:
 			columns = append(columns, &updateTime)
+		default:
+			columns = append(columns, new(interface{}))
 		}
 	}
 
 	err := rs.Rows.Scan(columns...)
 	if err != nil {
-		return err
+		return "", err
+	}
+
+	rowKind := rs.kind
+	if kind != nil {
+		rowKind = *kind
 	}
 
+	if rs.keys != nil {
+		var key rowKey
+		if kind != nil {
+			key.kind = *kind
+		}
+		if id != nil {
+			key.id = *id
+		}
+		if parentKind != nil {
+			key.parentKind = *parentKind
+		}
+		if parentID != nil {
+			key.parentID = *parentID
+		}
+		*rs.keys = append(*rs.keys, key)
+	}
+
+	var version int
 	if data != nil && *data != "" {
-		err = json.Unmarshal([]byte(*data), dest.Interface())
+		var b []byte
+		b, version, err = rs.kinds.upgrade(rowKind, []byte(*data))
 		if err != nil {
-			return err
+			return "", err
+		}
+		err = json.Unmarshal(b, dest.Interface())
+		if err != nil {
+			return "", err
 		}
 	}
 
-	t := s.Type()
-	for i := 0; i < t.NumField(); i++ {
-		field := t.Field(i)
-		if field.PkgPath != "" {
+	tf := cachedTypeFields(s.Type())
+	for i := range tf.fields {
+		f := &tf.fields[i]
+		if !f.meta {
 			continue
 		}
 
-		value := s.Field(i)
-		if !value.IsValid() {
-			continue
-		}
+		value := s.Field(f.index)
 		if !value.CanSet() {
 			continue
 		}
+		name := f.name
 
-		tag := field.Tag.Get(tagName)
-		if tag == "" {
-			continue
-		}
-		name, _ := json.ParseTag(tag)
-
+		var src interface{}
 		switch name {
 		case idTag:
-			if id == nil {
-				continue
-			}
-			var tv reflect.Value
-			if value.Kind() == reflect.Ptr {
-				tv = reflect.ValueOf(id)
-			} else {
-				tv = reflect.ValueOf(*id)
-			}
-			if value.Kind() != tv.Kind() {
-				return fmt.Errorf("%s must be a string", idTag)
+			if id != nil {
+				src = *id
 			}
-			value.Set(tv)
 		case kindTag:
-			if kind == nil {
-				continue
+			if kind != nil {
+				src = *kind
 			}
-			var tv reflect.Value
-			if value.Kind() == reflect.Ptr {
-				tv = reflect.ValueOf(kind)
-			} else {
-				tv = reflect.ValueOf(*kind)
-			}
-			if value.Kind() != tv.Kind() {
-				return fmt.Errorf("%s must be a string", kindTag)
-			}
-			value.Set(tv)
 		case parentKindTag:
-			if parentKind == nil {
-				continue
-			}
-			var tv reflect.Value
-			if value.Kind() == reflect.Ptr {
-				tv = reflect.ValueOf(parentKind)
-			} else if parentKind != nil {
-				tv = reflect.ValueOf(*parentKind)
-			} else {
-				tv = stringValue
-			}
-			if value.Kind() != tv.Kind() {
-				return fmt.Errorf("%s must be a string", parentKindTag)
-			}
 			if parentKind != nil {
-				value.Set(tv)
+				src = *parentKind
 			}
 		case parentIDTag:
-			if parentID == nil {
-				continue
-			}
-			var tv reflect.Value
-			if value.Kind() == reflect.Ptr {
-				tv = reflect.ValueOf(parentID)
-			} else if parentID != nil {
-				tv = reflect.ValueOf(*parentID)
-			} else {
-				tv = stringValue
-			}
-			if value.Kind() != tv.Kind() {
-				return fmt.Errorf("%s must be a string", parentIDTag)
-			}
 			if parentID != nil {
-				value.Set(tv)
+				src = *parentID
 			}
 		case createTimeTag:
-			if createTime == nil {
-				continue
-			}
-			var tv reflect.Value
-			if value.Kind() == reflect.Ptr {
-				tv = reflect.ValueOf(createTime)
-			} else {
-				tv = reflect.ValueOf(*createTime)
-			}
-			if value.Kind() != tv.Kind() {
-				return fmt.Errorf("%s must be a time.Time value", createTimeTag)
+			if createTime != nil {
+				src = *createTime
 			}
-			value.Set(tv)
 		case updateTimeTag:
-			if updateTime == nil {
-				continue
+			if updateTime != nil {
+				src = *updateTime
 			}
-			var tv reflect.Value
-			if value.Kind() == reflect.Ptr {
-				tv = reflect.ValueOf(updateTime)
-			} else {
-				tv = reflect.ValueOf(*updateTime)
-			}
-			if value.Kind() != tv.Kind() {
-				return fmt.Errorf("%s must be a time.Time value", updateTimeTag)
+		case schemaVersionTag:
+			src = version
+		}
+		if src == nil {
+			continue
+		}
+
+		if err := f.set(value, src); err != nil {
+			switch src.(type) {
+			case time.Time:
+				return "", fmt.Errorf("%s must be a time.Time value", name)
+			case int:
+				return "", fmt.Errorf("%s must be an int", name)
 			}
-			value.Set(tv)
+			return "", fmt.Errorf("%s must be a string", name)
 		}
 	}
 
-	return nil
+	return rowKind, nil
 }
 
+// Scan copies the columns of the current row into dest. The AfterLoad hooks
+// of dest run while the rows are open, so on mysql and postgres they can not
+// query the transaction, unlike the hooks run by First and All.
 func (rs *Rows) Scan(dest interface{}) error {
 	return rs.scan(reflect.ValueOf(dest))
 }
@@ -0,0 +1,145 @@
+package jdb
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/silas/jdb/internal/schema"
+)
+
+type SchemaViolation = schema.Violation
+
+// ValidationError is returned when a document does not match the schema
+// registered for its kind.
+type ValidationError struct {
+	Kind       string
+	ID         string
+	Violations []SchemaViolation
+}
+
+func (e *ValidationError) Error() string {
+	reasons := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		pointer := v.Pointer
+		if pointer == "" {
+			pointer = "/"
+		}
+		reasons[i] = pointer + ": " + v.Reason
+	}
+	return fmt.Sprintf("jdb: %s/%s is invalid: %s", e.Kind, e.ID, strings.Join(reasons, ", "))
+}
+
+type kindRegistry struct {
+	mu      sync.RWMutex
+	schemas map[string]*schema.Schema
+	names   map[string]reflect.Type
+	types   map[reflect.Type]string
+
+	upgrades map[string][]UpgradeFunc
+	hooks    map[string]Hooks
+}
+
+func newKindRegistry() *kindRegistry {
+	return &kindRegistry{
+		schemas: map[string]*schema.Schema{},
+		names:   map[string]reflect.Type{},
+		types:   map[reflect.Type]string{},
+
+		upgrades: map[string][]UpgradeFunc{},
+		hooks:    map[string]Hooks{},
+	}
+}
+
+func (r *kindRegistry) schema(kind string) *schema.Schema {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.schemas[kind]
+}
+
+// RegisterSchema sets the JSON Schema that inserted and updated documents of
+// kind are validated against, a nil schema removes it.
+func (c *Client) RegisterSchema(kind string, data []byte) error {
+	c.kinds.mu.Lock()
+	defer c.kinds.mu.Unlock()
+
+	if data == nil {
+		delete(c.kinds.schemas, kind)
+		return nil
+	}
+
+	s, err := schema.Compile(data)
+	if err != nil {
+		return fmt.Errorf("jdb: %s %s", kind, err)
+	}
+	c.kinds.schemas[kind] = s
+	return nil
+}
+
+// validate checks the data of r against the schema registered for its kind.
+func (q *Query) validate(r *row) error {
+	s := q.kinds.schema(r.Kind)
+	if s == nil {
+		return nil
+	}
+	return validateData(s, r.Kind, r.ID, r.Data)
+}
+
+func validateData(s *schema.Schema, kind, id string, data *string) error {
+	value := "null"
+	if data != nil {
+		value = *data
+	}
+	violations, err := s.Validate([]byte(value))
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return &ValidationError{Kind: kind, ID: id, Violations: violations}
+	}
+	return nil
+}
+
+// ValidateKind checks every document of kind against its registered schema
+// and returns the documents that do not match.
+func (c *Client) ValidateKind(ctx context.Context, kind string) ([]*ValidationError, error) {
+	s := c.kinds.schema(kind)
+	if s == nil {
+		return nil, fmt.Errorf("jdb: schema not registered: %s", kind)
+	}
+
+	var errs []*ValidationError
+	err := c.View(ctx, func(tx *Tx) error {
+		errs = nil
+		rows, err := c.Query(kind).Select(c.ID, c.Data).OrderBy(c.ID.Asc()).Rows(ctx, tx)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var doc Document
+			if err := rows.Scan(&doc); err != nil {
+				return err
+			}
+			var data *string
+			if doc.Data != nil {
+				value := string(doc.Data)
+				data = &value
+			}
+			err := validateData(s, kind, doc.ID, data)
+			if e, ok := err.(*ValidationError); ok {
+				errs = append(errs, e)
+			} else if err != nil {
+				return err
+			}
+		}
+		return rows.Err()
+	})
+	return errs, err
+}
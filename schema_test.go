@@ -0,0 +1,78 @@
+package jdb
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+type schemaUser struct {
+	ID  string      `jdb:"-id"`
+	Age interface{} `jdb:"age"`
+}
+
+const schemaUserSchema = `{"type": "object", "properties": {"age": {"type": "integer"}}, "required": ["age"]}`
+
+func TestClient_RegisterSchema(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	require.NoError(t, c.RegisterSchema("user", []byte(schemaUserSchema)))
+
+	_, _, err := c.Query("user").Insert(schemaUser{ID: "1", Age: 30}).ToSQL()
+	require.NoError(t, err)
+
+	_, _, err = c.Query("user").Insert(schemaUser{ID: "1", Age: 30}, schemaUser{ID: "2", Age: "thirty"}).ToSQL()
+	require.EqualError(t, err, "jdb: user/2 is invalid: /age: expected integer, got string")
+	require.Equal(t, &ValidationError{
+		Kind:       "user",
+		ID:         "2",
+		Violations: []SchemaViolation{{Pointer: "/age", Reason: "expected integer, got string"}},
+	}, err)
+
+	_, _, err = c.Query("user").Update(schemaUser{ID: "1"}).ToSQL()
+	require.EqualError(t, err, "jdb: user/1 is invalid: /age: expected integer, got null")
+
+	_, _, err = c.Query("post").Insert(schemaUser{ID: "1", Age: "thirty"}).ToSQL()
+	require.NoError(t, err)
+
+	require.NoError(t, c.RegisterSchema("user", nil))
+	_, _, err = c.Query("user").Insert(schemaUser{ID: "2", Age: "thirty"}).ToSQL()
+	require.NoError(t, err)
+
+	err = c.RegisterSchema("user", []byte(`{"type": "text"}`))
+	require.EqualError(t, err, "jdb: user schema/type: unknown type: text")
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClient_ValidateKind(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	_, err := c.ValidateKind(context.Background(), "user")
+	require.EqualError(t, err, "jdb: schema not registered: user")
+
+	require.NoError(t, c.RegisterSchema("user", []byte(schemaUserSchema)))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, data FROM jdb WHERE ((kind = ?)) ORDER BY id ASC")).
+		WithArgs("user").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "data"}).
+			AddRow("1", `{"age": 30}`).
+			AddRow("2", `{"age": "thirty"}`).
+			AddRow("3", nil))
+	mock.ExpectRollback()
+
+	errs, err := c.ValidateKind(context.Background(), "user")
+	require.NoError(t, err)
+	require.Equal(t, []*ValidationError{
+		{Kind: "user", ID: "2", Violations: []SchemaViolation{{Pointer: "/age", Reason: "expected integer, got string"}}},
+		{Kind: "user", ID: "3", Violations: []SchemaViolation{{Pointer: "", Reason: "expected object, got null"}}},
+	}, errs)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -1,8 +1,9 @@
 package jdb
 
 import (
-	"bytes"
 	"context"
+	"errors"
+	"reflect"
 	"strconv"
 )
 
@@ -16,6 +17,9 @@ type SelectBuilder struct {
 	offset        uint64
 	offsetDefined bool
 	order         []Order
+	defaultOrder  []Order
+	includes      []string
+	preloads      []string
 }
 
 type selectCount struct{}
@@ -23,8 +27,9 @@ type selectCount struct{}
 var defaultSelectColumns = []SelectField{
 	kindField, idField, parentKindField, parentIdField, dataField, createTimeField, updateTimeField}
 
-func (sc selectCount) toSelectField() string {
-	return "count(*) AS count"
+func (sc selectCount) SelectFieldSQL(w *SQLWriter) error {
+	w.WriteString("count(*) AS count")
+	return nil
 }
 
 func newSelectBuilder(q *Query, wb *WhereBuilder, columns []SelectField) *SelectBuilder {
@@ -75,55 +80,122 @@ func (b *SelectBuilder) OrderBy(order ...Order) *SelectBuilder {
 	return &n
 }
 
+// Include loads related documents into fields tagged with children=<kind>,
+// included by kind, or parent, included as "parent", using one query per
+// relation.
+func (b *SelectBuilder) Include(names ...string) *SelectBuilder {
+	if len(names) == 0 {
+		return b
+	}
+	n := *b
+	n.includes = make([]string, len(b.includes)+len(names))
+	start := copy(n.includes, b.includes)
+	copy(n.includes[start:], names)
+	return &n
+}
+
+// Preload loads documents referenced by the ref field name+"ID" into the
+// field name, using one query per field.
+func (b *SelectBuilder) Preload(names ...string) *SelectBuilder {
+	if len(names) == 0 {
+		return b
+	}
+	n := *b
+	n.preloads = make([]string, len(b.preloads)+len(names))
+	start := copy(n.preloads, b.preloads)
+	copy(n.preloads[start:], names)
+	return &n
+}
+
+func (b *SelectBuilder) load(ctx context.Context, tx *Tx, t reflect.Type, values []reflect.Value,
+	keys []rowKey) error {
+
+	if t.Kind() != reflect.Struct {
+		return errors.New("dest must be a struct")
+	}
+	if err := b.include(ctx, tx, t, values, keys); err != nil {
+		return err
+	}
+	return b.preload(ctx, tx, t, values)
+}
+
 func (b *SelectBuilder) ToSQL() (string, []interface{}, error) {
-	var params []interface{}
-	query := &bytes.Buffer{}
+	w := newSQLWriter(b.q.d, b.q.table)
 
-	query.WriteString("SELECT ")
+	if b.wb.tree != nil {
+		b.wb.tree.toWithSQL(w, b.q.table)
+	}
+
+	w.WriteString("SELECT ")
 	for i, c := range b.columns {
 		if i != 0 {
-			query.WriteString(", ")
+			w.WriteString(", ")
+		}
+		if err := c.SelectFieldSQL(w); err != nil {
+			return "", nil, err
 		}
-		query.WriteString(c.toSelectField())
 	}
-	query.WriteString(" ")
-	query.WriteString("FROM ")
-	query.WriteString(b.q.table)
-	query.WriteString(" ")
+	w.WriteString(" ")
+	w.WriteString("FROM ")
+	w.WriteString(b.q.table)
+	w.WriteString(" ")
 
-	err := b.wb.toWhereSQL(query, &params)
+	if b.wb.tree != nil {
+		b.wb.tree.toJoinSQL(w, b.q.table)
+	}
+
+	err := b.wb.toWhereSQL(w)
 	if err != nil {
 		return "", nil, err
 	}
 
-	for i, order := range b.order {
+	orders := b.order
+	if len(orders) == 0 {
+		orders = b.defaultOrder
+	}
+	for i, order := range orders {
 		if i == 0 {
-			query.WriteString(" ORDER BY ")
+			w.WriteString(" ORDER BY ")
 		} else {
-			query.WriteString(", ")
+			w.WriteString(", ")
+		}
+		if err := order.OrderSQL(w); err != nil {
+			return "", nil, err
 		}
-		query.WriteString(b.q.d.OrderExpression(order))
 	}
 
 	if b.limitDefined {
-		query.WriteString(" LIMIT ")
-		query.WriteString(strconv.FormatUint(b.limit, 10))
+		w.WriteString(" LIMIT ")
+		w.WriteString(strconv.FormatUint(b.limit, 10))
 	}
 
 	if b.offsetDefined {
-		query.WriteString(" OFFSET ")
-		query.WriteString(strconv.FormatUint(b.offset, 10))
+		w.WriteString(" OFFSET ")
+		w.WriteString(strconv.FormatUint(b.offset, 10))
 	}
 
-	return b.q.d.ReplacePlaceHolders(query.String()), params, nil
+	query, params := w.toSQL()
+	return query, params, nil
+}
+
+func (b *SelectBuilder) Rows(ctx context.Context, e Executor) (*Rows, error) {
+	var rows *Rows
+	err := e.run(ctx, queryMode, func(tx *Tx) error {
+		var err error
+		rows, err = tx.query(ctx, b)
+		return err
+	})
+	return rows, err
 }
 
-func (b *SelectBuilder) Rows(ctx context.Context, tx *Tx) (*Rows, error) {
-	return tx.query(ctx, b)
+func (b *SelectBuilder) First(ctx context.Context, e Executor, dest interface{}) error {
+	return e.run(ctx, b.runMode(dest), func(tx *Tx) error {
+		return b.first(ctx, tx, dest)
+	})
 }
 
-func (b *SelectBuilder) First(ctx context.Context, tx *Tx, dest interface{}) error {
-	rows, err := b.Rows(ctx, tx)
+func (b *SelectBuilder) first(ctx context.Context, tx *Tx, dest interface{}) error {
+	rows, err := tx.query(ctx, b)
 	if err != nil {
 		return err
 	}
@@ -136,15 +208,84 @@ func (b *SelectBuilder) First(ctx context.Context, tx *Tx, dest interface{}) err
 		return ErrNotFound
 	}
 
-	return rows.Scan(dest)
+	var keys []rowKey
+	var kinds []string
+	if b.loads() {
+		rows.keys = &keys
+	}
+	rows.loadKinds = &kinds
+	if err := rows.Scan(dest); err != nil {
+		return err
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+
+	v := reflect.ValueOf(dest).Elem()
+	if b.loads() {
+		if err := b.load(ctx, tx, v.Type(), []reflect.Value{v}, keys); err != nil {
+			return err
+		}
+	}
+	return b.q.kinds.afterLoad(ctx, tx, kinds, []reflect.Value{v})
+}
+
+func (b *SelectBuilder) All(ctx context.Context, e Executor, dest interface{}) error {
+	return e.run(ctx, b.runMode(dest), func(tx *Tx) error {
+		return b.all(ctx, tx, dest)
+	})
 }
 
-func (b *SelectBuilder) All(ctx context.Context, tx *Tx, dest interface{}) error {
-	rows, err := b.Rows(ctx, tx)
+func (b *SelectBuilder) all(ctx context.Context, tx *Tx, dest interface{}) error {
+	rows, err := tx.query(ctx, b)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
-	return rows.ScanAll(dest)
+	var keys []rowKey
+	var kinds []string
+	if b.loads() {
+		rows.keys = &keys
+	}
+	rows.loadKinds = &kinds
+	if err := rows.ScanAll(dest); err != nil {
+		return err
+	}
+	if err := rows.Close(); err != nil {
+		return err
+	}
+	if len(kinds) == 0 {
+		return nil
+	}
+
+	v := reflect.ValueOf(dest).Elem()
+	t := v.Type().Elem()
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	values := make([]reflect.Value, v.Len())
+	for i := range values {
+		values[i] = reflect.Indirect(v.Index(i))
+	}
+	if b.loads() {
+		if err := b.load(ctx, tx, t, values, keys); err != nil {
+			return err
+		}
+	}
+	return b.q.kinds.afterLoad(ctx, tx, kinds, values)
+}
+
+// runMode returns snapshotMode when reading into dest takes more than one
+// statement, for includes, preloads or AfterLoad hooks.
+func (b *SelectBuilder) runMode(dest interface{}) runMode {
+	if b.loads() || b.q.kinds.loadHooks(b.q.kind, reflect.TypeOf(dest)) {
+		return snapshotMode
+	}
+	return queryMode
+}
+
+// loads reports whether the builder includes or preloads documents.
+func (b *SelectBuilder) loads() bool {
+	return len(b.includes) != 0 || len(b.preloads) != 0
 }
@@ -68,6 +68,13 @@ func TestSelectBuilder_ToSQL(t *testing.T) {
 			fmt.Sprintf("SELECT %s %s WHERE ((kind = ?) AND (string_key = ?))", columns, from),
 			params(kind, "example.com"),
 		},
+		{
+			c.Query(kind).Where(Raw("numeric_key > ?", 5)).Select(RawField("numeric_key * ?", 2)).
+				OrderBy(RawField("coalesce(string_key, ?)", "z").Desc()),
+			fmt.Sprintf("SELECT numeric_key * ? %s WHERE ((kind = ?) AND (numeric_key > ?)) "+
+				"ORDER BY coalesce(string_key, ?) DESC", from),
+			params(2, kind, 5, "z"),
+		},
 	}
 
 	for i, test := range tests {
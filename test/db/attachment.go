@@ -0,0 +1,118 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/silas/jdb"
+	"github.com/stretchr/testify/require"
+)
+
+type attachmentUser struct {
+	ID string `jdb:"-id"`
+}
+
+func (dt *Test) testAttachments(t *testing.T) {
+	db := dt.setup(t, false)
+
+	ctx := context.Background()
+	users := db.Query("user")
+
+	count := func(table string) int {
+		var count int
+		err := dt.sqlDB.QueryRow(dt.sql(`SELECT count(*) FROM ` + table)).Scan(&count)
+		require.NoError(t, err)
+		return count
+	}
+	blobs := func() int {
+		return count("jdb_test_blobs")
+	}
+
+	// larger than the chunk size, so it is stored as several chunks
+	large := bytes.Repeat([]byte("0123456789"), 250000)
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		err := users.Insert(attachmentUser{ID: "1"}, attachmentUser{ID: "2"}).Exec(ctx, tx)
+		require.NoError(t, err)
+
+		a, err := tx.PutAttachment(ctx, "user", "1", "avatar.png", "image/png", strings.NewReader("png"))
+		require.NoError(t, err)
+		require.Equal(t, int64(3), a.Size)
+
+		_, err = tx.PutAttachment(ctx, "user", "1", "cv.pdf", "application/pdf", strings.NewReader("pdf"))
+		require.NoError(t, err)
+
+		b, err := tx.PutAttachment(ctx, "user", "2", "avatar.png", "image/png", strings.NewReader("png"))
+		require.NoError(t, err)
+		require.Equal(t, a.SHA256, b.SHA256)
+
+		_, err = tx.PutAttachment(ctx, "user", "3", "avatar.png", "image/png", strings.NewReader("png"))
+		require.Equal(t, jdb.ErrIDNotFound, err)
+
+		c, err := tx.PutAttachment(ctx, "user", "2", "data.bin", "application/octet-stream",
+			bytes.NewReader(large))
+		require.NoError(t, err)
+		require.Equal(t, int64(len(large)), c.Size)
+
+		return tx.Commit()
+	}))
+	require.Equal(t, 3, blobs())
+	require.Equal(t, 5, count("jdb_test_blob_chunks"))
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		buf := &bytes.Buffer{}
+		_, err := tx.GetAttachment(ctx, "user", "2", "data.bin", buf)
+		require.NoError(t, err)
+		require.Equal(t, large, buf.Bytes())
+
+		require.NoError(t, tx.DeleteAttachment(ctx, "user", "2", "data.bin"))
+
+		return tx.Commit()
+	}))
+	require.Equal(t, 2, blobs())
+	require.Equal(t, 2, count("jdb_test_blob_chunks"))
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		attachments, err := tx.ListAttachments(ctx, "user", "1")
+		require.NoError(t, err)
+		require.Len(t, attachments, 2)
+		require.Equal(t, "avatar.png", attachments[0].Name)
+		require.Equal(t, "application/pdf", attachments[1].ContentType)
+
+		buf := &bytes.Buffer{}
+		_, err = tx.GetAttachment(ctx, "user", "2", "avatar.png", buf)
+		require.NoError(t, err)
+		require.Equal(t, "png", buf.String())
+
+		_, err = tx.PutAttachment(ctx, "user", "1", "cv.pdf", "application/pdf", strings.NewReader("pdf2"))
+		require.NoError(t, err)
+
+		buf.Reset()
+		_, err = tx.GetAttachment(ctx, "user", "1", "cv.pdf", buf)
+		require.NoError(t, err)
+		require.Equal(t, "pdf2", buf.String())
+
+		return tx.Commit()
+	}))
+	require.Equal(t, 2, blobs())
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		require.NoError(t, users.Delete("1").Exec(ctx, tx))
+		return tx.Commit()
+	}))
+	require.Equal(t, 1, blobs())
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		require.NoError(t, tx.DeleteAttachment(ctx, "user", "2", "avatar.png"))
+		require.Equal(t, jdb.ErrNotFound, tx.DeleteAttachment(ctx, "user", "2", "avatar.png"))
+
+		_, err := tx.GetAttachment(ctx, "user", "2", "avatar.png", &bytes.Buffer{})
+		require.Equal(t, jdb.ErrNotFound, err)
+
+		return tx.Commit()
+	}))
+	require.Equal(t, 0, blobs())
+	require.Equal(t, 0, count("jdb_test_blob_chunks"))
+}
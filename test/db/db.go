@@ -57,10 +57,20 @@ func (dt *Test) Run(t *testing.T) {
 	dt.sqlDB = sqlDB
 
 	dt.testClient(t)
+	dt.testNested(t)
 	dt.testDelete(t)
 	dt.testSelect(t)
 	dt.testInsert(t)
 	dt.testUpdate(t)
+	dt.testTree(t)
+	dt.testRefs(t)
+	dt.testIndex(t)
+	dt.testIndexScope(t)
+	dt.testPathIndex(t)
+	dt.testFilter(t)
+	dt.testAttachments(t)
+	dt.testUpgrade(t)
+	dt.testHooks(t)
 }
 
 func (dt *Test) setup(t *testing.T, populate bool) *jdb.Client {
@@ -122,8 +132,13 @@ func (dt *Test) count(t *testing.T, kind string) int {
 }
 
 func (dt *Test) deleteAll(t *testing.T) {
-	s := `DELETE FROM jdb_test WHERE kind != ?`
-	dt.exec(t, s, "jdb")
+	dt.exec(t, `DELETE FROM jdb_test_refs`)
+	dt.exec(t, `DELETE FROM jdb_test_keys`)
+	dt.exec(t, `DELETE FROM jdb_test_attachments`)
+	dt.exec(t, `DELETE FROM jdb_test_blob_chunks`)
+	dt.exec(t, `DELETE FROM jdb_test_blobs`)
+	dt.exec(t, `UPDATE jdb_test SET parent_kind = NULL, parent_id = NULL WHERE kind != ?`, "jdb")
+	dt.exec(t, `DELETE FROM jdb_test WHERE kind != ?`, "jdb")
 }
 
 func (dt *Test) insertRaw(t *testing.T, args ...interface{}) {
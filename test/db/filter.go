@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/silas/jdb"
+	"github.com/silas/jdb/filter"
+	"github.com/stretchr/testify/require"
+)
+
+type filterUser struct {
+	ID      string      `jdb:"-id"`
+	Age     interface{} `jdb:"age"`
+	Aliases []string    `jdb:"aliases,index=by_alias"`
+	Name    filterName  `jdb:"Name"`
+}
+
+type filterName struct {
+	Aliases []string `jdb:"Aliases"`
+}
+
+func (dt *Test) testFilter(t *testing.T) {
+	db := dt.setup(t, false)
+
+	ctx := context.Background()
+	users := db.Query("user")
+
+	f, err := filter.New(db,
+		filter.Field("age"),
+		filter.Column("aliases", db.Index("by_alias"), "$in", "$nin"),
+		filter.Field("Name.Aliases"),
+	)
+	require.NoError(t, err)
+
+	require.NoError(t, db.Update(ctx, func(tx *jdb.Tx) error {
+		return users.Insert(
+			filterUser{ID: "1", Age: 9, Aliases: []string{"Doe", "Roe"}, Name: filterName{[]string{"Doe", "Roe"}}},
+			filterUser{ID: "2", Age: 10, Aliases: []string{"Roe"}, Name: filterName{[]string{"Roe"}}},
+			filterUser{ID: "3", Age: 100.5},
+			filterUser{ID: "4", Age: "50", Aliases: []string{"Poe"}, Name: filterName{[]string{"Poe"}}},
+		).Exec(ctx, tx)
+	}))
+
+	tests := []struct {
+		Filter string
+		IDs    []string
+	}{
+		{`{"age":{"$gt":9}}`, []string{"2", "3"}},
+		{`{"age":{"$gte":9,"$lt":100}}`, []string{"1", "2"}},
+		{`{"age":10}`, []string{"2"}},
+		{`{"age":"50"}`, []string{"4"}},
+		{`{"age":{"$in":[9,100.5]}}`, []string{"1", "3"}},
+		{`{"age":{"$in":[9,"50","10.0"]}}`, []string{"1", "4"}},
+		{`{"age":{"$nin":[9,"50"]}}`, []string{"2", "3"}},
+		{`{"aliases":{"$in":["Roe"]}}`, []string{"1", "2"}},
+		{`{"aliases":{"$nin":["Roe"]}}`, []string{"3", "4"}},
+		{`{"$or":[{"aliases":{"$in":["Poe"]}},{"age":{"$lte":9}}]}`, []string{"1", "4"}},
+		{`{"age":{"$gte":10},"Name.Aliases":{"$in":["Roe"]}}`, []string{"2"}},
+		{`{"Name.Aliases":{"$in":["Doe","Poe"]}}`, []string{"1", "4"}},
+		{`{"Name.Aliases":{"$nin":["Roe"]}}`, []string{"3", "4"}},
+		{`{"Name.Aliases":{"$in":["Roe",null]}}`, []string{"1", "2", "3"}},
+	}
+
+	for _, test := range tests {
+		cond, err := f.Parse([]byte(test.Filter))
+		require.NoError(t, err, test.Filter)
+
+		var ids []string
+		err = users.Where(cond).Select(db.ID).OrderBy(db.ID.Asc()).All(ctx, db, &ids)
+		require.NoError(t, err, test.Filter)
+		require.Equal(t, test.IDs, ids, test.Filter)
+	}
+}
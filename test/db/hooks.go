@@ -0,0 +1,95 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/silas/jdb"
+	"github.com/stretchr/testify/require"
+)
+
+type hookPost struct {
+	ID       string        `jdb:"-id"`
+	Title    string        `jdb:"title"`
+	Comments []hookComment `jdb:",children=hook_comment"`
+	Count    int           `jdb:"-"`
+}
+
+type hookComment struct {
+	ID         string `jdb:"-id"`
+	ParentKind string `jdb:"-parentkind"`
+	ParentID   string `jdb:"-parentid"`
+	Siblings   int    `jdb:"-"`
+}
+
+type hookOrder struct {
+	ID     string    `jdb:"-id"`
+	PostID string    `jdb:"post_id,ref=hook_post"`
+	Post   *hookPost `jdb:"-"`
+}
+
+func (dt *Test) testHooks(t *testing.T) {
+	db := dt.setup(t, false)
+
+	ctx := context.Background()
+	posts := db.Query("hook_post")
+	comments := db.Query("hook_comment")
+
+	// the hooks query the transaction, which requires the rows to be closed
+	children := func(ctx context.Context, tx *jdb.Tx, kind, id string) (int, error) {
+		var count int
+		err := comments.Where(jdb.Eq(db.ParentKind, kind), jdb.Eq(db.ParentId, id)).Count().First(ctx, tx, &count)
+		return count, err
+	}
+	db.RegisterHooks("hook_post", jdb.Hooks{
+		AfterLoad: func(ctx context.Context, tx *jdb.Tx, v interface{}) error {
+			p := v.(*hookPost)
+			var err error
+			p.Count, err = children(ctx, tx, "hook_post", p.ID)
+			return err
+		},
+	})
+	db.RegisterHooks("hook_comment", jdb.Hooks{
+		AfterLoad: func(ctx context.Context, tx *jdb.Tx, v interface{}) error {
+			c := v.(*hookComment)
+			var err error
+			c.Siblings, err = children(ctx, tx, c.ParentKind, c.ParentID)
+			return err
+		},
+	})
+
+	require.NoError(t, db.Update(ctx, func(tx *jdb.Tx) error {
+		require.NoError(t, posts.Insert(hookPost{ID: "p1", Title: "One"}, hookPost{ID: "p2", Title: "Two"}).
+			Exec(ctx, tx))
+		require.NoError(t, db.Query("hook_order").Insert(hookOrder{ID: "o1", PostID: "p1"}).Exec(ctx, tx))
+		return comments.Under("hook_post", "p1").Insert(hookComment{ID: "c1"}, hookComment{ID: "c2"}).
+			Exec(ctx, tx)
+	}))
+
+	require.NoError(t, db.View(ctx, func(tx *jdb.Tx) error {
+		var all []hookPost
+		err := posts.Select().OrderBy(db.ID.Asc()).Include("hook_comment").All(ctx, tx, &all)
+		require.NoError(t, err)
+		require.Len(t, all, 2)
+		require.Equal(t, 2, all[0].Count)
+		require.Len(t, all[0].Comments, 2)
+		for _, c := range all[0].Comments {
+			require.Equal(t, 2, c.Siblings)
+		}
+		require.Equal(t, 0, all[1].Count)
+
+		var post hookPost
+		require.NoError(t, posts.Get("p1").Select().First(ctx, tx, &post))
+		require.Equal(t, 2, post.Count)
+
+		var order hookOrder
+		require.NoError(t, db.Query("hook_order").Get("o1").Select().Preload("Post").First(ctx, tx, &order))
+		require.Equal(t, 2, order.Post.Count)
+
+		return nil
+	}))
+
+	var post hookPost
+	require.NoError(t, posts.Get("p1").Select().First(ctx, db, &post))
+	require.Equal(t, 2, post.Count)
+}
@@ -0,0 +1,189 @@
+package db
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/silas/jdb"
+	"github.com/stretchr/testify/require"
+)
+
+type indexUser struct {
+	ID       string    `jdb:"-id"`
+	Email    string    `jdb:",unique=by_email"`
+	Domain   string    `jdb:",index=by_domain"`
+	Backup   string    `jdb:",unique=by_backup,omitempty"`
+	Age      int       `jdb:",index=by_age"`
+	JoinTime time.Time `jdb:",index=by_join_time"`
+	Roles    []string  `jdb:",index=by_role"`
+}
+
+func (dt *Test) testIndex(t *testing.T) {
+	db := dt.setup(t, false)
+
+	ctx := context.Background()
+	users := db.Query("user")
+	joinTime := time.Date(2005, 3, 7, 8, 23, 34, 0, time.UTC)
+	west := time.FixedZone("", -5*3600)
+	east := time.FixedZone("", 9*3600)
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		err := users.Insert(
+			indexUser{ID: "1", Email: "a@example.com", Domain: "example.com", Age: 30, JoinTime: joinTime,
+				Roles: []string{"admin", "editor"}},
+			indexUser{ID: "2", Email: "b@example.com", Domain: "example.com", Age: 40,
+				JoinTime: joinTime.Add(time.Hour), Roles: []string{"editor"}},
+			indexUser{ID: "3", Email: "c@example.org", Domain: "example.org", Age: 50,
+				JoinTime: joinTime.Add(2 * time.Hour).In(west)},
+		).Exec(ctx, tx)
+		require.NoError(t, err)
+
+		var ids []string
+		err = users.Where(jdb.Eq(db.Index("by_domain"), "example.com")).Select(db.ID).
+			OrderBy(db.Index("by_email").Desc()).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"2", "1"}, ids)
+
+		err = users.Where(jdb.Gte(db.Index("by_age").Numeric(), 40)).Select(db.ID).
+			OrderBy(db.Index("by_age").Numeric().Asc()).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"2", "3"}, ids)
+
+		err = users.Where(jdb.Gt(db.Index("by_join_time").Time(), joinTime)).Select(db.ID).
+			OrderBy(db.ID.Asc()).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"2", "3"}, ids)
+
+		// times in other zones compare by instant
+		err = users.Where(jdb.Lt(db.Index("by_join_time").Time(), joinTime.Add(90*time.Minute).In(east))).
+			Select(db.ID).OrderBy(db.Index("by_join_time").Time().Desc()).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"2", "1"}, ids)
+
+		err = users.Where(jdb.AnyKey("by_role", "admin", "editor")).Select(db.ID).
+			OrderBy(db.ID.Asc()).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"1", "2"}, ids)
+
+		err = users.Where(jdb.AllKeys("by_role", "admin", "editor")).Select(db.ID).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"1"}, ids)
+
+		err = users.Where(jdb.Eq(db.Index("by_role"), "editor")).Select(db.ID).
+			OrderBy(db.ID.Asc()).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"1", "2"}, ids)
+
+		err = users.Where(jdb.NotIn(db.Index("by_role"), "admin")).Select(db.ID).
+			OrderBy(db.Index("by_role").Desc(), db.ID.Asc()).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"2", "3"}, ids)
+
+		err = users.Where(jdb.Eq(db.Index("by_role"), nil)).Select(db.ID).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"3"}, ids)
+
+		err = users.Update(indexUser{ID: "2", Email: "b@example.com", Domain: "example.com", Age: 40,
+			JoinTime: joinTime.Add(time.Hour), Roles: []string{"viewer"}}).Exec(ctx, tx)
+		require.NoError(t, err)
+
+		err = users.Where(jdb.AnyKey("by_role", "editor", "viewer")).Select(db.ID).
+			OrderBy(db.ID.Asc()).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"1", "2"}, ids)
+
+		err = users.Where(jdb.AnyKey("by_role", "editor")).Select(db.ID).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"1"}, ids)
+
+		err = users.Update(indexUser{ID: "3", Email: "c@example.com", Domain: "example.com", Age: 50}).Exec(ctx, tx)
+		require.NoError(t, err)
+
+		var count int
+		err = users.Where(jdb.Eq(db.Index("by_domain"), "example.com")).Count().First(ctx, tx, &count)
+		require.NoError(t, err)
+		require.Equal(t, 3, count)
+
+		err = users.Where(jdb.Eq(db.Index("by_email"), "c@example.org")).Count().First(ctx, tx, &count)
+		require.NoError(t, err)
+		require.Equal(t, 0, count)
+
+		err = users.Delete("1").Exec(ctx, tx)
+		require.NoError(t, err)
+
+		err = users.Insert(indexUser{ID: "4", Email: "a@example.com"}).Exec(ctx, tx)
+		require.NoError(t, err)
+
+		return tx.Commit()
+	}))
+
+	err := db.Tx(ctx, func(tx *jdb.Tx) error {
+		return users.Insert(indexUser{ID: "5", Email: "b@example.com"}).Exec(ctx, tx)
+	})
+	require.IsType(t, &jdb.UniqueError{}, err)
+	require.Equal(t, "by_email", err.(*jdb.UniqueError).Key)
+}
+
+type scopeFolder struct {
+	ID string `jdb:"-id"`
+}
+
+type scopePage struct {
+	ID     string `jdb:"-id"`
+	Tenant string `jdb:"tenant"`
+	Email  string `jdb:"email,unique=by_email,scope=tenant"`
+	Slug   string `jdb:"slug,unique=by_slug,scope=parent"`
+}
+
+func (dt *Test) testIndexScope(t *testing.T) {
+	db := dt.setup(t, false)
+
+	ctx := context.Background()
+	folders := db.Query("folder")
+	pages := db.Query("page")
+
+	insert := func(q *jdb.Query, values ...interface{}) error {
+		return db.Tx(ctx, func(tx *jdb.Tx) error {
+			if err := q.Insert(values...).Exec(ctx, tx); err != nil {
+				return err
+			}
+			return tx.Commit()
+		})
+	}
+
+	require.NoError(t, insert(folders, scopeFolder{ID: "1"}, scopeFolder{ID: "2"}))
+	require.NoError(t, insert(pages.Under("folder", "1"),
+		scopePage{ID: "1", Tenant: "t1", Email: "a@example.com", Slug: "home"},
+		scopePage{ID: "2", Tenant: "t2", Email: "a@example.com", Slug: "about"},
+	))
+	require.NoError(t, insert(pages.Under("folder", "2"),
+		scopePage{ID: "3", Tenant: "t1", Email: "b@example.com", Slug: "home"},
+	))
+
+	err := insert(pages.Under("folder", "1"), scopePage{ID: "4", Tenant: "t3", Email: "c@example.com", Slug: "home"})
+	require.IsType(t, &jdb.UniqueError{}, err)
+	require.Equal(t, "by_slug", err.(*jdb.UniqueError).Key)
+
+	err = insert(pages.Under("folder", "2"), scopePage{ID: "4", Tenant: "t2", Email: "a@example.com", Slug: "faq"})
+	require.IsType(t, &jdb.UniqueError{}, err)
+	require.Equal(t, "by_email", err.(*jdb.UniqueError).Key)
+
+	err = db.Tx(ctx, func(tx *jdb.Tx) error {
+		return pages.Move("3", "folder", "1").Exec(ctx, tx)
+	})
+	require.IsType(t, &jdb.UniqueError{}, err)
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		require.NoError(t, pages.Move("2", "folder", "2").Exec(ctx, tx))
+		require.NoError(t, pages.Under("folder", "2").Update(scopePage{ID: "3", Tenant: "t1",
+			Email: "b@example.com", Slug: "contact"}).Exec(ctx, tx))
+		return tx.Commit()
+	}))
+
+	require.NoError(t, insert(pages.Under("folder", "1"),
+		scopePage{ID: "4", Tenant: "t3", Email: "c@example.com", Slug: "about"},
+	))
+	err = insert(pages.Under("folder", "2"), scopePage{ID: "5", Tenant: "t3", Email: "d@example.com", Slug: "about"})
+	require.IsType(t, &jdb.UniqueError{}, err)
+}
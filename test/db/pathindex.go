@@ -0,0 +1,74 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/silas/jdb"
+	"github.com/stretchr/testify/require"
+)
+
+type pathIndexUser struct {
+	ID    string `jdb:"-id"`
+	Email string `jdb:"email"`
+	Name  string `jdb:"name"`
+}
+
+func (dt *Test) testPathIndex(t *testing.T) {
+	db := dt.setup(t, false)
+
+	ctx := context.Background()
+	users := db.Query("user")
+
+	index, err := db.EnsurePathIndex(ctx, "user", []string{"email"}, jdb.PathIndexOpts{Unique: true})
+	require.NoError(t, err)
+	require.Equal(t, "user", index.Kind)
+	require.Equal(t, []string{"email"}, index.Path)
+	require.True(t, index.Unique)
+
+	again, err := db.EnsurePathIndex(ctx, "user", []string{"email"}, jdb.PathIndexOpts{Unique: true})
+	require.NoError(t, err)
+	require.Equal(t, index, again)
+
+	_, err = db.EnsurePathIndex(ctx, "user", []string{"email"}, jdb.PathIndexOpts{})
+	require.Error(t, err)
+
+	indexes, err := db.PathIndexes(ctx)
+	require.NoError(t, err)
+	require.Equal(t, []jdb.PathIndex{*index}, indexes)
+
+	require.NoError(t, db.Migrate(ctx))
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		err := users.Insert(
+			pathIndexUser{ID: "1", Email: "a@example.com", Name: "A"},
+			pathIndexUser{ID: "2", Email: "b@example.com", Name: "B"},
+		).Exec(ctx, tx)
+		require.NoError(t, err)
+
+		var ids []string
+		err = users.Where(jdb.Eq(db.Path("email"), "b@example.com")).Select(db.ID).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"2"}, ids)
+
+		return tx.Commit()
+	}))
+
+	err = db.Tx(ctx, func(tx *jdb.Tx) error {
+		return users.Insert(pathIndexUser{ID: "3", Email: "a@example.com"}).Exec(ctx, tx)
+	})
+	require.Error(t, err)
+
+	require.NoError(t, db.DropPathIndex(ctx, index.Name))
+	require.Equal(t, jdb.ErrNotFound, db.DropPathIndex(ctx, index.Name))
+
+	indexes, err = db.PathIndexes(ctx)
+	require.NoError(t, err)
+	require.Empty(t, indexes)
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		err := users.Insert(pathIndexUser{ID: "3", Email: "a@example.com"}).Exec(ctx, tx)
+		require.NoError(t, err)
+		return tx.Commit()
+	}))
+}
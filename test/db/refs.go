@@ -0,0 +1,88 @@
+package db
+
+import (
+	"context"
+	"testing"
+
+	"github.com/silas/jdb"
+	"github.com/stretchr/testify/require"
+)
+
+type refProduct struct {
+	ID   string `jdb:"-id"`
+	Name string
+}
+
+type refOrder struct {
+	ID        string      `jdb:"-id"`
+	ProductID string      `jdb:",ref=product"`
+	Product   *refProduct `jdb:"-"`
+}
+
+func (dt *Test) testRefs(t *testing.T) {
+	db := dt.setup(t, false)
+
+	ctx := context.Background()
+	products := db.Query("product")
+	orders := db.Query("order")
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		err := products.Insert(refProduct{ID: "p1", Name: "One"}, refProduct{ID: "p2", Name: "Two"}).Exec(ctx, tx)
+		require.NoError(t, err)
+
+		err = orders.Insert(refOrder{ID: "o1", ProductID: "p1"}, refOrder{ID: "o2", ProductID: "p1"}).
+			CheckRefs().Exec(ctx, tx)
+		require.NoError(t, err)
+
+		err = orders.Insert(refOrder{ID: "o3", ProductID: "p3"}).CheckRefs().Exec(ctx, tx)
+		require.Equal(t, &jdb.RefError{Name: "ProductID", Kind: "product", ID: "p3"}, err)
+
+		err = orders.Insert(refOrder{ID: "o3", ProductID: "p2"}).Exec(ctx, tx)
+		require.NoError(t, err)
+
+		var ids []string
+		err = orders.Where(jdb.RefersTo("product", "p1")).Select(db.ID).OrderBy(db.ID.Asc()).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"o1", "o2"}, ids)
+
+		err = orders.Update(refOrder{ID: "o2", ProductID: "p2"}).CheckRefs().Exec(ctx, tx)
+		require.NoError(t, err)
+
+		err = orders.Where(jdb.RefersTo("product", "p2", "ProductID")).Select(db.ID).OrderBy(db.ID.Asc()).
+			All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"o2", "o3"}, ids)
+
+		err = orders.Where(jdb.RefersTo("product", "p2", "GiftID")).Select(db.ID).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Len(t, ids, 0)
+
+		err = orders.Delete("o3").Exec(ctx, tx)
+		require.NoError(t, err)
+
+		err = orders.Update(refOrder{ID: "o1"}).Exec(ctx, tx)
+		require.NoError(t, err)
+
+		err = orders.Where(jdb.Or(jdb.RefersTo("product", "p1"), jdb.RefersTo("product", "p2"))).
+			Select(db.ID).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"o2"}, ids)
+
+		err = orders.Update(refOrder{ID: "o3", ProductID: "p1"}).Exec(ctx, tx)
+		require.NoError(t, err)
+
+		err = orders.Where(jdb.RefersTo("product", "p1")).Select(db.ID).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Len(t, ids, 0)
+
+		var result []refOrder
+		err = orders.Select().OrderBy(db.ID.Asc()).Preload("Product").All(ctx, tx, &result)
+		require.NoError(t, err)
+		require.Equal(t, []refOrder{
+			{ID: "o1"},
+			{ID: "o2", ProductID: "p2", Product: &refProduct{ID: "p2", Name: "Two"}},
+		}, result)
+
+		return tx.Commit()
+	}))
+}
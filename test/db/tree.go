@@ -0,0 +1,355 @@
+package db
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/silas/jdb"
+	"github.com/stretchr/testify/require"
+)
+
+type treeAccount struct {
+	ID   string `jdb:"-id"`
+	Name string
+}
+
+type treePost struct {
+	ID         string `jdb:"-id"`
+	ParentKind string `jdb:"-parentkind"`
+	ParentID   string `jdb:"-parentid"`
+	Title      string
+}
+
+type treeComment struct {
+	ID         string `jdb:"-id"`
+	ParentKind string `jdb:"-parentkind"`
+	ParentID   string `jdb:"-parentid"`
+	Body       string
+}
+
+type treePostComments struct {
+	ID       string `jdb:"-id"`
+	Title    string
+	Account  *treeAccount  `jdb:",parent"`
+	Comments []treeComment `jdb:",children=comment"`
+}
+
+func (dt *Test) setupTree(t *testing.T) *jdb.Client {
+	db := dt.setup(t, false)
+
+	ctx := context.Background()
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		require.NoError(t, db.Query("account").Insert(treeAccount{ID: "a1", Name: "Account"}).Exec(ctx, tx))
+		require.NoError(t, db.Query("post").Insert(treePost{
+			ID: "p1", ParentKind: "account", ParentID: "a1", Title: "Post 1",
+		}).Exec(ctx, tx))
+		require.NoError(t, db.Query("post").Insert(treePost{
+			ID: "p2", ParentKind: "account", ParentID: "a1", Title: "Post 2",
+		}).Exec(ctx, tx))
+		require.NoError(t, db.Query("comment").Insert(treeComment{
+			ID: "c1", ParentKind: "post", ParentID: "p1", Body: "Comment 1",
+		}).Exec(ctx, tx))
+		require.NoError(t, db.Query("comment").Insert(treeComment{
+			ID: "c2", ParentKind: "comment", ParentID: "c1", Body: "Comment 2",
+		}).Exec(ctx, tx))
+		return tx.Commit()
+	}))
+
+	return db
+}
+
+func (dt *Test) testTree(t *testing.T) {
+	dt.testTreeAncestors(t)
+	dt.testTreeDescendants(t)
+	dt.testTreeUnder(t)
+	dt.testTreeRelations(t)
+	dt.testTreeInclude(t)
+	dt.testTreeMove(t)
+}
+
+func (dt *Test) testTreeAncestors(t *testing.T) {
+	db := dt.setupTree(t)
+
+	ctx := context.Background()
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		var docs []jdb.Document
+		err := db.Query("").Ancestors("comment", "c2").Select().All(ctx, tx, &docs)
+		require.NoError(t, err)
+		require.Len(t, docs, 3)
+		require.Equal(t, "comment", docs[0].Kind)
+		require.Equal(t, "c1", docs[0].ID)
+		require.Equal(t, "post", docs[0].ParentKind)
+		require.Equal(t, "p1", docs[0].ParentID)
+		require.JSONEq(t, `{"Body":"Comment 1"}`, string(docs[0].Data))
+		require.Equal(t, "post", docs[1].Kind)
+		require.Equal(t, "p1", docs[1].ID)
+		require.Equal(t, "account", docs[2].Kind)
+		require.Equal(t, "a1", docs[2].ID)
+		require.Equal(t, "", docs[2].ParentKind)
+
+		var posts []treePost
+		err = db.Query("post").Ancestors("comment", "c2").Select().All(ctx, tx, &posts)
+		require.NoError(t, err)
+		require.Equal(t, []treePost{{ID: "p1", ParentKind: "account", ParentID: "a1", Title: "Post 1"}}, posts)
+
+		var account treeAccount
+		err = db.Query("account").Ancestors("comment", "c1").Select().First(ctx, tx, &account)
+		require.NoError(t, err)
+		require.Equal(t, treeAccount{ID: "a1", Name: "Account"}, account)
+
+		docs = nil
+		err = db.Query("").Ancestors("comment", "c2").Depth(2).Select().All(ctx, tx, &docs)
+		require.NoError(t, err)
+		require.Len(t, docs, 2)
+		require.Equal(t, "c1", docs[0].ID)
+		require.Equal(t, "p1", docs[1].ID)
+
+		docs = nil
+		err = db.Query("").Ancestors("account", "a1").Select().All(ctx, tx, &docs)
+		require.NoError(t, err)
+		require.Len(t, docs, 0)
+
+		return tx.Commit()
+	}))
+}
+
+func (dt *Test) testTreeDescendants(t *testing.T) {
+	db := dt.setupTree(t)
+
+	ctx := context.Background()
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		var docs []jdb.Document
+		err := db.Query("").Descendants("account", "a1").Select().All(ctx, tx, &docs)
+		require.NoError(t, err)
+		require.Len(t, docs, 4)
+		require.Equal(t, "post", docs[0].Kind)
+		require.Equal(t, "post", docs[1].Kind)
+		require.Equal(t, "c1", docs[2].ID)
+		require.Equal(t, "c2", docs[3].ID)
+		require.JSONEq(t, `{"Body":"Comment 2"}`, string(docs[3].Data))
+
+		var comments []treeComment
+		err = db.Query("comment").Descendants("account", "a1").Select().
+			OrderBy(db.ID.Desc()).All(ctx, tx, &comments)
+		require.NoError(t, err)
+		require.Equal(t, []treeComment{
+			{ID: "c2", ParentKind: "comment", ParentID: "c1", Body: "Comment 2"},
+			{ID: "c1", ParentKind: "post", ParentID: "p1", Body: "Comment 1"},
+		}, comments)
+
+		var ids []string
+		err = db.Query("").Descendants("account", "a1").Depth(2).Select(db.ID).
+			OrderBy(db.ID.Asc()).Limit(2).Offset(1).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"p1", "p2"}, ids)
+
+		var posts []treePost
+		err = db.Query("post").Descendants("account", "a1").Where(jdb.Eq(db.Path("Title"), "Post 2")).
+			Select().All(ctx, tx, &posts)
+		require.NoError(t, err)
+		require.Equal(t, []treePost{{ID: "p2", ParentKind: "account", ParentID: "a1", Title: "Post 2"}}, posts)
+
+		var count int
+		err = db.Query("").Descendants("account", "a1").Count().First(ctx, tx, &count)
+		require.NoError(t, err)
+		require.Equal(t, 4, count)
+
+		err = db.Query("").Descendants("account", "a1").Kind("comment").Depth(2).Count().First(ctx, tx, &count)
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+
+		err = db.Query("").Descendants("comment", "c2").Count().First(ctx, tx, &count)
+		require.NoError(t, err)
+		require.Equal(t, 0, count)
+
+		return tx.Commit()
+	}))
+}
+
+func (dt *Test) testTreeUnder(t *testing.T) {
+	db := dt.setupTree(t)
+
+	ctx := context.Background()
+	query := db.Query("post").Under("account", "a1")
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		err := query.Insert(treePost{ID: "p3", Title: "Post 3"}).Exec(ctx, tx)
+		require.NoError(t, err)
+
+		var post treePost
+		err = db.Query("post").Get("p3").Select().First(ctx, tx, &post)
+		require.NoError(t, err)
+		require.Equal(t, treePost{ID: "p3", ParentKind: "account", ParentID: "a1", Title: "Post 3"}, post)
+
+		err = query.Insert(treePost{ID: "p4", ParentKind: "post", ParentID: "p1"}).Exec(ctx, tx)
+		require.EqualError(t, err, "value 0 parent mismatch: account/a1 != post/p1")
+
+		var count int
+		err = query.Count().First(ctx, tx, &count)
+		require.NoError(t, err)
+		require.Equal(t, 3, count)
+
+		err = db.Query("comment").Under("post", "p1").Count().First(ctx, tx, &count)
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+
+		err = query.Update(treePost{ID: "p3", Title: "Post Three"}).Exec(ctx, tx)
+		require.NoError(t, err)
+
+		err = query.Get("p3").Select().First(ctx, tx, &post)
+		require.NoError(t, err)
+		require.Equal(t, treePost{ID: "p3", ParentKind: "account", ParentID: "a1", Title: "Post Three"}, post)
+
+		err = db.Query("post").Under("account", "a2").Delete("p3").Exec(ctx, tx)
+		require.NoError(t, err)
+
+		err = query.Get("p3").Select().First(ctx, tx, &post)
+		require.NoError(t, err)
+
+		err = query.Delete("p3").Exec(ctx, tx)
+		require.NoError(t, err)
+
+		err = query.Get("p3").Select().First(ctx, tx, &post)
+		require.Equal(t, jdb.ErrNotFound, err)
+
+		return tx.Commit()
+	}))
+}
+
+func (dt *Test) testTreeRelations(t *testing.T) {
+	db := dt.setupTree(t)
+
+	ctx := context.Background()
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		var ids []string
+		err := db.Query("post").Where(jdb.HasChild("comment")).Select(db.ID).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"p1"}, ids)
+
+		err = db.Query("post").Where(jdb.Or(
+			jdb.HasChild("comment", jdb.Eq(db.Path("Body"), "Comment 2")),
+			jdb.Eq(db.ID, "p2"),
+		)).Select(db.ID).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"p2"}, ids)
+
+		err = db.Query("account").Where(jdb.HasChild("post", jdb.HasChild("comment"))).
+			Select(db.ID).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"a1"}, ids)
+
+		err = db.Query("comment").Where(jdb.HasParent("post", jdb.Eq(db.Path("Title"), "Post 1"))).
+			Select(db.ID).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"c1"}, ids)
+
+		err = db.Query("comment").Where(jdb.HasParent("comment")).Select(db.ID).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"c2"}, ids)
+
+		var count int
+		err = db.Query("post").Where(jdb.HasParent("account", jdb.HasChild("post", jdb.Eq(db.ID, "p2")))).
+			Count().First(ctx, tx, &count)
+		require.NoError(t, err)
+		require.Equal(t, 2, count)
+
+		return tx.Commit()
+	}))
+}
+
+func (dt *Test) testTreeInclude(t *testing.T) {
+	db := dt.setupTree(t)
+
+	ctx := context.Background()
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		var posts []treePostComments
+		err := db.Query("post").Select().OrderBy(db.ID.Asc()).Include("comment", "parent").All(ctx, tx, &posts)
+		require.NoError(t, err)
+		account := &treeAccount{ID: "a1", Name: "Account"}
+		require.Equal(t, []treePostComments{
+			{
+				ID:       "p1",
+				Title:    "Post 1",
+				Account:  account,
+				Comments: []treeComment{{ID: "c1", ParentKind: "post", ParentID: "p1", Body: "Comment 1"}},
+			},
+			{
+				ID:      "p2",
+				Title:   "Post 2",
+				Account: account,
+			},
+		}, posts)
+
+		var post treePostComments
+		err = db.Query("post").Get("p2").Select().Include("comment").First(ctx, tx, &post)
+		require.NoError(t, err)
+		require.Equal(t, treePostComments{ID: "p2", Title: "Post 2"}, post)
+
+		err = db.Query("post").Update(post).Exec(ctx, tx)
+		require.NoError(t, err)
+
+		var doc jdb.Document
+		err = db.Query("post").Get("p2").Select().First(ctx, tx, &doc)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"Title":"Post 2"}`, string(doc.Data))
+
+		return tx.Commit()
+	}))
+}
+
+func (dt *Test) testTreeMove(t *testing.T) {
+	db := dt.setupTree(t)
+
+	ctx := context.Background()
+	comments := db.Query("comment")
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		require.Equal(t, jdb.ErrCycle, comments.Move("c1", "comment", "c1").Exec(ctx, tx))
+		require.Equal(t, jdb.ErrCycle, comments.Move("c1", "comment", "c2").Exec(ctx, tx))
+
+		require.Equal(t, jdb.ErrNotFound, comments.Move("c1", "post", "nope").Exec(ctx, tx))
+		require.Equal(t, jdb.ErrNotFound, comments.Move("nope", "post", "p2").Exec(ctx, tx))
+		require.Equal(t, jdb.ErrNotFound, comments.Move("nope", "", "").Exec(ctx, tx))
+
+		require.NoError(t, comments.Move("c2", "post", "p2").Exec(ctx, tx))
+		require.NoError(t, comments.Move("c2", "post", "p2").Exec(ctx, tx))
+
+		var comment treeComment
+		err := comments.Get("c2").Select().First(ctx, tx, &comment)
+		require.NoError(t, err)
+		require.Equal(t, treeComment{ID: "c2", ParentKind: "post", ParentID: "p2", Body: "Comment 2"}, comment)
+
+		require.NoError(t, comments.Move("c1", "comment", "c2").Exec(ctx, tx))
+
+		var ids []string
+		err = db.Query("").Ancestors("comment", "c1").Select(db.ID).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"c2", "p2", "a1"}, ids)
+
+		require.NoError(t, comments.Move("c2", "", "").Exec(ctx, tx))
+
+		err = db.Query("").Ancestors("comment", "c1").Select(db.ID).All(ctx, tx, &ids)
+		require.NoError(t, err)
+		require.Equal(t, []string{"c2"}, ids)
+
+		deep := make([]interface{}, 150)
+		for i := range deep {
+			comment := treeComment{ID: "d" + strconv.Itoa(i)}
+			if i > 0 {
+				comment.ParentKind = "comment"
+				comment.ParentID = "d" + strconv.Itoa(i-1)
+			}
+			deep[i] = comment
+		}
+		require.NoError(t, comments.Insert(deep...).Exec(ctx, tx))
+		require.Equal(t, jdb.ErrCycle, comments.Move("d0", "comment", "d149").Exec(ctx, tx))
+
+		return tx.Commit()
+	}))
+}
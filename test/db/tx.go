@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"database/sql"
 	"testing"
 	"time"
 
@@ -21,4 +22,85 @@ func (dt *Test) testClient(t *testing.T) {
 
 		return tx.Commit()
 	}))
+
+	err := db.TxWith(ctx, jdb.TxOptions{ReadOnly: true, Label: "read"}, func(tx *jdb.Tx) error {
+		require.Equal(t, "read", tx.Label())
+		return db.Query("user").Insert(struct {
+			ID string `jdb:"-id"`
+		}{"1"}).Exec(ctx, tx)
+	})
+	require.Equal(t, jdb.ErrReadOnlyMode, err)
+
+	require.NoError(t, db.TxWith(ctx, jdb.TxOptions{Isolation: sql.LevelSerializable, Timeout: time.Minute},
+		func(tx *jdb.Tx) error {
+			_, err := tx.Now(ctx)
+			return err
+		}))
+
+	if dt.driverName == "sqlite3" {
+		// the timeout covers the whole transaction
+		err := db.TxWith(ctx, jdb.TxOptions{Timeout: 10 * time.Millisecond}, func(tx *jdb.Tx) error {
+			time.Sleep(50 * time.Millisecond)
+			_, err := tx.Now(ctx)
+			return err
+		})
+		require.Equal(t, jdb.ErrTxTimeout, err)
+	}
+
+	users := db.Query("user")
+	require.NoError(t, db.Update(ctx, func(tx *jdb.Tx) error {
+		return users.Insert(nestedUser{ID: "1", Email: "a@example.com"}).Exec(ctx, tx)
+	}))
+	require.NoError(t, db.View(ctx, func(tx *jdb.Tx) error {
+		var user nestedUser
+		require.NoError(t, users.Get("1").Select().First(ctx, tx, &user))
+		require.Equal(t, "a@example.com", user.Email)
+
+		err := users.Get("1").Delete().Exec(ctx, tx)
+		require.Equal(t, jdb.ErrReadOnlyMode, err)
+		return nil
+	}))
+
+	require.NoError(t, users.Insert(nestedUser{ID: "2", Email: "b@example.com"}).Exec(ctx, db))
+	var user nestedUser
+	require.NoError(t, users.Get("2").Select().First(ctx, db, &user))
+	require.Equal(t, "b@example.com", user.Email)
+	require.NoError(t, users.Delete("2").Exec(ctx, db))
+	require.Equal(t, jdb.ErrNotFound, users.Get("2").Select().First(ctx, db, &user))
+}
+
+type nestedUser struct {
+	ID    string `jdb:"-id"`
+	Email string `jdb:"email,unique=by_email"`
+}
+
+func (dt *Test) testNested(t *testing.T) {
+	db := dt.setup(t, false)
+
+	ctx := context.Background()
+	users := db.Query("user")
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		require.NoError(t, users.Insert(nestedUser{ID: "1", Email: "a@example.com"}).Exec(ctx, tx))
+
+		err := tx.Nested(ctx, func(tx *jdb.Tx) error {
+			if err := users.Insert(nestedUser{ID: "2", Email: "b@example.com"}).Exec(ctx, tx); err != nil {
+				return err
+			}
+			return users.Insert(nestedUser{ID: "3", Email: "a@example.com"}).Exec(ctx, tx)
+		})
+		_, ok := err.(*jdb.UniqueError)
+		require.True(t, ok, "%v", err)
+
+		require.NoError(t, users.Insert(nestedUser{ID: "4", Email: "c@example.com"}).Exec(ctx, tx))
+
+		return tx.Commit()
+	}))
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		var all []nestedUser
+		require.NoError(t, users.Select().OrderBy(db.ID.Asc()).All(ctx, tx, &all))
+		require.Equal(t, []nestedUser{{"1", "a@example.com"}, {"4", "c@example.com"}}, all)
+		return nil
+	}))
 }
@@ -0,0 +1,90 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/silas/jdb"
+	"github.com/stretchr/testify/require"
+)
+
+type upgradeUser struct {
+	ID       string `jdb:"-id"`
+	FullName string `jdb:"full_name,stringkey"`
+	Version  int    `jdb:"-schemaversion"`
+}
+
+func upgradeName(data []byte) ([]byte, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields["full_name"] = fields["name"]
+	delete(fields, "name")
+	return json.Marshal(fields)
+}
+
+func (dt *Test) testUpgrade(t *testing.T) {
+	db := dt.setup(t, false)
+
+	ctx := context.Background()
+	before := time.Now().UTC().Add(-time.Hour).Truncate(time.Second)
+
+	for _, kind := range []string{"upgrade_doc", "upgrade_user"} {
+		require.NoError(t, db.RegisterUpgrade(kind, 1, upgradeName))
+		for _, id := range []string{"1", "2", "3"} {
+			dt.insertRaw(t, kind, id, nil, nil, nil, nil, nil, nil, `{"name":"User `+id+`"}`, before, before)
+		}
+	}
+	require.NoError(t, db.Register(upgradeUser{}, jdb.KindName("upgrade_user")))
+
+	users := db.Query("upgrade_user")
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		var user upgradeUser
+		require.NoError(t, users.Get("1").Select().First(ctx, tx, &user))
+		require.Equal(t, upgradeUser{ID: "1", FullName: "User 1"}, user)
+
+		user.FullName = "Alice"
+		require.NoError(t, users.Update(user).Exec(ctx, tx))
+
+		require.NoError(t, users.Get("1").Select().First(ctx, tx, &user))
+		require.Equal(t, upgradeUser{ID: "1", FullName: "Alice", Version: 1}, user)
+
+		return tx.Commit()
+	}))
+
+	count, err := db.UpgradeKind(ctx, "upgrade_user")
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	count, err = db.UpgradeKind(ctx, "upgrade_doc")
+	require.NoError(t, err)
+	require.Equal(t, 3, count)
+
+	count, err = db.UpgradeKind(ctx, "upgrade_doc")
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
+
+	require.NoError(t, db.Tx(ctx, func(tx *jdb.Tx) error {
+		var all []upgradeUser
+		require.NoError(t, users.Where(jdb.Eq(db.StringKey, "User 2")).Select().All(ctx, tx, &all))
+		require.Equal(t, []upgradeUser{{ID: "2", FullName: "User 2", Version: 1}}, all)
+
+		var docs []jdb.Document
+		require.NoError(t, db.Query("upgrade_doc").Select().OrderBy(db.ID.Asc()).All(ctx, tx, &docs))
+		require.Len(t, docs, 3)
+		for _, doc := range docs {
+			require.Equal(t, 1, doc.SchemaVersion)
+			require.JSONEq(t, `{"full_name":"User `+doc.ID+`"}`, string(doc.Data))
+			require.True(t, doc.UpdateTime.After(before), doc.UpdateTime)
+		}
+
+		return nil
+	}))
+
+	_, err = db.UpgradeKind(ctx, "user")
+	require.EqualError(t, err, "jdb: upgrades not registered: user")
+}
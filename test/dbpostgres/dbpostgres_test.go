@@ -23,8 +23,12 @@ func TestPostgres(t *testing.T) {
 	require.NoError(t, err)
 	defer c.Close()
 
-	_, err = c.Exec("DROP TABLE IF EXISTS " + table)
-	require.NoError(t, err)
+	tables := []string{table + "_refs", table + "_keys", table + "_attachments", table + "_blob_chunks",
+		table + "_blobs", table}
+	for _, name := range tables {
+		_, err = c.Exec("DROP TABLE IF EXISTS " + name)
+		require.NoError(t, err)
+	}
 	c.Close()
 
 	db.New(driverName, dataSourceName, dataSourceName, table).Run(t)
@@ -0,0 +1,296 @@
+package jdb
+
+import (
+	"context"
+	"strconv"
+)
+
+// maxTreeDepth bounds recursive queries when no depth is given so that a
+// cycle in parent links can't recurse forever.
+const maxTreeDepth = 100
+
+var treeDepthField = WhereColumn{"tree_depth"}
+
+type tree struct {
+	ancestors bool
+	kind      string
+	id        string
+	depth     uint64
+}
+
+func (t *tree) name(table string) string {
+	return table + "_tree"
+}
+
+func (t *tree) toWithSQL(w *SQLWriter, table string) {
+	depth := t.depth
+	if depth == 0 {
+		depth = maxTreeDepth
+	}
+
+	name := t.name(table)
+
+	w.WriteString("WITH RECURSIVE ")
+	w.WriteString(name)
+	w.WriteString(" (tree_kind, tree_id, tree_depth) AS (")
+	if t.ancestors {
+		w.WriteString("SELECT parent_kind, parent_id, 1 FROM ")
+		w.WriteString(table)
+		w.WriteString(" WHERE kind = ")
+		w.WriteParam(t.kind)
+		w.WriteString(" AND id = ")
+		w.WriteParam(t.id)
+		w.WriteString(" AND parent_kind IS NOT NULL AND parent_id IS NOT NULL UNION ALL ")
+		w.WriteString("SELECT t.parent_kind, t.parent_id, r.tree_depth + 1 FROM ")
+		w.WriteString(table)
+		w.WriteString(" t INNER JOIN ")
+		w.WriteString(name)
+		w.WriteString(" r ON t.kind = r.tree_kind AND t.id = r.tree_id")
+		w.WriteString(" WHERE t.parent_kind IS NOT NULL AND t.parent_id IS NOT NULL AND r.tree_depth < ")
+	} else {
+		w.WriteString("SELECT kind, id, 1 FROM ")
+		w.WriteString(table)
+		w.WriteString(" WHERE parent_kind = ")
+		w.WriteParam(t.kind)
+		w.WriteString(" AND parent_id = ")
+		w.WriteParam(t.id)
+		w.WriteString(" UNION ALL ")
+		w.WriteString("SELECT t.kind, t.id, r.tree_depth + 1 FROM ")
+		w.WriteString(table)
+		w.WriteString(" t INNER JOIN ")
+		w.WriteString(name)
+		w.WriteString(" r ON t.parent_kind = r.tree_kind AND t.parent_id = r.tree_id")
+		w.WriteString(" WHERE r.tree_depth < ")
+	}
+	w.WriteString(strconv.FormatUint(depth, 10))
+	w.WriteString(") ")
+}
+
+func (t *tree) toJoinSQL(w *SQLWriter, table string) {
+	name := t.name(table)
+
+	w.WriteString("INNER JOIN ")
+	w.WriteString(name)
+	w.WriteString(" ON ")
+	w.WriteString(table)
+	w.WriteString(".kind = ")
+	w.WriteString(name)
+	w.WriteString(".tree_kind AND ")
+	w.WriteString(table)
+	w.WriteString(".id = ")
+	w.WriteString(name)
+	w.WriteString(".tree_id ")
+}
+
+type TreeBuilder struct {
+	q *Query
+	t tree
+
+	kinds []string
+	where and
+}
+
+func newTreeBuilder(q *Query, t tree) *TreeBuilder {
+	b := &TreeBuilder{q: q, t: t}
+	if q.kind != "" {
+		b.kinds = []string{q.kind}
+	}
+	return b
+}
+
+// Ancestors returns the documents above kind/id, nearest first. Results are
+// limited to the query's kind unless it is empty or changed using Kind.
+func (q *Query) Ancestors(kind, id string) *TreeBuilder {
+	return newTreeBuilder(q, tree{ancestors: true, kind: kind, id: id})
+}
+
+// Descendants returns the documents below parentKind/parentID, nearest
+// first. Results are limited to the query's kind unless it is empty or changed
+// using Kind.
+func (q *Query) Descendants(parentKind, parentID string) *TreeBuilder {
+	return newTreeBuilder(q, tree{kind: parentKind, id: parentID})
+}
+
+// Depth limits how many levels are traversed, zero uses the default limit.
+func (b *TreeBuilder) Depth(depth uint64) *TreeBuilder {
+	n := *b
+	n.t.depth = depth
+	return &n
+}
+
+// Kind limits results to the given kinds, no kinds returns all kinds.
+func (b *TreeBuilder) Kind(kinds ...string) *TreeBuilder {
+	n := *b
+	n.kinds = kinds
+	return &n
+}
+
+func (b *TreeBuilder) Where(where ...Condition) *TreeBuilder {
+	if len(where) == 0 {
+		return b
+	}
+	n := *b
+	n.where = make(and, len(b.where)+len(where))
+	start := copy(n.where, b.where)
+	copy(n.where[start:], where)
+	return &n
+}
+
+func (b *TreeBuilder) whereBuilder() *WhereBuilder {
+	wb := &WhereBuilder{q: b.q, tree: &b.t}
+	if len(b.kinds) > 0 {
+		kinds := make([]interface{}, len(b.kinds))
+		for i, kind := range b.kinds {
+			kinds[i] = kind
+		}
+		wb = wb.Where(In(kindField, kinds...))
+	}
+	return wb.Where(b.where...)
+}
+
+func (b *TreeBuilder) Select(columns ...SelectField) *SelectBuilder {
+	if len(columns) == 0 {
+		columns = defaultSelectColumns
+	}
+	sb := newSelectBuilder(b.q, b.whereBuilder(), columns)
+	sb.defaultOrder = []Order{treeDepthField.Asc()}
+	return sb
+}
+
+func (b *TreeBuilder) Count() *SelectBuilder {
+	return newSelectBuilder(b.q, b.whereBuilder(), []SelectField{selectCount{}})
+}
+
+type MoveBuilder struct {
+	q  *Query
+	wb *WhereBuilder
+
+	id         string
+	parentKind string
+	parentID   string
+}
+
+// Move sets the parent of id, returning ErrCycle when the new parent is the
+// document itself or one of its descendants, and ErrNotFound when the
+// document or the new parent doesn't exist. An empty parent moves the
+// document to the root.
+//
+// The cycle check reads the ancestors of the new parent before the update,
+// so concurrent moves can only be prevented from creating a cycle together
+// when run in serializable transactions, the Client.Tx default.
+func (q *Query) Move(id, parentKind, parentID string) *MoveBuilder {
+	return &MoveBuilder{q: q, wb: q.get(id), id: id, parentKind: parentKind, parentID: parentID}
+}
+
+func (b *MoveBuilder) ToSQL() (string, []interface{}, error) {
+	w := newSQLWriter(b.q.d, b.q.table)
+
+	var parentKind, parentID *string
+	if b.parentKind != "" || b.parentID != "" {
+		parentKind = &b.parentKind
+		parentID = &b.parentID
+	}
+
+	w.WriteString("UPDATE ")
+	w.WriteString(b.q.table)
+	w.WriteString(" SET parent_kind = ?, parent_id = ?, update_time = ")
+	w.WriteString(b.q.d.TimestampExpression())
+	w.WriteString(" ")
+	w.AddParams(parentKind, parentID)
+
+	if err := b.wb.toWhereSQL(w); err != nil {
+		return "", nil, err
+	}
+
+	query, params := w.toSQL()
+	return query, params, nil
+}
+
+// cycleSQL counts the ancestors of the new parent that are the moved
+// document. It isn't limited by depth, UNION drops repeated ancestors so the
+// recursion ends even if the stored links already have a cycle.
+func (b *MoveBuilder) cycleSQL() *SQLWriter {
+	table := b.q.table
+	name := table + "_cycle"
+
+	w := newSQLWriter(b.q.d, table)
+	w.WriteString("WITH RECURSIVE " + name + " (tree_kind, tree_id) AS (")
+	w.WriteString("SELECT parent_kind, parent_id FROM " + table + " WHERE kind = ")
+	w.WriteParam(b.parentKind)
+	w.WriteString(" AND id = ")
+	w.WriteParam(b.parentID)
+	w.WriteString(" AND parent_kind IS NOT NULL AND parent_id IS NOT NULL UNION ")
+	w.WriteString("SELECT t.parent_kind, t.parent_id FROM " + table + " t INNER JOIN " + name + " r")
+	w.WriteString(" ON t.kind = r.tree_kind AND t.id = r.tree_id")
+	w.WriteString(" WHERE t.parent_kind IS NOT NULL AND t.parent_id IS NOT NULL) ")
+	w.WriteString("SELECT count(*) FROM " + name + " WHERE tree_kind = ")
+	w.WriteParam(b.q.kind)
+	w.WriteString(" AND tree_id = ")
+	w.WriteParam(b.id)
+	return w
+}
+
+func (b *MoveBuilder) Exec(ctx context.Context, e Executor) error {
+	return e.run(ctx, writeMode, func(tx *Tx) error {
+		return b.exec(ctx, tx)
+	})
+}
+
+func (b *MoveBuilder) exec(ctx context.Context, tx *Tx) error {
+	if b.parentKind != "" || b.parentID != "" {
+		if b.parentKind == b.q.kind && b.parentID == b.id {
+			return ErrCycle
+		}
+
+		found, err := exists(ctx, tx, b.q.subQuery(b.parentKind), b.parentID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrNotFound
+		}
+
+		var count int
+		if err := tx.scanRow(ctx, b.cycleSQL(), &count); err != nil {
+			return tx.c.d.ErrorMap(err)
+		}
+		if count > 0 {
+			return ErrCycle
+		}
+	}
+
+	result, err := tx.exec(ctx, b)
+	if err != nil {
+		return err
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		// mysql doesn't count rows the update left unchanged
+		found, err := exists(ctx, tx, b.q, b.id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrNotFound
+		}
+	}
+
+	r := &row{}
+	if b.parentKind != "" || b.parentID != "" {
+		r.ParentKind = &b.parentKind
+		r.ParentID = &b.parentID
+	}
+	return moveKeys(ctx, tx, b.q, b.id, r)
+}
+
+// exists reports whether the document id of the kind of q exists.
+func exists(ctx context.Context, tx *Tx, q *Query, id string) (bool, error) {
+	var count int
+	if err := q.Get(id).Count().First(ctx, tx, &count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
@@ -0,0 +1,228 @@
+package jdb
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/silas/jdb/internal/ptr"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestTreeBuilder_Ancestors(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	columns := "kind, id, parent_kind, parent_id, data, create_time, update_time"
+	with := "WITH RECURSIVE jdb_tree (tree_kind, tree_id, tree_depth) AS (" +
+		"SELECT parent_kind, parent_id, 1 FROM jdb WHERE kind = ? AND id = ? AND " +
+		"parent_kind IS NOT NULL AND parent_id IS NOT NULL UNION ALL " +
+		"SELECT t.parent_kind, t.parent_id, r.tree_depth + 1 FROM jdb t INNER JOIN jdb_tree r ON " +
+		"t.kind = r.tree_kind AND t.id = r.tree_id WHERE t.parent_kind IS NOT NULL AND " +
+		"t.parent_id IS NOT NULL AND r.tree_depth < %d) "
+	join := "INNER JOIN jdb_tree ON jdb.kind = jdb_tree.tree_kind AND jdb.id = jdb_tree.tree_id"
+
+	tests := []struct {
+		Builder QueryBuilder
+		Query   string
+		Params  []interface{}
+	}{
+		{
+			c.Query("").Ancestors("comment", "1").Select(),
+			fmt.Sprintf(with, maxTreeDepth) +
+				fmt.Sprintf("SELECT %s FROM jdb %s WHERE (1 = 1) ORDER BY tree_depth ASC", columns, join),
+			params("comment", "1"),
+		},
+		{
+			c.Query("account").Ancestors("comment", "1").Select(c.ID),
+			fmt.Sprintf(with, maxTreeDepth) +
+				fmt.Sprintf("SELECT id FROM jdb %s WHERE ((kind IN (?))) ORDER BY tree_depth ASC", join),
+			params("comment", "1", "account"),
+		},
+		{
+			c.Query("account").Ancestors("comment", "1").Kind("post", "account").Depth(2).Select(c.ID).
+				OrderBy(c.CreateTime.Desc()).Limit(1),
+			fmt.Sprintf(with, 2) + fmt.Sprintf("SELECT id FROM jdb %s WHERE ((kind IN (?, ?))) "+
+				"ORDER BY create_time DESC LIMIT 1", join),
+			params("comment", "1", "post", "account"),
+		},
+		{
+			c.Query("account").Ancestors("comment", "1").Kind().Select(c.ID),
+			fmt.Sprintf(with, maxTreeDepth) +
+				fmt.Sprintf("SELECT id FROM jdb %s WHERE (1 = 1) ORDER BY tree_depth ASC", join),
+			params("comment", "1"),
+		},
+	}
+
+	for i, test := range tests {
+		msg := fmt.Sprintf("Test: %d", i)
+
+		s, p, err := test.Builder.ToSQL()
+		require.NoError(t, err, msg)
+		require.Equal(t, test.Query, s, msg)
+		require.Equal(t, test.Params, p, msg)
+	}
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTreeBuilder_Depth(t *testing.T) {
+	b := setupQuery(t).Ancestors("comment", "1")
+	require.Zero(t, b.t.depth)
+
+	b2 := b.Depth(3)
+	require.Zero(t, b.t.depth)
+	require.Equal(t, uint64(3), b2.t.depth)
+}
+
+func TestTreeBuilder_Kind(t *testing.T) {
+	b := setupQuery(t).Ancestors("comment", "1")
+	require.Equal(t, []string{"kind"}, b.kinds)
+
+	b2 := b.Kind("a", "b")
+	require.Equal(t, []string{"kind"}, b.kinds)
+	require.Equal(t, []string{"a", "b"}, b2.kinds)
+}
+
+func TestTreeBuilder_Descendants(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	columns := "kind, id, parent_kind, parent_id, data, create_time, update_time"
+	with := "WITH RECURSIVE jdb_tree (tree_kind, tree_id, tree_depth) AS (" +
+		"SELECT kind, id, 1 FROM jdb WHERE parent_kind = ? AND parent_id = ? UNION ALL " +
+		"SELECT t.kind, t.id, r.tree_depth + 1 FROM jdb t INNER JOIN jdb_tree r ON " +
+		"t.parent_kind = r.tree_kind AND t.parent_id = r.tree_id WHERE r.tree_depth < %d) "
+	join := "INNER JOIN jdb_tree ON jdb.kind = jdb_tree.tree_kind AND jdb.id = jdb_tree.tree_id"
+
+	tests := []struct {
+		Builder QueryBuilder
+		Query   string
+		Params  []interface{}
+	}{
+		{
+			c.Query("").Descendants("account", "1").Select(),
+			fmt.Sprintf(with, maxTreeDepth) +
+				fmt.Sprintf("SELECT %s FROM jdb %s WHERE (1 = 1) ORDER BY tree_depth ASC", columns, join),
+			params("account", "1"),
+		},
+		{
+			c.Query("comment").Descendants("account", "1").Depth(3).Where(Eq(c.Path("Spam"), true)).
+				Where(Gt(c.CreateTime, 1)).Select(c.ID).OrderBy(c.CreateTime.Desc()).Limit(10).Offset(20),
+			fmt.Sprintf(with, 3) + fmt.Sprintf("SELECT id FROM jdb %s WHERE ((kind IN (?)) AND "+
+				"(data->'$.Spam' = ?) AND (create_time > ?)) ORDER BY create_time DESC LIMIT 10 OFFSET 20", join),
+			params("account", "1", "comment", true, 1),
+		},
+		{
+			c.Query("").Descendants("account", "1").Kind("post", "comment").Count(),
+			fmt.Sprintf(with, maxTreeDepth) +
+				fmt.Sprintf("SELECT count(*) AS count FROM jdb %s WHERE ((kind IN (?, ?)))", join),
+			params("account", "1", "post", "comment"),
+		},
+	}
+
+	for i, test := range tests {
+		msg := fmt.Sprintf("Test: %d", i)
+
+		s, p, err := test.Builder.ToSQL()
+		require.NoError(t, err, msg)
+		require.Equal(t, test.Query, s, msg)
+		require.Equal(t, test.Params, p, msg)
+	}
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTreeBuilder_Where(t *testing.T) {
+	b := setupQuery(t).Descendants("account", "1")
+
+	b2 := b.Where()
+	require.Equal(t, b, b2)
+
+	b3 := b.Where(True())
+	require.Len(t, b.where, 0)
+	require.Len(t, b3.where, 1)
+
+	b4 := b3.Where(False())
+	require.Len(t, b3.where, 1)
+	require.Len(t, b4.where, 2)
+}
+
+func TestMoveBuilder_ToSQL(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	s, p, err := c.Query("folder").Move("1", "folder", "2").ToSQL()
+	require.NoError(t, err)
+	require.Equal(t, "UPDATE jdb SET parent_kind = ?, parent_id = ?, update_time = CURRENT_TIMESTAMP "+
+		"WHERE ((kind = ?) AND (id = ?))", s)
+	require.Equal(t, params(ptr.String("folder"), ptr.String("2"), "folder", "1"), p)
+
+	_, p, err = c.Query("folder").Move("1", "", "").ToSQL()
+	require.NoError(t, err)
+	require.Equal(t, params((*string)(nil), (*string)(nil), "folder", "1"), p)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestMoveBuilder_Exec(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	countSQL := regexp.QuoteMeta("SELECT count(*) AS count FROM jdb WHERE ((kind = ?) AND (id = ?))")
+	count := func(n int) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"count"}).AddRow(n)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(countSQL).WithArgs("folder", "2").WillReturnRows(count(1))
+	mock.ExpectQuery(regexp.QuoteMeta("WITH RECURSIVE jdb_cycle (tree_kind, tree_id) AS ("+
+		"SELECT parent_kind, parent_id FROM jdb WHERE kind = ? AND id = ? "+
+		"AND parent_kind IS NOT NULL AND parent_id IS NOT NULL UNION "+
+		"SELECT t.parent_kind, t.parent_id FROM jdb t INNER JOIN jdb_cycle r "+
+		"ON t.kind = r.tree_kind AND t.id = r.tree_id WHERE t.parent_kind IS NOT NULL AND t.parent_id IS NOT NULL) "+
+		"SELECT count(*) FROM jdb_cycle WHERE tree_kind = ? AND tree_id = ?")).
+		WithArgs("folder", "2", "folder", "1").
+		WillReturnRows(count(1))
+	mock.ExpectQuery(countSQL).WithArgs("folder", "3").WillReturnRows(count(1))
+	mock.ExpectQuery(`WITH RECURSIVE jdb_cycle .*`).
+		WithArgs("folder", "3", "folder", "1").
+		WillReturnRows(count(0))
+	mock.ExpectExec(`UPDATE jdb SET parent_kind = \?, parent_id = \?`).
+		WithArgs(ptr.String("folder"), ptr.String("3"), "folder", "1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE jdb_keys SET scope_value = \? WHERE kind = \? AND id = \? AND scope = \?`).
+		WithArgs("folder/3", "folder", "1", "parent").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`UPDATE jdb SET parent_kind = \?, parent_id = \?`).
+		WithArgs(nil, nil, "folder", "1").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE jdb_keys SET scope_value = \?`).
+		WithArgs("", "folder", "1", "parent").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(countSQL).WithArgs("folder", "4").WillReturnRows(count(0))
+	mock.ExpectExec(`UPDATE jdb SET parent_kind = \?, parent_id = \?`).
+		WithArgs(nil, nil, "folder", "5").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(countSQL).WithArgs("folder", "5").WillReturnRows(count(0))
+	mock.ExpectCommit()
+
+	ctx := context.Background()
+
+	require.NoError(t, c.Tx(ctx, func(tx *Tx) error {
+		q := c.Query("folder")
+
+		require.Equal(t, ErrCycle, q.Move("1", "folder", "1").Exec(ctx, tx))
+		require.Equal(t, ErrCycle, q.Move("1", "folder", "2").Exec(ctx, tx))
+		require.NoError(t, q.Move("1", "folder", "3").Exec(ctx, tx))
+		require.NoError(t, q.Move("1", "", "").Exec(ctx, tx))
+		require.Equal(t, ErrNotFound, q.Move("1", "folder", "4").Exec(ctx, tx))
+		require.Equal(t, ErrNotFound, q.Move("5", "", "").Exec(ctx, tx))
+
+		return tx.Commit()
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -3,20 +3,48 @@ package jdb
 import (
 	"context"
 	"database/sql"
+	"fmt"
 	"time"
 )
 
 type Tx struct {
-	c  *Client
-	tx *sql.Tx
+	c     *Client
+	tx    sqlExecutor
+	sqlTx *sql.Tx
+
+	readOnly  bool
+	label     string
+	depth     int
+	committed bool
+}
+
+// Label returns the label of the transaction set with TxWith.
+func (t *Tx) Label() string {
+	return t.label
 }
 
 func (t *Tx) Commit() error {
-	return t.tx.Commit()
+	if t.depth > 0 {
+		return ErrNestedCommit
+	}
+	if t.committed {
+		return ErrTxCommitted
+	}
+	if t.sqlTx == nil {
+		return ErrNoTx
+	}
+	if err := t.sqlTx.Commit(); err != nil {
+		return t.c.d.ErrorMap(err)
+	}
+	t.committed = true
+	return nil
 }
 
 func (t *Tx) exec(ctx context.Context, builder QueryBuilder) (sql.Result, error) {
-	if t.c.readOnly {
+	if t.committed {
+		return nil, ErrTxCommitted
+	}
+	if t.readOnly {
 		return nil, ErrReadOnlyMode
 	}
 
@@ -33,6 +61,9 @@ func (t *Tx) exec(ctx context.Context, builder QueryBuilder) (sql.Result, error)
 }
 
 func (t *Tx) query(ctx context.Context, builder *SelectBuilder) (*Rows, error) {
+	if t.committed {
+		return nil, ErrTxCommitted
+	}
 	query, params, err := builder.ToSQL()
 	if err != nil {
 		return nil, err
@@ -42,13 +73,65 @@ func (t *Tx) query(ctx context.Context, builder *SelectBuilder) (*Rows, error) {
 	if err != nil {
 		return nil, t.c.d.ErrorMap(err)
 	}
-	return newRows(rows, builder.columns), nil
+	rs := newRows(rows, builder.columns)
+	rs.ctx = ctx
+	rs.tx = t
+	rs.kind = builder.q.kind
+	rs.kinds = builder.q.kinds
+	return rs, nil
 }
 
 func (t *Tx) Now(ctx context.Context) (time.Time, error) {
-	now, err := t.c.d.Now(ctx, t.tx)
+	if t.committed {
+		return time.Time{}, ErrTxCommitted
+	}
+	if t.sqlTx == nil {
+		var now time.Time
+		err := t.c.View(ctx, func(tx *Tx) error {
+			var err error
+			now, err = tx.Now(ctx)
+			return err
+		})
+		return now, err
+	}
+
+	now, err := t.c.d.Now(ctx, t.sqlTx)
 	if err != nil {
 		err = t.c.d.ErrorMap(err)
 	}
 	return now, err
 }
+
+// Nested runs fn in a savepoint of the transaction, so when fn returns an
+// error only the statements run by fn are rolled back and the transaction can
+// still be used.
+func (t *Tx) Nested(ctx context.Context, fn func(*Tx) error) error {
+	if t.committed {
+		return ErrTxCommitted
+	}
+	if t.sqlTx == nil {
+		return ErrNoTx
+	}
+
+	nt := &Tx{c: t.c, tx: t.tx, sqlTx: t.sqlTx, readOnly: t.readOnly, label: t.label, depth: t.depth + 1}
+	savepoint := fmt.Sprintf("jdb_savepoint_%d", nt.depth)
+
+	if _, err := t.tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		return t.c.d.ErrorMap(err)
+	}
+
+	if err := fn(nt); err != nil {
+		if _, rerr := t.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint); rerr != nil {
+			return t.c.d.ErrorMap(rerr)
+		}
+		if _, rerr := t.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint); rerr != nil {
+			return t.c.d.ErrorMap(rerr)
+		}
+		return err
+	}
+
+	if _, err := t.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint); err != nil {
+		return t.c.d.ErrorMap(err)
+	}
+	return nil
+}
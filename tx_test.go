@@ -1,7 +1,9 @@
 package jdb
 
 import (
+	"bytes"
 	"context"
+	"database/sql"
 	"testing"
 	"time"
 
@@ -30,3 +32,155 @@ func TestTx_Now(t *testing.T) {
 		return nil
 	}))
 }
+
+func TestClient_TxWith(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SET LOCAL statement_timeout = 1500`).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	opts := TxOptions{
+		Isolation: sql.LevelReadCommitted,
+		ReadOnly:  true,
+		Timeout:   1500 * time.Millisecond,
+		Label:     "report",
+	}
+	require.NoError(t, c.TxWith(ctx, opts, func(tx *Tx) error {
+		require.Equal(t, "report", tx.Label())
+
+		err := c.Query("kind").Delete("1").Exec(ctx, tx)
+		require.Equal(t, ErrReadOnlyMode, err)
+
+		return nil
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTx_Nested(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`SAVEPOINT jdb_savepoint_1`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SAVEPOINT jdb_savepoint_2`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT jdb_savepoint_2`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`RELEASE SAVEPOINT jdb_savepoint_2`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`ROLLBACK TO SAVEPOINT jdb_savepoint_1`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`RELEASE SAVEPOINT jdb_savepoint_1`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`SAVEPOINT jdb_savepoint_1`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(`RELEASE SAVEPOINT jdb_savepoint_1`).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	require.NoError(t, c.Tx(ctx, func(tx *Tx) error {
+		err := tx.Nested(ctx, func(tx *Tx) error {
+			err := tx.Nested(ctx, func(tx *Tx) error {
+				return ErrNotFound
+			})
+			require.Equal(t, ErrNotFound, err)
+
+			return tx.Commit()
+		})
+		require.Equal(t, ErrNestedCommit, err)
+
+		require.NoError(t, tx.Nested(ctx, func(tx *Tx) error {
+			return nil
+		}))
+
+		return tx.Commit()
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestTx_Committed(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	require.NoError(t, c.Tx(ctx, func(tx *Tx) error {
+		require.NoError(t, tx.Commit())
+		require.Equal(t, ErrTxCommitted, tx.Commit())
+
+		_, err := tx.Now(ctx)
+		require.Equal(t, ErrTxCommitted, err)
+
+		err = c.Query("kind").Get("1").Select().First(ctx, tx, &Document{})
+		require.Equal(t, ErrTxCommitted, err)
+
+		err = c.Query("kind").Insert(Document{ID: "1"}).Exec(ctx, tx)
+		require.Equal(t, ErrTxCommitted, err)
+
+		err = tx.Nested(ctx, func(*Tx) error { return nil })
+		require.Equal(t, ErrTxCommitted, err)
+
+		_, err = tx.GetAttachment(ctx, "kind", "1", "a", &bytes.Buffer{})
+		require.Equal(t, ErrTxCommitted, err)
+
+		_, err = tx.ListAttachments(ctx, "kind", "1")
+		require.Equal(t, ErrTxCommitted, err)
+
+		return nil
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClient_Update(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO jdb").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	require.NoError(t, c.Update(ctx, func(tx *Tx) error {
+		return c.Query("kind").Insert(Document{ID: "1"}).Exec(ctx, tx)
+	}))
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	require.Equal(t, ErrNotFound, c.Update(ctx, func(tx *Tx) error {
+		return ErrNotFound
+	}))
+
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	require.NoError(t, c.Update(ctx, func(tx *Tx) error {
+		return tx.Commit()
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestClient_View(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+
+	err := c.View(ctx, func(tx *Tx) error {
+		return c.Query("kind").Insert(Document{ID: "1"}).Exec(ctx, tx)
+	})
+	require.Equal(t, ErrReadOnlyMode, err)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
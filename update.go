@@ -1,7 +1,6 @@
 package jdb
 
 import (
-	"bytes"
 	"context"
 )
 
@@ -11,7 +10,8 @@ type UpdateBuilder struct {
 	q  *Query
 	wb *WhereBuilder
 
-	value interface{}
+	value     interface{}
+	checkRefs bool
 }
 
 func newUpdateBuilder(q *Query, wb *WhereBuilder, value interface{}) *UpdateBuilder {
@@ -21,33 +21,90 @@ func newUpdateBuilder(q *Query, wb *WhereBuilder, value interface{}) *UpdateBuil
 	return &UpdateBuilder{q: q, wb: wb, value: value}
 }
 
-func (b *UpdateBuilder) Exec(ctx context.Context, tx *Tx) error {
-	_, err := tx.exec(ctx, b)
-	return err
+// CheckRefs makes Exec return a RefError when a ref points at a missing
+// document.
+func (b *UpdateBuilder) CheckRefs() *UpdateBuilder {
+	n := *b
+	n.checkRefs = true
+	return &n
+}
+
+func (b *UpdateBuilder) Exec(ctx context.Context, e Executor) error {
+	return e.run(ctx, writeMode, func(tx *Tx) error {
+		return b.exec(ctx, tx)
+	})
+}
+
+func (b *UpdateBuilder) exec(ctx context.Context, tx *Tx) error {
+	n := *b
+	n.value = hookValue(b.value, beforeUpdaterType, afterUpdaterType)
+	b = &n
+
+	if err := b.q.kinds.hook(ctx, tx, b.q.kind, beforeUpdateHook, b.value); err != nil {
+		return err
+	}
+
+	query, params, r, err := b.toSQL()
+	if err != nil {
+		return err
+	}
+
+	if b.checkRefs {
+		if err := checkRefs(ctx, tx, b.q, r.Refs); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.exec(ctx, sqlQuery{query, params}); err != nil {
+		return err
+	}
+
+	if r.HasRefs {
+		if err := replaceRefs(ctx, tx, b.q, b.wb, r); err != nil {
+			return err
+		}
+	}
+	if r.HasKeys {
+		if err := replaceKeys(ctx, tx, b.q, b.wb, r); err != nil {
+			return err
+		}
+	}
+	return b.q.kinds.hook(ctx, tx, b.q.kind, afterUpdateHook, b.value)
 }
 
 func (b *UpdateBuilder) ToSQL() (string, []interface{}, error) {
-	var params []interface{}
-	query := &bytes.Buffer{}
+	query, params, _, err := b.toSQL()
+	return query, params, err
+}
+
+func (b *UpdateBuilder) toSQL() (string, []interface{}, *row, error) {
+	w := newSQLWriter(b.q.d, b.q.table)
 
-	r, err := rowScanInput(b.q.kind, b.value)
+	r, err := b.q.rowScanInput(b.value)
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
+	}
+	if err := b.q.validate(r); err != nil {
+		return "", nil, nil, err
+	}
+	if err := b.q.setSchemaVersion(r); err != nil {
+		return "", nil, nil, err
 	}
 
-	query.WriteString("UPDATE ")
-	query.WriteString(b.q.table)
-	query.WriteString(" SET ")
+	w.WriteString("UPDATE ")
+	w.WriteString(b.q.table)
+	w.WriteString(" SET ")
 
-	query.WriteString(updateColumnsSQL)
-	query.WriteString(b.q.d.TimestampExpression())
-	query.WriteString(" ")
-	params = append(params, r.ParentKind, r.ParentID, r.UniqueStringKey, r.StringKey, r.NumericKey, r.TimeKey, r.Data)
+	w.WriteString(updateColumnsSQL)
+	w.WriteString(b.q.d.TimestampExpression())
+	w.WriteString(" ")
+	w.AddParams(r.ParentKind, r.ParentID, r.UniqueStringKey, r.StringKey, r.NumericKey, r.TimeKey, r.Data)
 
-	err = b.wb.toWhereSQL(query, &params)
+	err = b.wb.toWhereSQL(w)
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
 	}
 
-	return b.q.d.ReplacePlaceHolders(query.String()), params, nil
+	query, params := w.toSQL()
+	return query, params, r, nil
 }
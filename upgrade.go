@@ -0,0 +1,257 @@
+package jdb
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+
+	"github.com/silas/jdb/internal/json"
+)
+
+const upgradeBatchSize = 100
+
+var schemaVersionKey = []byte(strconv.Quote(schemaVersionTag))
+
+// UpgradeFunc converts the data of a document from the previous schema
+// version of its kind.
+type UpgradeFunc func(data []byte) ([]byte, error)
+
+func (r *kindRegistry) upgradeFuncs(kind string) []UpgradeFunc {
+	if r == nil {
+		return nil
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.upgrades[kind]
+}
+
+// RegisterUpgrade registers fn to convert documents of kind from version-1
+// to version. Versions start at 1 and must be registered in order.
+//
+// Documents are written with the latest version of their kind, and older
+// documents are upgraded when scanned, so the upgraded data is written back
+// on their next update. UpgradeKind rewrites the remaining documents.
+func (c *Client) RegisterUpgrade(kind string, version int, fn UpgradeFunc) error {
+	if fn == nil {
+		return fmt.Errorf("jdb: %s upgrade function required", kind)
+	}
+
+	c.kinds.mu.Lock()
+	defer c.kinds.mu.Unlock()
+
+	if want := len(c.kinds.upgrades[kind]) + 1; version != want {
+		return fmt.Errorf("jdb: %s upgrade version must be %d: %d", kind, want, version)
+	}
+	c.kinds.upgrades[kind] = append(c.kinds.upgrades[kind], fn)
+	return nil
+}
+
+// SchemaVersion returns the latest schema version of kind, which is 0 when
+// no upgrades are registered.
+func (c *Client) SchemaVersion(kind string) int {
+	return len(c.kinds.upgradeFuncs(kind))
+}
+
+// splitSchemaVersion removes the schema version from data, a missing version
+// is 0. The other members are left as they are stored.
+func splitSchemaVersion(data []byte) ([]byte, int, error) {
+	if !bytes.Contains(data, schemaVersionKey) {
+		return data, 0, nil
+	}
+
+	start, end, value, ok := objectMember(data, schemaVersionTag)
+	if !ok {
+		return data, 0, nil
+	}
+
+	var version int
+	if err := json.Unmarshal(value, &version); err != nil || version < 0 {
+		return nil, 0, fmt.Errorf("schema version is invalid: %s", value)
+	}
+
+	stripped := make([]byte, 0, len(data)-(end-start))
+	stripped = append(stripped, data[:start]...)
+	stripped = append(stripped, data[end:]...)
+	return stripped, version, nil
+}
+
+// objectMember finds the named member of the JSON object in data without
+// decoding the other members. It returns the member's value and its byte
+// range, which includes one of the commas separating it from its neighbours.
+func objectMember(data []byte, name string) (int, int, json.RawMessage, bool) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return 0, 0, nil, false
+	}
+
+	for first := true; dec.More(); first = false {
+		// after the first member the range starts with the preceding comma
+		start := int(dec.InputOffset())
+		t, err := dec.Token()
+		if err != nil {
+			return 0, 0, nil, false
+		}
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return 0, 0, nil, false
+		}
+		end := int(dec.InputOffset())
+
+		if key, _ := t.(string); key != name {
+			continue
+		}
+		if first {
+			rest := bytes.TrimLeft(data[end:], " \t\r\n")
+			if len(rest) > 0 && rest[0] == ',' {
+				end = len(data) - len(rest) + 1
+			}
+		}
+		return start, end, value, true
+	}
+
+	return 0, 0, nil, false
+}
+
+// upgrade returns data converted to the latest schema version of kind, along
+// with the version it was stored at.
+func (r *kindRegistry) upgrade(kind string, data []byte) ([]byte, int, error) {
+	data, version, err := splitSchemaVersion(data)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	fns := r.upgradeFuncs(kind)
+	if version > len(fns) {
+		return nil, 0, fmt.Errorf("jdb: %s schema version %d is newer than %d", kind, version, len(fns))
+	}
+	for i := version; i < len(fns); i++ {
+		data, err = fns[i](data)
+		if err != nil {
+			return nil, 0, fmt.Errorf("jdb: %s upgrade to version %d: %v", kind, i+1, err)
+		}
+	}
+	return data, version, nil
+}
+
+// setSchemaVersion adds the latest schema version of the kind to the data of
+// r.
+func (q *Query) setSchemaVersion(r *row) error {
+	version := len(q.kinds.upgradeFuncs(r.Kind))
+	if version == 0 {
+		return nil
+	}
+
+	data := []byte("{}")
+	if r.Data != nil {
+		var err error
+		data, _, err = splitSchemaVersion([]byte(*r.Data))
+		if err != nil {
+			return err
+		}
+	}
+	data = bytes.TrimSpace(data)
+	if len(data) < 2 || data[0] != '{' {
+		return fmt.Errorf("jdb: %s data must be an object to set a schema version", r.Kind)
+	}
+
+	w := &bytes.Buffer{}
+	w.WriteString("{")
+	w.Write(schemaVersionKey)
+	w.WriteString(":")
+	w.WriteString(strconv.Itoa(version))
+	if rest := bytes.TrimSpace(data[1:]); len(rest) > 0 && rest[0] != '}' {
+		w.WriteString(",")
+	}
+	w.Write(data[1:])
+
+	s := w.String()
+	r.Data = &s
+	return nil
+}
+
+// UpgradeKind rewrites the documents of kind stored with an old schema
+// version, in batches, and returns the number of documents upgraded.
+//
+// Documents of a kind registered with Register are updated through their
+// struct type, so keys and refs derived from upgraded fields are refreshed,
+// otherwise only the data is rewritten.
+func (c *Client) UpgradeKind(ctx context.Context, kind string) (int, error) {
+	if c.readOnly {
+		return 0, ErrReadOnlyMode
+	}
+
+	version := c.SchemaVersion(kind)
+	if version == 0 {
+		return 0, fmt.Errorf("jdb: upgrades not registered: %s", kind)
+	}
+
+	c.kinds.mu.RLock()
+	t := c.kinds.names[kind]
+	c.kinds.mu.RUnlock()
+
+	q := c.Query(kind)
+	var upgraded int
+	var lastID string
+	for {
+		var docs []Document
+		var batch int
+		err := c.Tx(ctx, func(tx *Tx) error {
+			// counted per attempt, the transaction may be retried
+			batch = 0
+			sb := q.Where(Gt(c.ID, lastID)).Select().OrderBy(c.ID.Asc()).Limit(upgradeBatchSize)
+			if err := sb.All(ctx, tx, &docs); err != nil {
+				return err
+			}
+
+			for _, doc := range docs {
+				if doc.SchemaVersion == version {
+					continue
+				}
+				if err := c.upgradeDocument(ctx, tx, q, t, doc); err != nil {
+					return err
+				}
+				batch++
+			}
+
+			return tx.Commit()
+		})
+		if err != nil {
+			return upgraded, err
+		}
+		upgraded += batch
+		if len(docs) < upgradeBatchSize {
+			return upgraded, nil
+		}
+		lastID = docs[len(docs)-1].ID
+	}
+}
+
+func (c *Client) upgradeDocument(ctx context.Context, tx *Tx, q *Query, t reflect.Type, doc Document) error {
+	if t != nil {
+		v := reflect.New(t)
+		if err := q.Get(doc.ID).Select().First(ctx, tx, v.Interface()); err != nil {
+			return err
+		}
+		return q.Update(v.Interface()).Exec(ctx, tx)
+	}
+
+	r := &row{Kind: doc.Kind, ID: doc.ID}
+	if len(doc.Data) > 0 {
+		data := string(doc.Data)
+		r.Data = &data
+	}
+	if err := q.validate(r); err != nil {
+		return err
+	}
+	if err := q.setSchemaVersion(r); err != nil {
+		return err
+	}
+
+	w := newSQLWriter(c.d, c.table)
+	w.WriteString("UPDATE " + c.table + " SET data = ?, update_time = " + c.d.TimestampExpression() +
+		" WHERE kind = ? AND id = ?")
+	w.AddParams(r.Data, r.Kind, r.ID)
+	return tx.execWriter(ctx, w)
+}
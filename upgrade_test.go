@@ -0,0 +1,214 @@
+package jdb
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+type upgradeUser struct {
+	ID       string `jdb:"-id"`
+	FullName string `jdb:"full_name"`
+	Version  int    `jdb:"-schemaversion"`
+}
+
+func renameField(from, to string) UpgradeFunc {
+	return func(data []byte) ([]byte, error) {
+		var fields map[string]interface{}
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, err
+		}
+		if v, ok := fields[from]; ok {
+			fields[to] = v
+			delete(fields, from)
+		}
+		return json.Marshal(fields)
+	}
+}
+
+func TestClient_RegisterUpgrade(t *testing.T) {
+	c, _ := createMockClient(t)
+	defer c.Close()
+
+	require.Equal(t, 0, c.SchemaVersion("user"))
+
+	require.EqualError(t, c.RegisterUpgrade("user", 2, renameField("a", "b")),
+		"jdb: user upgrade version must be 1: 2")
+	require.EqualError(t, c.RegisterUpgrade("user", 1, nil), "jdb: user upgrade function required")
+
+	require.NoError(t, c.RegisterUpgrade("user", 1, renameField("name", "fullname")))
+	require.NoError(t, c.RegisterUpgrade("user", 2, renameField("fullname", "full_name")))
+	require.Equal(t, 2, c.SchemaVersion("user"))
+
+	data, version, err := c.kinds.upgrade("user", []byte(`{"name":"Alice"}`))
+	require.NoError(t, err)
+	require.Equal(t, 0, version)
+	require.JSONEq(t, `{"full_name":"Alice"}`, string(data))
+
+	data, version, err = c.kinds.upgrade("user", []byte(`{"-schemaversion":1,"fullname":"Alice"}`))
+	require.NoError(t, err)
+	require.Equal(t, 1, version)
+	require.JSONEq(t, `{"full_name":"Alice"}`, string(data))
+
+	data, version, err = c.kinds.upgrade("user", []byte(`{"-schemaversion":2,"full_name":"Alice"}`))
+	require.NoError(t, err)
+	require.Equal(t, 2, version)
+	require.JSONEq(t, `{"full_name":"Alice"}`, string(data))
+
+	_, _, err = c.kinds.upgrade("user", []byte(`{"-schemaversion":3}`))
+	require.EqualError(t, err, "jdb: user schema version 3 is newer than 2")
+
+	_, _, err = c.kinds.upgrade("user", []byte(`{"-schemaversion":"1"}`))
+	require.EqualError(t, err, `schema version is invalid: "1"`)
+
+	require.NoError(t, c.RegisterUpgrade("broken", 1, func([]byte) ([]byte, error) {
+		return nil, errors.New("failed")
+	}))
+	_, _, err = c.kinds.upgrade("broken", []byte(`{}`))
+	require.EqualError(t, err, "jdb: broken upgrade to version 1: failed")
+}
+
+func TestSplitSchemaVersion(t *testing.T) {
+	tests := []struct {
+		data    string
+		want    string
+		version int
+	}{
+		{`{"b":2,"a":1.0}`, `{"b":2,"a":1.0}`, 0},
+		{`{"-schemaversion":2,"b":2,"a":1.0}`, `{"b":2,"a":1.0}`, 2},
+		{`{ "-schemaversion" : 2 , "b":2}`, `{  "b":2}`, 2},
+		{`{"b":2,"-schemaversion":2,"a":1.0}`, `{"b":2,"a":1.0}`, 2},
+		{`{"b":2,"a":1.0,"-schemaversion":2}`, `{"b":2,"a":1.0}`, 2},
+		{`{"-schemaversion":2}`, `{}`, 2},
+		{`{"b":"-schemaversion"}`, `{"b":"-schemaversion"}`, 0},
+		{`{"b":{"-schemaversion":2}}`, `{"b":{"-schemaversion":2}}`, 0},
+	}
+
+	for _, test := range tests {
+		data, version, err := splitSchemaVersion([]byte(test.data))
+		require.NoError(t, err, test.data)
+		require.Equal(t, test.want, string(data), test.data)
+		require.Equal(t, test.version, version, test.data)
+	}
+}
+
+func TestQuery_setSchemaVersion(t *testing.T) {
+	c, _ := createMockClient(t)
+	defer c.Close()
+
+	require.NoError(t, c.RegisterUpgrade("user", 1, renameField("name", "full_name")))
+
+	q := c.Query("user")
+
+	r, err := q.rowScanInput(upgradeUser{ID: "1", FullName: "Alice", Version: 1})
+	require.NoError(t, err)
+	require.NoError(t, q.setSchemaVersion(r))
+	require.Equal(t, `{"-schemaversion":1,"full_name":"Alice"}`, *r.Data)
+
+	r, err = q.rowScanInput(Document{ID: "1"})
+	require.NoError(t, err)
+	require.NoError(t, q.setSchemaVersion(r))
+	require.Equal(t, `{"-schemaversion":1}`, *r.Data)
+
+	r, err = q.rowScanInput(Document{ID: "1", Data: json.RawMessage(`{"-schemaversion":0,"a":1}`)})
+	require.NoError(t, err)
+	require.NoError(t, q.setSchemaVersion(r))
+	require.Equal(t, `{"-schemaversion":1,"a":1}`, *r.Data)
+
+	r, err = q.rowScanInput(Document{ID: "1", Data: json.RawMessage(`[1]`)})
+	require.NoError(t, err)
+	require.EqualError(t, q.setSchemaVersion(r), "jdb: user data must be an object to set a schema version")
+
+	r, err = c.Query("other").rowScanInput(upgradeUser{ID: "1", FullName: "Alice"})
+	require.NoError(t, err)
+	require.NoError(t, q.setSchemaVersion(r))
+	require.Equal(t, `{"full_name":"Alice"}`, *r.Data)
+}
+
+func TestRows_ScanUpgrade(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	require.NoError(t, c.RegisterUpgrade("user", 1, renameField("name", "full_name")))
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`SELECT id, data FROM jdb WHERE \(\(kind = \?\)\)`).
+		WithArgs("user").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "data"}).
+			AddRow("1", `{"name":"Alice"}`).
+			AddRow("2", `{"-schemaversion":1,"full_name":"Bob"}`))
+	mock.ExpectRollback()
+
+	var users []upgradeUser
+	err := c.Tx(context.Background(), func(tx *Tx) error {
+		return c.Query("user").Select(c.ID, c.Data).All(context.Background(), tx, &users)
+	})
+	require.NoError(t, err)
+	require.Equal(t, []upgradeUser{
+		{ID: "1", FullName: "Alice", Version: 0},
+		{ID: "2", FullName: "Bob", Version: 1},
+	}, users)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestSelectBuilder_IncludeUpgrade(t *testing.T) {
+	c, mock := createMockClient(t)
+	defer c.Close()
+
+	require.NoError(t, c.RegisterUpgrade("comment", 1, renameField("text", "body")))
+	require.NoError(t, c.RegisterUpgrade("product", 1, renameField("title", "name")))
+
+	columns := []string{"kind", "id", "parent_kind", "parent_id", "data", "create_time", "update_time"}
+	now := time.Date(2005, 3, 7, 8, 23, 34, 0, time.UTC)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT kind, id, parent_kind, parent_id, data, create_time, update_time ` +
+		`FROM jdb WHERE ((kind = ?))`)).
+		WithArgs("post").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("post", "1", nil, nil, `{"title":"One"}`, now, now))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT kind, id, parent_kind, parent_id, data, create_time, update_time `+
+		`FROM jdb WHERE ((kind = ?) AND (((parent_kind = ?) AND (parent_id IN (?)))))`)).
+		WithArgs("comment", "post", "1").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("comment", "2", "post", "1", `{"text":"Two"}`, now, now).
+			AddRow("comment", "3", "post", "1", `{"-schemaversion":1,"body":"Three"}`, now, now))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT kind, id, parent_kind, parent_id, data, create_time, update_time ` +
+		`FROM jdb WHERE ((kind = ?))`)).
+		WithArgs("order").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("order", "4", nil, nil, `{"productId":"5"}`, now, now))
+	mock.ExpectQuery(regexp.QuoteMeta(`SELECT kind, id, parent_kind, parent_id, data, create_time, update_time `+
+		`FROM jdb WHERE ((kind = ?) AND (id IN (?)))`)).
+		WithArgs("product", "5").
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow("product", "5", nil, nil, `{"title":"Five"}`, now, now))
+	mock.ExpectRollback()
+
+	ctx := context.Background()
+
+	require.NoError(t, c.Tx(ctx, func(tx *Tx) error {
+		var posts []includePost
+		require.NoError(t, c.Query("post").Select().Include("comment").All(ctx, tx, &posts))
+		require.Equal(t, []includePost{
+			{ID: "1", Title: "One", Comments: []includeComment{{ID: "2", Body: "Two"}, {ID: "3", Body: "Three"}}},
+		}, posts)
+
+		var orders []refOrder
+		require.NoError(t, c.Query("order").Select().Preload("Product").All(ctx, tx, &orders))
+		require.Equal(t, []refOrder{
+			{ID: "4", ProductID: "5", Product: &refProduct{ID: "5", Name: "Five"}},
+		}, orders)
+
+		return nil
+	}))
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
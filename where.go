@@ -1,17 +1,17 @@
 package jdb
 
-import (
-	"bytes"
-)
-
 type WhereBuilder struct {
-	q *Query
+	q    *Query
+	tree *tree
 
 	where and
 }
 
 func newWhereBuilder(q *Query) *WhereBuilder {
 	b := &WhereBuilder{q: q}
+	if q.scoped() {
+		return b.Where(Eq(kindField, q.kind), Eq(parentKindField, q.parentKind), Eq(parentIdField, q.parentID))
+	}
 	return b.Where(Eq(kindField, q.kind))
 }
 
@@ -36,9 +36,9 @@ func (b *WhereBuilder) Where(where ...Condition) *WhereBuilder {
 	return &n
 }
 
-func (b *WhereBuilder) toWhereSQL(query *bytes.Buffer, params *[]interface{}) error {
-	query.WriteString("WHERE ")
-	return b.where.toConditionSQL(query, params)
+func (b *WhereBuilder) toWhereSQL(w *SQLWriter) error {
+	w.WriteString("WHERE ")
+	return b.where.ConditionSQL(w)
 }
 
 func (b *WhereBuilder) Delete() *DeleteBuilder {
@@ -1,7 +1,6 @@
 package jdb
 
 import (
-	"bytes"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -11,13 +10,12 @@ func TestWhere(t *testing.T) {
 	c, mock := createMockClient(t)
 	defer c.Close()
 
-	var p []interface{}
-	q := &bytes.Buffer{}
+	w := newSQLWriter(c.d, c.table)
 
-	err := c.Query("test").Where(Eq(idField, "1")).toWhereSQL(q, &p)
+	err := c.Query("test").Where(Eq(idField, "1")).toWhereSQL(w)
 	require.NoError(t, err)
-	require.Equal(t, "WHERE ((kind = ?) AND (id = ?))", q.String())
-	require.Equal(t, params("test", "1"), p)
+	require.Equal(t, "WHERE ((kind = ?) AND (id = ?))", w.String())
+	require.Equal(t, params("test", "1"), *w.params)
 
 	require.NoError(t, mock.ExpectationsWereMet())
 }
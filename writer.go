@@ -0,0 +1,87 @@
+package jdb
+
+import (
+	"bytes"
+	"strconv"
+
+	"github.com/silas/jdb/dialect"
+)
+
+// SQLWriter is used by conditions and fields to render themselves into a
+// query. Parameters are always written as "?" and are replaced with the
+// dialect's placeholders once the query is complete.
+type SQLWriter struct {
+	d      dialect.Dialect
+	query  *bytes.Buffer
+	params *[]interface{}
+	table  string
+	alias  string
+	depth  int
+}
+
+func newSQLWriter(d dialect.Dialect, table string) *SQLWriter {
+	return &SQLWriter{
+		d:      d,
+		query:  &bytes.Buffer{},
+		params: new([]interface{}),
+		table:  table,
+		alias:  table,
+	}
+}
+
+// buffer returns a writer that shares parameters with w but writes to a
+// separate buffer.
+func (w *SQLWriter) buffer() *SQLWriter {
+	n := *w
+	n.query = &bytes.Buffer{}
+	return &n
+}
+
+// subquery returns a writer for a nested query against the table, which is
+// given a unique alias so conditions can refer to the enclosing query.
+func (w *SQLWriter) subquery() *SQLWriter {
+	n := *w
+	n.depth++
+	n.alias = w.table + "_" + strconv.Itoa(n.depth)
+	return &n
+}
+
+func (w *SQLWriter) Dialect() dialect.Dialect {
+	return w.d
+}
+
+// Table returns the name used to refer to the table in the current query,
+// which is an alias inside subqueries.
+func (w *SQLWriter) Table() string {
+	return w.alias
+}
+
+func (w *SQLWriter) WriteString(s string) {
+	w.query.WriteString(s)
+}
+
+func (w *SQLWriter) WriteParam(v interface{}) {
+	w.query.WriteString("?")
+	*w.params = append(*w.params, v)
+}
+
+// AddParams adds parameters for placeholders already written to the query.
+func (w *SQLWriter) AddParams(v ...interface{}) {
+	*w.params = append(*w.params, v...)
+}
+
+func (w *SQLWriter) WriteField(f WhereField) error {
+	return f.WhereFieldSQL(w)
+}
+
+func (w *SQLWriter) WriteCondition(c Condition) error {
+	return c.ConditionSQL(w)
+}
+
+func (w *SQLWriter) String() string {
+	return w.query.String()
+}
+
+func (w *SQLWriter) toSQL() (string, []interface{}) {
+	return w.d.ReplacePlaceHolders(w.query.String()), *w.params
+}